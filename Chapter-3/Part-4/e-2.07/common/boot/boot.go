@@ -1,62 +1,48 @@
 package boot
 
 import (
+	"common/logging"
 	"context"
-	"log"
-	"net/http"
+	"log/slog"
+	"os"
 	"os/signal"
 	"syscall"
-	"time"
 )
 
-type App interface {
-	Start(ctx context.Context) error
-	Stop() error
-}
-
-func Run(app App, srv *http.Server) {
-	appCtx, appCancel := context.WithCancel(context.Background())
-	defer appCancel()
-
-	done := make(chan bool, 1)
-	go gracefulShutdown(srv, app, appCancel, done)
-
-	if err := app.Start(appCtx); err != nil {
-		log.Fatalf("failed to start application: %v", err)
-	}
-
-	log.Printf("Server started on port: %s", srv.Addr)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("http server error: %s", err)
-	}
-
-	<-done
-	log.Println("Graceful shutdown complete.")
-}
-
-func gracefulShutdown(apiServer *http.Server, app App, appCancel context.CancelFunc, done chan bool) {
+// Run starts the supervised components and blocks until SIGINT/SIGTERM or until a
+// component fails fast, then drains (see ShutdownConfig) and stops everything in reverse order.
+func Run(sup *Supervisor, cfg ShutdownConfig) {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	<-ctx.Done()
+	if err := sup.Start(ctx); err != nil {
+		logging.Fatal("failed to start application", "error", err)
+	}
 
-	log.Println("shutting down gracefully, press Ctrl+C again to force")
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down gracefully, press Ctrl+C again to force")
+	case <-sup.Done():
+		slog.Error("component failure, shutting down")
+		cfg.DrainDelay = 0 // a failed component is not worth waiting for the endpoints
+	}
 	stop() // Allow Ctrl+C to force shutdown
 
-	// Shutdown sequence: 1) Stop application services, 2) Stop HTTP server
-	log.Println("stopping application services...")
-	appCancel() // Cancel application context to stop logger
-	if err := app.Stop(); err != nil {
-		log.Printf("Application shutdown error: %v", err)
+	if err := gracefulShutdown(sup, cfg); err != nil {
+		slog.Error("shutdown finished with errors", "error", err)
+		os.Exit(1)
 	}
-
-	log.Println("stopping HTTP server...")
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := apiServer.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Server forced to shutdown with error: %v", err)
+	if err := sup.Err(); err != nil {
+		slog.Error("exiting after failure", "error", err)
+		os.Exit(1)
 	}
+	slog.Info("graceful shutdown complete")
+}
+
+func gracefulShutdown(sup *Supervisor, cfg ShutdownConfig) error {
+	// a preStop hook may have started the drain already
+	waitDrain(sup.Drain(), cfg.DrainDelay)
 
-	log.Println("Server exiting")
-	done <- true
+	slog.Info("stopping components")
+	return sup.Stop()
 }
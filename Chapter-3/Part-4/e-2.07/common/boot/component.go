@@ -0,0 +1,117 @@
+package boot
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Component is a unit of work managed by the Supervisor (background worker, http server, db pool...).
+// Run should block until ctx is cancelled or the component crashes.
+type Component interface {
+	Run(ctx context.Context) error
+}
+
+// Starter is implemented by components that need a synchronous init step
+// (e.g. opening a connection) before the components depending on them are started.
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper is implemented by components that need explicit cleanup on shutdown
+// (e.g. http.Server.Shutdown, sql.DB.Close). ctx carries the per-component stop timeout.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// WorkerFunc adapts a blocking function into a Component
+type WorkerFunc func(ctx context.Context) error
+
+func (f WorkerFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Resource is a Component with no background work, only a lifecycle (e.g. a db pool).
+// StartFn and StopFn are optional.
+type Resource struct {
+	StartFn func(ctx context.Context) error
+	StopFn  func(ctx context.Context) error
+}
+
+func (r *Resource) Start(ctx context.Context) error {
+	if r.StartFn == nil {
+		return nil
+	}
+	return r.StartFn(ctx)
+}
+
+func (r *Resource) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (r *Resource) Stop(ctx context.Context) error {
+	if r.StopFn == nil {
+		return nil
+	}
+	return r.StopFn(ctx)
+}
+
+// RequestTracker reports the requests an HTTPServer is still serving (server.InFlight)
+type RequestTracker interface {
+	Len() int
+	LogActive(logger *slog.Logger)
+}
+
+// HTTPServer wraps an *http.Server as a Component, served over https when srv.TLSConfig is set.
+// Stop stops accepting and waits for the in-flight requests until the StopTimeout of the component.
+type HTTPServer struct {
+	srv     *http.Server
+	tracker RequestTracker
+}
+
+func NewHTTPServer(srv *http.Server) *HTTPServer {
+	return &HTTPServer{
+		srv: srv,
+	}
+}
+
+func (h *HTTPServer) Run(ctx context.Context) error {
+	var err error
+	if h.srv.TLSConfig != nil {
+		slog.Info("server started", "addr", h.srv.Addr, "tls", true)
+		// certificates come from TLSConfig.GetCertificate
+		err = h.srv.ListenAndServeTLS("", "")
+	} else {
+		slog.Info("server started", "addr", h.srv.Addr)
+		err = h.srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Track makes Stop log the requests still running when the deadline expires
+func (h *HTTPServer) Track(t RequestTracker) *HTTPServer {
+	h.tracker = t
+	return h
+}
+
+func (h *HTTPServer) Stop(ctx context.Context) error {
+	if h.tracker != nil {
+		slog.Info("waiting for in-flight requests", "addr", h.srv.Addr, "in_flight", h.tracker.Len())
+	}
+	err := h.srv.Shutdown(ctx)
+	if err == nil {
+		return nil
+	}
+
+	if h.tracker != nil {
+		slog.Warn("in-flight requests left at the shutdown deadline, closing their connections",
+			"addr", h.srv.Addr, "in_flight", h.tracker.Len())
+		h.tracker.LogActive(slog.Default())
+	}
+	return errors.Join(err, h.srv.Close())
+}
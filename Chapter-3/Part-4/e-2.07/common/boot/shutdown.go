@@ -0,0 +1,55 @@
+package boot
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ShutdownConfig sizes the shutdown sequence, bound with common/config. The sequence is:
+// readiness turns off (OnShutdown hooks), DrainDelay passes so the endpoints are removed from
+// the Services, the http servers stop accepting and wait up to Timeout for the in-flight
+// requests, then the other components stop in reverse start order.
+// DrainDelay + Timeout + the component stop timeouts must fit in terminationGracePeriodSeconds.
+type ShutdownConfig struct {
+	DrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" default:"5s"`
+	Timeout    time.Duration `env:"SHUTDOWN_TIMEOUT" default:"20s"` // in-flight requests deadline, the StopTimeout of the http server
+}
+
+// waitDrain sleeps what is left of the drain delay started at start
+func waitDrain(start time.Time, delay time.Duration) {
+	if left := delay - time.Since(start); left > 0 {
+		slog.Info("draining, waiting for the endpoints to be removed", "remaining", left.Round(time.Millisecond))
+		time.Sleep(left)
+	}
+}
+
+// PreStopHandler serves the preStop hook (mounted on the admin listener for POST from loopback):
+// it starts the drain and answers once the drain delay has passed, the SIGTERM sent afterwards
+// then skips the delay. The kubelet runs "<binary> --prestop" in the container (see PreStop),
+// images without a shell have no "sleep" for an exec hook.
+func PreStopHandler(sup *Supervisor, cfg ShutdownConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := sup.Drain()
+		slog.Info("preStop hook received")
+		waitDrain(start, cfg.DrainDelay)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PreStop is the client side of PreStopHandler, run by the preStop exec hook: it asks the server
+// listening on adminPort to drain and returns once the drain delay has passed
+func PreStop(adminPort int, cfg ShutdownConfig) error {
+	client := &http.Client{Timeout: cfg.DrainDelay + 10*time.Second}
+	resp, err := client.Post(fmt.Sprintf("http://127.0.0.1:%d/admin/prestop", adminPort), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("preStop hook answered %s", resp.Status)
+	}
+	return nil
+}
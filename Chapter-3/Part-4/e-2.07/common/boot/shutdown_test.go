@@ -0,0 +1,214 @@
+package boot
+
+import (
+	"bytes"
+	"common/server"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPreStop(t *testing.T) {
+	sup := NewSupervisor()
+	drained := false
+	sup.OnShutdown(func() { drained = true })
+	cfg := ShutdownConfig{DrainDelay: 50 * time.Millisecond}
+
+	var method string
+	handler := PreStopHandler(sup, cfg)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		handler(w, r)
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	adminPort, _ := strconv.Atoi(port)
+
+	start := time.Now()
+	if err := PreStop(adminPort, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < cfg.DrainDelay {
+		t.Fatalf("PreStop returned after %v, before the drain delay", elapsed)
+	}
+	if method != http.MethodPost || !drained {
+		t.Fatalf("hook sent %s, drained %v", method, drained)
+	}
+}
+
+func TestPreStopError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	adminPort, _ := strconv.Atoi(port)
+
+	if err := PreStop(adminPort, ShutdownConfig{}); err == nil {
+		t.Fatal("PreStop on a 403: expected an error")
+	}
+}
+
+func TestGracefulShutdownDrainDelay(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		preStop func(sup *Supervisor, cfg ShutdownConfig) // run before SIGTERM
+		wantMax time.Duration                             // bound of the wait of gracefulShutdown
+	}{
+		{"sigterm only", func(*Supervisor, ShutdownConfig) {}, 2 * delay},
+		{"after the preStop hook", func(sup *Supervisor, cfg ShutdownConfig) {
+			PreStopHandler(sup, cfg)(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/admin/prestop", nil))
+		}, delay / 2},
+		{"during the preStop hook", func(sup *Supervisor, cfg ShutdownConfig) {
+			go PreStopHandler(sup, cfg)(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/admin/prestop", nil))
+			time.Sleep(delay / 2)
+		}, delay * 3 / 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{}
+			var stopped time.Time
+			sup := NewSupervisor()
+			sup.Register("server", &Resource{StopFn: func(ctx context.Context) error {
+				stopped = time.Now()
+				rec.add("stop")
+				return nil
+			}}, Options{})
+			sup.OnShutdown(func() { rec.add("drain") })
+			if err := sup.Start(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			cfg := ShutdownConfig{DrainDelay: delay}
+
+			tt.preStop(sup, cfg)
+			start := time.Now()
+			if err := gracefulShutdown(sup, cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			// the drain delay is counted once from the first of preStop and SIGTERM
+			if got := stopped.Sub(sup.Drain()); got < delay {
+				t.Fatalf("stopped %s after the drain began, want at least %s", got, delay)
+			}
+			if got := time.Since(start); got > tt.wantMax {
+				t.Fatalf("gracefulShutdown took %s, want at most %s", got, tt.wantMax)
+			}
+			if got, want := rec.list(), []string{"drain", "stop"}; !slices.Equal(got, want) {
+				t.Fatalf("events = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// captureLogs sends the default logger to the returned buffer until the test ends
+func captureLogs(t *testing.T) *syncBuffer {
+	prev := slog.Default()
+	var buf syncBuffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// trackedServer serves a handler blocking until release is closed or its request is
+// cancelled, tracked by an InFlight
+func trackedServer(t *testing.T, release chan struct{}) (*HTTPServer, *server.InFlight, string) {
+	inFlight := server.NewInFlight()
+	srv := &http.Server{Handler: inFlight.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	return NewHTTPServer(srv).Track(inFlight), inFlight, "http://" + ln.Addr().String()
+}
+
+func TestHTTPServerStopWaitsInFlight(t *testing.T) {
+	logs := captureLogs(t)
+	release := make(chan struct{})
+	h, inFlight, url := trackedServer(t, release)
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	waitFor(t, "the request", func() bool { return inFlight.Len() == 1 })
+
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Stop(ctx); err != nil {
+		t.Fatalf("Stop() = %v, want the request served within the deadline", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("in-flight request failed: %v", err)
+	}
+	if inFlight.Len() != 0 || strings.Contains(logs.String(), "still in flight") {
+		t.Fatalf("%d requests in flight after Stop, logs:\n%s", inFlight.Len(), logs)
+	}
+}
+
+func TestHTTPServerStopDeadlineCloses(t *testing.T) {
+	logs := captureLogs(t)
+	h, inFlight, url := trackedServer(t, make(chan struct{}))
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url + "/stuck")
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	waitFor(t, "the request", func() bool { return inFlight.Len() == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop() = %v, want the shutdown deadline exceeded", err)
+	}
+	// the connections are closed, the stuck request is logged and then cancelled
+	if err := <-done; err == nil {
+		t.Fatal("stuck request answered, want its connection closed")
+	}
+	waitFor(t, "the stuck request to return", func() bool { return inFlight.Len() == 0 })
+	if out := logs.String(); !strings.Contains(out, "in_flight=1") || !strings.Contains(out, "request still in flight") || !strings.Contains(out, "path=/stuck") {
+		t.Fatalf("logs miss the stuck request:\n%s", out)
+	}
+}
@@ -0,0 +1,342 @@
+package boot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RestartPolicy decides what the Supervisor does when a component's Run returns before shutdown
+type RestartPolicy int
+
+const (
+	// FailFast stops the whole supervisor when the component crashes (default)
+	FailFast RestartPolicy = iota
+	// RestartOnFailure restarts the component after a crash, up to Options.MaxRestarts, then fails fast
+	RestartOnFailure
+	// RestartAlways restarts the component whenever Run returns, even without error
+	RestartAlways
+)
+
+const (
+	defaultStopTimeout    = 5 * time.Second
+	defaultRestartBackoff = time.Second
+	maxRestartBackoff     = 30 * time.Second
+	defaultStableAfter    = time.Minute
+	runGrace              = 100 * time.Millisecond // left to Run after a Stop using the whole StopTimeout
+)
+
+// Options configures how a single component is supervised. The zero value is usable.
+type Options struct {
+	DependsOn   []string      // components that must be started before this one (and stopped after it)
+	Restart     RestartPolicy // what to do on crash
+	MaxRestarts int           // 0 --> unlimited restarts
+	Backoff     time.Duration // initial restart delay, doubled on every restart (default 1s, max 30s)
+	StableAfter time.Duration // a run lasting this long resets the backoff to Backoff (default 1m)
+	StopTimeout time.Duration // time allowed for Stop + Run to return (default 5s, plus 100ms for Run when Stop used it all)
+}
+
+// ComponentError ties an error to the component that produced it
+type ComponentError struct {
+	Component string
+	Err       error
+}
+
+func (ce *ComponentError) Error() string {
+	return fmt.Sprintf("component %q: %v", ce.Component, ce.Err)
+}
+
+func (ce *ComponentError) Unwrap() error {
+	return ce.Err
+}
+
+type entry struct {
+	name     string
+	comp     Component
+	opts     Options
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopping atomic.Bool
+	started  bool
+}
+
+// Supervisor starts registered components in dependency order, watches them while running
+// and stops them in reverse order.
+type Supervisor struct {
+	mu      sync.Mutex
+	entries []*entry
+	byName  map[string]*entry
+	order   []*entry // resolved start order
+
+	shutdownHooks []func()
+	drainOnce     sync.Once
+	drainStart    time.Time
+
+	failOnce sync.Once
+	failed   chan struct{}
+	failErr  error
+}
+
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		byName: make(map[string]*entry),
+		failed: make(chan struct{}),
+	}
+}
+
+// Register adds a named component. It must be called before Start.
+func (s *Supervisor) Register(name string, comp Component, opts Options) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byName[name]; ok {
+		panic(fmt.Sprintf("boot: component %q registered twice", name))
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = defaultStopTimeout
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultRestartBackoff
+	}
+	if opts.StableAfter <= 0 {
+		opts.StableAfter = defaultStableAfter
+	}
+
+	e := &entry{
+		name: name,
+		comp: comp,
+		opts: opts,
+		done: make(chan struct{}),
+	}
+	s.entries = append(s.entries, e)
+	s.byName[name] = e
+}
+
+// Start starts every component in dependency order. If one fails to start, the
+// components already started are stopped again and the start error is returned.
+func (s *Supervisor) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, err := s.resolveOrder()
+	if err != nil {
+		return err
+	}
+	s.order = order
+
+	for _, e := range s.order {
+		slog.Info("starting component", "component", e.name)
+		if starter, ok := e.comp.(Starter); ok {
+			if err := starter.Start(ctx); err != nil {
+				startErr := &ComponentError{Component: e.name, Err: err}
+				if stopErr := s.stopStarted(); stopErr != nil {
+					return errors.Join(startErr, stopErr)
+				}
+				return startErr
+			}
+		}
+
+		// run context is detached from ctx, components are only cancelled through Stop
+		runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		e.cancel = cancel
+		e.started = true
+		go s.supervise(runCtx, e)
+	}
+
+	return nil
+}
+
+// OnShutdown registers fn to run as soon as the drain begins, before any component is stopped
+// (e.g. flipping readiness off).
+func (s *Supervisor) OnShutdown(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, fn)
+}
+
+// Drain begins the shutdown by running the OnShutdown hooks, once. It returns when the drain
+// began, for the preStop hook and the signal handler to share the drain delay.
+func (s *Supervisor) Drain() time.Time {
+	s.drainOnce.Do(func() {
+		s.mu.Lock()
+		hooks := s.shutdownHooks
+		s.mu.Unlock()
+
+		slog.Info("draining, readiness reports not ready")
+		s.drainStart = time.Now()
+		for _, fn := range hooks {
+			fn()
+		}
+	})
+	return s.drainStart
+}
+
+// Done is closed when a component fails fast
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.failed
+}
+
+// Err returns the error that made the supervisor fail, nil otherwise
+func (s *Supervisor) Err() error {
+	select {
+	case <-s.failed:
+		return s.failErr
+	default:
+		return nil
+	}
+}
+
+// Stop stops the started components in reverse start order, each one bounded by its StopTimeout.
+// The returned error aggregates one ComponentError per component that did not stop cleanly.
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stopStarted()
+}
+
+func (s *Supervisor) stopStarted() error {
+	var errs []error
+	for i := len(s.order) - 1; i >= 0; i-- {
+		e := s.order[i]
+		if !e.started {
+			continue
+		}
+		slog.Info("stopping component", "component", e.name)
+		if err := stopEntry(e); err != nil {
+			errs = append(errs, &ComponentError{Component: e.name, Err: err})
+		}
+		e.started = false
+	}
+	return errors.Join(errs...)
+}
+
+func stopEntry(e *entry) error {
+	e.stopping.Store(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.opts.StopTimeout)
+	defer cancel()
+
+	var errs []error
+	if stopper, ok := e.comp.(Stopper); ok {
+		if err := stopper.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	e.cancel()
+
+	// Run gets what is left of the timeout, and runGrace when Stop used all of it
+	deadline, _ := ctx.Deadline()
+	timer := time.NewTimer(max(time.Until(deadline), runGrace))
+	defer timer.Stop()
+	select {
+	case <-e.done:
+	case <-timer.C:
+		errs = append(errs, fmt.Errorf("did not stop within %s", e.opts.StopTimeout))
+	}
+	return errors.Join(errs...)
+}
+
+// supervise runs the component and applies its restart policy until it is stopped
+func (s *Supervisor) supervise(ctx context.Context, e *entry) {
+	defer close(e.done)
+
+	restarts := 0
+	backoff := e.opts.Backoff
+	for {
+		started := time.Now()
+		err := runSafely(ctx, e.comp)
+		if e.stopping.Load() {
+			return
+		}
+		// a crash after a healthy run starts over from the initial delay
+		if time.Since(started) >= e.opts.StableAfter {
+			backoff = e.opts.Backoff
+		}
+
+		switch {
+		case err == nil && e.opts.Restart != RestartAlways:
+			slog.Info("component exited", "component", e.name)
+			return
+		case e.opts.Restart == FailFast:
+			s.fail(&ComponentError{Component: e.name, Err: err})
+			return
+		case e.opts.MaxRestarts > 0 && restarts >= e.opts.MaxRestarts:
+			s.fail(&ComponentError{Component: e.name, Err: fmt.Errorf("gave up after %d restarts: %w", restarts, err)})
+			return
+		}
+
+		restarts++
+		slog.Warn("component crashed, restarting", "component", e.name, "error", err, "backoff", backoff, "restart", restarts)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRestartBackoff)
+	}
+}
+
+// runSafely turns a panic inside Run into an error so the restart policy can handle it
+func runSafely(ctx context.Context, comp Component) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return comp.Run(ctx)
+}
+
+func (s *Supervisor) fail(err error) {
+	s.failOnce.Do(func() {
+		slog.Error("supervisor failure", "error", err)
+		s.failErr = err
+		close(s.failed)
+	})
+}
+
+// resolveOrder sorts the entries so every component comes after its dependencies,
+// keeping registration order otherwise.
+func (s *Supervisor) resolveOrder() ([]*entry, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(s.entries))
+	order := make([]*entry, 0, len(s.entries))
+
+	var visit func(e *entry, path []string) error
+	visit = func(e *entry, path []string) error {
+		switch state[e.name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %v", append(path, e.name))
+		}
+		state[e.name] = visiting
+		for _, dep := range e.opts.DependsOn {
+			depEntry, ok := s.byName[dep]
+			if !ok {
+				return fmt.Errorf("component %q depends on unknown component %q", e.name, dep)
+			}
+			if err := visit(depEntry, append(path, e.name)); err != nil {
+				return err
+			}
+		}
+		state[e.name] = visited
+		order = append(order, e)
+		return nil
+	}
+
+	for _, e := range s.entries {
+		if err := visit(e, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,337 @@
+package boot
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects lifecycle events from several components in order
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events)
+}
+
+type recordedComponent struct {
+	name string
+	rec  *recorder
+}
+
+func (c *recordedComponent) Start(ctx context.Context) error {
+	c.rec.add("start " + c.name)
+	return nil
+}
+
+func (c *recordedComponent) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (c *recordedComponent) Stop(ctx context.Context) error {
+	c.rec.add("stop " + c.name)
+	return nil
+}
+
+// scriptedComponent fails every run; runs[i] is how long run i lasts before failing
+type scriptedComponent struct {
+	runs []time.Duration
+
+	mu     sync.Mutex
+	starts []time.Time
+	ends   []time.Time
+}
+
+func (c *scriptedComponent) Run(ctx context.Context) error {
+	c.mu.Lock()
+	i := len(c.starts)
+	c.starts = append(c.starts, time.Now())
+	c.mu.Unlock()
+
+	if i >= len(c.runs) {
+		<-ctx.Done()
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(c.runs[i]):
+	}
+
+	c.mu.Lock()
+	c.ends = append(c.ends, time.Now())
+	c.mu.Unlock()
+	return errors.New("crashed")
+}
+
+// gaps returns the delay between the end of each failed run and the next start
+func (c *scriptedComponent) gaps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var gaps []time.Duration
+	for i, end := range c.ends {
+		if i+1 < len(c.starts) {
+			gaps = append(gaps, c.starts[i+1].Sub(end))
+		}
+	}
+	return gaps
+}
+
+func (c *scriptedComponent) runCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.starts)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSupervisorOrder(t *testing.T) {
+	rec := &recorder{}
+	s := NewSupervisor()
+	s.Register("server", &recordedComponent{name: "server", rec: rec}, Options{DependsOn: []string{"db", "admin"}})
+	s.Register("db", &recordedComponent{name: "db", rec: rec}, Options{})
+	s.Register("admin", &recordedComponent{name: "admin", rec: rec}, Options{DependsOn: []string{"db"}})
+	s.OnShutdown(func() { rec.add("drain") })
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s.Drain()
+	s.Drain()
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"start db", "start admin", "start server",
+		"drain",
+		"stop server", "stop admin", "stop db",
+	}
+	if got := rec.list(); !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
+
+func TestSupervisorResolveOrderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    map[string][]string
+		wantErr string
+	}{
+		{"cycle", map[string][]string{"a": {"b"}, "b": {"a"}}, "dependency cycle"},
+		{"unknown", map[string][]string{"a": {"missing"}}, `unknown component "missing"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSupervisor()
+			for name, deps := range tt.deps {
+				s.Register(name, &Resource{}, Options{DependsOn: deps})
+			}
+			err := s.Start(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Start() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSupervisorStartFailureStopsStarted(t *testing.T) {
+	rec := &recorder{}
+	s := NewSupervisor()
+	s.Register("db", &recordedComponent{name: "db", rec: rec}, Options{})
+	s.Register("server", &Resource{StartFn: func(ctx context.Context) error {
+		return errors.New("bind: address in use")
+	}}, Options{DependsOn: []string{"db"}})
+
+	err := s.Start(context.Background())
+	var ce *ComponentError
+	if !errors.As(err, &ce) || ce.Component != "server" {
+		t.Fatalf("Start() = %v, want a ComponentError for server", err)
+	}
+	if got, want := rec.list(), []string{"start db", "stop db"}; !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
+
+func TestSupervisorFailFast(t *testing.T) {
+	s := NewSupervisor()
+	s.Register("worker", WorkerFunc(func(ctx context.Context) error {
+		return errors.New("boom")
+	}), Options{})
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not fail")
+	}
+
+	var ce *ComponentError
+	if err := s.Err(); !errors.As(err, &ce) || ce.Component != "worker" {
+		t.Fatalf("Err() = %v, want a ComponentError for worker", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisorMaxRestarts(t *testing.T) {
+	comp := &scriptedComponent{runs: []time.Duration{0, 0, 0, 0, 0}}
+	s := NewSupervisor()
+	s.Register("worker", comp, Options{Restart: RestartOnFailure, MaxRestarts: 2, Backoff: time.Millisecond})
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not give up")
+	}
+
+	if err := s.Err(); err == nil || !strings.Contains(err.Error(), "gave up after 2 restarts") {
+		t.Fatalf("Err() = %v, want it to give up after 2 restarts", err)
+	}
+	if got := comp.runCount(); got != 3 {
+		t.Fatalf("component ran %d times, want 3", got)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisorRestartsAfterPanic(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	s := NewSupervisor()
+	s.Register("worker", WorkerFunc(func(ctx context.Context) error {
+		mu.Lock()
+		runs++
+		first := runs == 1
+		mu.Unlock()
+		if first {
+			panic("nil map")
+		}
+		<-ctx.Done()
+		return nil
+	}), Options{Restart: RestartOnFailure, Backoff: time.Millisecond})
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the restart", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return runs == 2
+	})
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err() = %v after a restart", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	const backoff = 20 * time.Millisecond
+
+	// three quick crashes double the delay, then a run longer than StableAfter resets it
+	comp := &scriptedComponent{runs: []time.Duration{0, 0, 0, 60 * time.Millisecond, 0}}
+	s := NewSupervisor()
+	s.Register("worker", comp, Options{
+		Restart:     RestartOnFailure,
+		Backoff:     backoff,
+		StableAfter: 50 * time.Millisecond,
+	})
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the last restart", func() bool { return comp.runCount() == 6 })
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	gaps := comp.gaps()
+	if len(gaps) != 5 {
+		t.Fatalf("got %d restart gaps, want 5", len(gaps))
+	}
+	for i, want := range []time.Duration{backoff, 2 * backoff, 4 * backoff, backoff} {
+		if gaps[i] < want {
+			t.Errorf("gap %d = %s, want at least %s", i, gaps[i], want)
+		}
+	}
+	if gaps[3] >= 4*backoff {
+		t.Errorf("gap after the stable run = %s, want the backoff reset to %s", gaps[3], backoff)
+	}
+	if gaps[4] < 2*backoff {
+		t.Errorf("gap after the reset = %s, want it doubled again to %s", gaps[4], 2*backoff)
+	}
+}
+
+func TestSupervisorStopTimeout(t *testing.T) {
+	s := NewSupervisor()
+	s.Register("stuck", WorkerFunc(func(ctx context.Context) error {
+		select {}
+	}), Options{StopTimeout: 10 * time.Millisecond})
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	err := s.Stop()
+	var ce *ComponentError
+	if !errors.As(err, &ce) || ce.Component != "stuck" || !strings.Contains(err.Error(), "did not stop within") {
+		t.Fatalf("Stop() = %v, want a stop timeout for stuck", err)
+	}
+}
+
+// slowStopper uses its whole stop timeout, Run returns as soon as it is cancelled
+type slowStopper struct{}
+
+func (slowStopper) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (slowStopper) Stop(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestSupervisorRunAfterSlowStop(t *testing.T) {
+	s := NewSupervisor()
+	s.Register("server", slowStopper{}, Options{StopTimeout: 10 * time.Millisecond})
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop() = %v, want Run returning right after the slow Stop to count as stopped", err)
+	}
+}
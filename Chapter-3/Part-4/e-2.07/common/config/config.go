@@ -0,0 +1,301 @@
+// Package config binds environment variables, .env files and mounted ConfigMap
+// files into typed structs.
+//
+// Fields are described with struct tags:
+//
+//	Port    int           `env:"PORT" default:"8080"`
+//	DBPass  string        `env:"DB_PASSWORD" required:"true" secret:"true"`
+//	Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+//
+// Nested structs without an env tag are walked recursively.
+// Lookup order for a key: environment (including .env), then a file named after
+// the key inside one of the mounted directories, then the default. A key set in the
+// environment shadows its mounted file, whose changes a Watcher then never sees: it
+// warns about those keys (Loader.Shadowed).
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/joho/godotenv/autoload"
+)
+
+// DirEnv names the env variable listing the mounted ConfigMap/Secret directories (comma separated)
+const DirEnv = "CONFIG_DIR"
+
+// FieldError describes a single missing or invalid key
+type FieldError struct {
+	Key    string
+	Reason string
+}
+
+func (fe FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", fe.Key, fe.Reason)
+}
+
+// Error lists every problem found while loading a config struct
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+	sb.WriteString("invalid configuration:")
+	for _, fe := range e.Fields {
+		sb.WriteString("\n  - ")
+		sb.WriteString(fe.Error())
+	}
+	return sb.String()
+}
+
+// Loader reads configuration values from the environment and from mounted directories
+type Loader struct {
+	Dirs   []string                        // directories holding one file per key (ConfigMap volume layout)
+	Lookup func(key string) (string, bool) // defaults to os.LookupEnv
+}
+
+// NewLoader returns a Loader reading the environment and the directories listed in CONFIG_DIR
+func NewLoader() *Loader {
+	var dirs []string
+	for _, dir := range strings.Split(os.Getenv(DirEnv), ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return &Loader{
+		Dirs:   dirs,
+		Lookup: os.LookupEnv,
+	}
+}
+
+// Load fills dst (pointer to struct) using a default Loader
+func Load(dst any) error {
+	return NewLoader().Load(dst)
+}
+
+// Defaults fills dst (pointer to struct) from the default tags only, ignoring the
+// environment and the mounted directories
+func Defaults(dst any) error {
+	return (&Loader{Lookup: func(string) (string, bool) { return "", false }}).Load(dst)
+}
+
+// Load fills dst (pointer to struct). Every missing or invalid key is reported in a single *Error.
+func (l *Loader) Load(dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("config: Load expects a pointer to a struct")
+	}
+
+	var cfgErr Error
+	l.loadStruct(rv.Elem(), &cfgErr)
+	if len(cfgErr.Fields) > 0 {
+		return &cfgErr
+	}
+	return nil
+}
+
+func (l *Loader) loadStruct(v reflect.Value, cfgErr *Error) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key, ok := field.Tag.Lookup("env")
+		if !ok {
+			if field.Type.Kind() == reflect.Struct && !isLeafType(field.Type) {
+				l.loadStruct(v.Field(i), cfgErr)
+			}
+			continue
+		}
+
+		raw, found := l.lookup(key)
+		if !found {
+			raw, found = field.Tag.Lookup("default")
+		}
+		if !found {
+			if field.Tag.Get("required") == "true" {
+				cfgErr.Fields = append(cfgErr.Fields, FieldError{Key: key, Reason: "required but not set"})
+			}
+			continue
+		}
+
+		if err := setValue(v.Field(i), raw); err != nil {
+			reason := err.Error()
+			if field.Tag.Get("secret") == "true" {
+				// parse errors quote the raw value
+				reason = fmt.Sprintf("invalid %s value %s", field.Type, redacted)
+			}
+			cfgErr.Fields = append(cfgErr.Fields, FieldError{Key: key, Reason: reason})
+		}
+	}
+}
+
+func (l *Loader) lookup(key string) (string, bool) {
+	if val, ok := l.env(key); ok {
+		return val, true
+	}
+
+	for _, dir := range l.Dirs {
+		content, err := os.ReadFile(filepath.Join(dir, key))
+		if err == nil {
+			return strings.TrimSpace(string(content)), true
+		}
+	}
+	return "", false
+}
+
+// env returns the non empty environment value of key
+func (l *Loader) env(key string) (string, bool) {
+	lookup := l.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	if val, ok := lookup(key); ok && val != "" {
+		return val, true
+	}
+	return "", false
+}
+
+// Shadowed returns the keys of dst (pointer to struct) set in the environment while a file
+// of the mounted directories holds them too. The environment wins, the file is ignored.
+func (l *Loader) Shadowed(dst any) []string {
+	t := reflect.TypeOf(dst)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	var shadowed []string
+	walkKeys(t.Elem(), func(key string) {
+		if _, ok := l.env(key); !ok {
+			return
+		}
+		for _, dir := range l.Dirs {
+			if _, err := os.Stat(filepath.Join(dir, key)); err == nil {
+				shadowed = append(shadowed, key)
+				return
+			}
+		}
+	})
+	return shadowed
+}
+
+// walkKeys calls fn with the env key of every field of t, in the order Load reads them
+func walkKeys(t reflect.Type, fn func(key string)) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key, ok := field.Tag.Lookup("env")
+		if !ok {
+			if field.Type.Kind() == reflect.Struct && !isLeafType(field.Type) {
+				walkKeys(field.Type, fn)
+			}
+			continue
+		}
+		fn(key)
+	}
+}
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	urlType      = reflect.TypeFor[url.URL]()
+	textType     = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// isLeafType reports whether a struct type is parsed as a single value instead of walked
+func isLeafType(t reflect.Type) bool {
+	return t == urlType || reflect.PointerTo(t).Implements(textType)
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setValue(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	if v.Type() == urlType {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("invalid url %q: %v", raw, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid url %q: scheme and host are required", raw)
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(raw))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(raw, ",")
+		slice := reflect.MakeSlice(v.Type(), 0, len(parts))
+		for _, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(elem, part); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"common/logging"
+	"common/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const redacted = "******"
+
+// KeyValue is a single effective configuration entry
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Print writes the effective configuration as KEY=value lines, fields tagged secret:"true" are redacted
+func Print(w io.Writer, cfg any) error {
+	values, err := Values(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, kv := range values {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", kv.Key, kv.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintLoaded writes cfg like Print, then one "# KEY: reason" comment per key that is
+// missing or invalid in loadErr, so the output is still useful when Load failed.
+// It returns the write error or else loadErr.
+func PrintLoaded(w io.Writer, cfg any, loadErr error) error {
+	if err := Print(w, cfg); err != nil {
+		return err
+	}
+	if loadErr == nil {
+		return nil
+	}
+
+	var cfgErr *Error
+	if !errors.As(loadErr, &cfgErr) {
+		if _, err := fmt.Fprintf(w, "# %v\n", loadErr); err != nil {
+			return err
+		}
+		return loadErr
+	}
+	for _, fe := range cfgErr.Fields {
+		if _, err := fmt.Fprintf(w, "# %s\n", fe.Error()); err != nil {
+			return err
+		}
+	}
+	return loadErr
+}
+
+// Handler serves the effective (redacted) configuration of a config loaded once,
+// Watcher.StatusHandler is the counterpart for reloadable configs
+func Handler(cfg any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values, err := Values(cfg)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("could not read the configuration", "error", err)
+			utils.InternalServerError(w, r)
+			return
+		}
+		utils.OK(w, utils.Envelope{"config": utils.Envelope{"values": values}})
+	}
+}
+
+// Values returns the effective configuration in field order, fields tagged secret:"true" are redacted
+func Values(cfg any) ([]KeyValue, error) {
+	rv := reflect.ValueOf(cfg)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: expected a struct, got %s", rv.Kind())
+	}
+	return values(rv), nil
+}
+
+func values(v reflect.Value) []KeyValue {
+	var res []KeyValue
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key, ok := field.Tag.Lookup("env")
+		if !ok {
+			if field.Type.Kind() == reflect.Struct && !isLeafType(field.Type) {
+				res = append(res, values(v.Field(i))...)
+			}
+			continue
+		}
+
+		val := format(v.Field(i))
+		if field.Tag.Get("secret") == "true" && val != "" {
+			val = redacted
+		}
+		res = append(res, KeyValue{Key: key, Value: val})
+	}
+	return res
+}
+
+func format(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch val := v.Interface().(type) {
+	case url.URL:
+		return val.Redacted()
+	case fmt.Stringer:
+		return val.String()
+	}
+
+	if v.Kind() == reflect.Slice {
+		parts := make([]string, v.Len())
+		for i := range v.Len() {
+			parts[i] = format(v.Index(i))
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"common/utils"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// debounce coalesces the burst of events produced by a single ConfigMap update
+const debounce = 200 * time.Millisecond
+
+// Snapshot is an immutable loaded configuration
+type Snapshot[T any] struct {
+	Config   T
+	Revision uint64
+	LoadedAt time.Time
+}
+
+// Watcher reloads T whenever one of the watched files changes and swaps the
+// current snapshot atomically. It is meant to run as a boot component.
+type Watcher[T any] struct {
+	loader   *Loader
+	current  atomic.Pointer[Snapshot[T]]
+	debounce time.Duration
+
+	mu          sync.Mutex
+	paths       []string
+	subscribers []func(Snapshot[T])
+	lastErr     error
+	lastErrAt   time.Time
+	shadowed    []string // keys of the environment hiding a mounted file, see Loader.Shadowed
+}
+
+// NewWatcher loads the initial snapshot (revision 1) and watches the loader directories
+func NewWatcher[T any](loader *Loader) (*Watcher[T], error) {
+	w := &Watcher[T]{
+		loader:   loader,
+		debounce: debounce,
+		paths:    slices.Clone(loader.Dirs),
+	}
+
+	var cfg T
+	if err := loader.Load(&cfg); err != nil {
+		return nil, err
+	}
+	w.current.Store(&Snapshot[T]{Config: cfg, Revision: 1, LoadedAt: time.Now()})
+	w.checkShadowed()
+	return w, nil
+}
+
+// checkShadowed warns once about every key whose mounted file is hidden by the environment,
+// editing the ConfigMap does not change them
+func (w *Watcher[T]) checkShadowed() {
+	var cfg T
+	shadowed := w.loader.Shadowed(&cfg)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, key := range shadowed {
+		if !slices.Contains(w.shadowed, key) {
+			slog.Warn("config key set in the environment shadows its mounted file, changes to the file are not reloaded", "key", key)
+		}
+	}
+	w.shadowed = shadowed
+}
+
+// Current returns the latest successfully loaded snapshot
+func (w *Watcher[T]) Current() *Snapshot[T] {
+	return w.current.Load()
+}
+
+// Watch adds files (or directories) whose changes trigger a reload, must be called before Run
+func (w *Watcher[T]) Watch(paths ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paths = append(w.paths, paths...)
+}
+
+// Subscribe registers fn, called with every new snapshot after a successful reload
+func (w *Watcher[T]) Subscribe(fn func(Snapshot[T])) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Reload loads the configuration again. On error the current snapshot is kept.
+func (w *Watcher[T]) Reload() error {
+	var cfg T
+	if err := w.loader.Load(&cfg); err != nil {
+		w.mu.Lock()
+		w.lastErr = err
+		w.lastErrAt = time.Now()
+		w.mu.Unlock()
+		return err
+	}
+
+	prev := w.current.Load()
+	snap := &Snapshot[T]{Config: cfg, Revision: prev.Revision + 1, LoadedAt: time.Now()}
+	w.current.Store(snap)
+
+	w.mu.Lock()
+	w.lastErr = nil
+	subscribers := slices.Clone(w.subscribers)
+	w.mu.Unlock()
+
+	w.checkShadowed()
+	for _, fn := range subscribers {
+		fn(*snap)
+	}
+	return nil
+}
+
+// Run watches the parent directories of the watched paths until ctx is cancelled.
+// Watching directories instead of files is what makes the Kubernetes "..data"
+// symlink swap visible: the files themselves are never written, the symlink is replaced.
+func (w *Watcher[T]) Run(ctx context.Context) error {
+	fsw, targets, err := w.watch()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+	return w.loop(ctx, fsw, targets)
+}
+
+// watch adds the directories of the watched paths to a new fsnotify watcher and returns,
+// per directory, the base names to react on ("" means any entry of the directory)
+func (w *Watcher[T]) watch() (*fsnotify.Watcher, map[string]map[string]bool, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create file watcher: %w", err)
+	}
+
+	w.mu.Lock()
+	paths := slices.Clone(w.paths)
+	w.mu.Unlock()
+
+	targets := make(map[string]map[string]bool)
+	for _, path := range paths {
+		dir, name := filepath.Dir(path), filepath.Base(path)
+		if isDir(path) {
+			dir, name = path, ""
+		}
+		if targets[dir] == nil {
+			targets[dir] = make(map[string]bool)
+			if err := fsw.Add(dir); err != nil {
+				fsw.Close()
+				return nil, nil, fmt.Errorf("could not watch %s: %w", dir, err)
+			}
+		}
+		targets[dir][name] = true
+	}
+	return fsw, targets, nil
+}
+
+// loop reloads once no relevant event came for the debounce delay, until ctx is cancelled
+func (w *Watcher[T]) loop(ctx context.Context, fsw *fsnotify.Watcher, targets map[string]map[string]bool) error {
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-fsw.Errors:
+			slog.Warn("config watcher error", "error", err)
+		case ev := <-fsw.Events:
+			if relevant(targets, ev.Name) {
+				timer = time.After(w.debounce)
+			}
+		case <-timer:
+			timer = nil
+			if err := w.Reload(); err != nil {
+				slog.Error("config reload failed", "revision", w.Current().Revision, "error", err)
+				continue
+			}
+			slog.Info("config reloaded", "revision", w.Current().Revision)
+		}
+	}
+}
+
+func relevant(targets map[string]map[string]bool, name string) bool {
+	names, ok := targets[filepath.Dir(name)]
+	if !ok {
+		return false
+	}
+	base := filepath.Base(name)
+	return names[""] || names[base] || base == "..data"
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Status describes the watcher state, served by StatusHandler
+type Status struct {
+	Revision  uint64     `json:"revision"`
+	LoadedAt  time.Time  `json:"loaded_at"`
+	LastError string     `json:"last_error,omitempty"`
+	ErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	Shadowed  []string   `json:"shadowed,omitempty"` // keys of the environment hiding a mounted file
+	Values    []KeyValue `json:"values"`
+}
+
+func (w *Watcher[T]) Status() Status {
+	snap := w.Current()
+	values, _ := Values(snap.Config)
+	status := Status{
+		Revision: snap.Revision,
+		LoadedAt: snap.LoadedAt,
+		Values:   values,
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status.Shadowed = slices.Clone(w.shadowed)
+	if w.lastErr != nil {
+		errAt := w.lastErrAt
+		status.LastError = w.lastErr.Error()
+		status.ErrorAt = &errAt
+	}
+	return status
+}
+
+// StatusHandler serves the current revision, last reload error and effective (redacted) config
+func (w *Watcher[T]) StatusHandler(rw http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(rw, http.StatusOK, utils.Envelope{"config": w.Status()})
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+type watchedConfig struct {
+	Interval time.Duration `env:"INTERVAL" default:"5s"`
+	Message  string        `env:"MESSAGE" default:"hello"`
+}
+
+// configMapDir lays out a directory the way the kubelet mounts a ConfigMap: every key is a
+// symlink through ..data to a timestamped directory. An update writes a new directory,
+// swaps ..data with a rename and removes the old one, the key files are never written.
+type configMapDir struct {
+	t       *testing.T
+	dir     string
+	current string
+	gen     int
+}
+
+func newConfigMapDir(t *testing.T, files map[string]string) *configMapDir {
+	c := &configMapDir{t: t, dir: t.TempDir()}
+	c.update(files)
+	return c
+}
+
+func (c *configMapDir) update(files map[string]string) {
+	c.t.Helper()
+	c.gen++
+	ts := fmt.Sprintf("..2026_10_18_12_00_00.%d", c.gen)
+	if err := os.Mkdir(filepath.Join(c.dir, ts), 0o755); err != nil {
+		c.t.Fatal(err)
+	}
+	for key, val := range files {
+		if err := os.WriteFile(filepath.Join(c.dir, ts, key), []byte(val), 0o644); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	tmp := filepath.Join(c.dir, "..data_tmp")
+	if err := os.Symlink(ts, tmp); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, "..data")); err != nil {
+		c.t.Fatal(err)
+	}
+	for key := range files {
+		link := filepath.Join(c.dir, key)
+		if _, err := os.Lstat(link); errors.Is(err, os.ErrNotExist) {
+			if err := os.Symlink(filepath.Join("..data", key), link); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+	}
+
+	if c.current != "" {
+		if err := os.RemoveAll(filepath.Join(c.dir, c.current)); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	c.current = ts
+}
+
+// runWatcher starts watching before returning, so no event of the test is missed
+func runWatcher[T any](t *testing.T, w *Watcher[T]) {
+	t.Helper()
+	fsw, targets, err := w.watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.loop(ctx, fsw, targets) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		fsw.Close()
+	})
+}
+
+func TestWatcherReloadsOnDataSwap(t *testing.T) {
+	cm := newConfigMapDir(t, map[string]string{"INTERVAL": "1s"})
+	w, err := NewWatcher[watchedConfig](&Loader{Dirs: []string{cm.dir}, Lookup: mapLookup(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap := w.Current(); snap.Revision != 1 || snap.Config.Interval != time.Second || snap.Config.Message != "hello" {
+		t.Fatalf("initial snapshot = %+v, want revision 1 with 1s", snap)
+	}
+
+	w.debounce = 100 * time.Millisecond
+	first, second := make(chan Snapshot[watchedConfig], 10), make(chan Snapshot[watchedConfig], 10)
+	w.Subscribe(func(s Snapshot[watchedConfig]) { first <- s })
+	w.Subscribe(func(s Snapshot[watchedConfig]) { second <- s })
+	runWatcher(t, w)
+
+	// a burst of updates is a single reload
+	cm.update(map[string]string{"INTERVAL": "2s"})
+	cm.update(map[string]string{"INTERVAL": "3s", "MESSAGE": "hi"})
+	for name, ch := range map[string]chan Snapshot[watchedConfig]{"first": first, "second": second} {
+		select {
+		case snap := <-ch:
+			if snap.Revision != 2 || snap.Config.Interval != 3*time.Second || snap.Config.Message != "hi" {
+				t.Fatalf("%s subscriber got %+v, want revision 2 with 3s and hi", name, snap)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s subscriber not called after the ..data swap", name)
+		}
+	}
+	select {
+	case snap := <-first:
+		t.Fatalf("second reload for the same burst: %+v", snap)
+	case <-time.After(3 * w.debounce):
+	}
+	if got := w.Current(); got.Revision != 2 || got.Config.Interval != 3*time.Second {
+		t.Fatalf("Current = %+v, want revision 2", got)
+	}
+}
+
+func TestWatcherIgnoresOtherFiles(t *testing.T) {
+	cm := newConfigMapDir(t, map[string]string{"INTERVAL": "1s"})
+	other := t.TempDir()
+	w, err := NewWatcher[watchedConfig](&Loader{Dirs: []string{cm.dir}, Lookup: mapLookup(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.debounce = 10 * time.Millisecond
+	w.Watch(filepath.Join(other, "watched.txt"))
+	reloads := make(chan Snapshot[watchedConfig], 10)
+	w.Subscribe(func(s Snapshot[watchedConfig]) { reloads <- s })
+	runWatcher(t, w)
+
+	if err := os.WriteFile(filepath.Join(other, "unrelated.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case snap := <-reloads:
+		t.Fatalf("reloaded on an unwatched file: %+v", snap)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(filepath.Join(other, "watched.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case snap := <-reloads:
+		if snap.Revision != 2 {
+			t.Fatalf("reload of the watched file = revision %d, want 2", snap.Revision)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the watched file changed")
+	}
+}
+
+func TestWatcherFailedReloadKeepsSnapshot(t *testing.T) {
+	cm := newConfigMapDir(t, map[string]string{"INTERVAL": "1s"})
+	w, err := NewWatcher[watchedConfig](&Loader{Dirs: []string{cm.dir}, Lookup: mapLookup(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	w.Subscribe(func(Snapshot[watchedConfig]) { calls++ })
+
+	cm.update(map[string]string{"INTERVAL": "soon"})
+	if err := w.Reload(); err == nil {
+		t.Fatal("Reload of an invalid interval: expected an error")
+	}
+	if snap := w.Current(); snap.Revision != 1 || snap.Config.Interval != time.Second {
+		t.Fatalf("Current after a failed reload = %+v, want revision 1 with 1s", snap)
+	}
+	status := w.Status()
+	if status.Revision != 1 || status.LastError == "" || status.ErrorAt == nil {
+		t.Fatalf("Status after a failed reload = %+v, want revision 1 and the error", status)
+	}
+	if calls != 0 {
+		t.Fatalf("subscribers called %d times after a failed reload", calls)
+	}
+
+	cm.update(map[string]string{"INTERVAL": "2s"})
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	status = w.Status()
+	if status.Revision != 2 || status.LastError != "" || status.ErrorAt != nil || calls != 1 {
+		t.Fatalf("Status after the fix = %+v with %d calls, want revision 2 without error", status, calls)
+	}
+}
+
+func TestWatcherShadowedKeys(t *testing.T) {
+	cm := newConfigMapDir(t, map[string]string{"INTERVAL": "1s", "MESSAGE": "from-file"})
+	env := map[string]string{"INTERVAL": "9s", "MESSAGE": ""}
+	w, err := NewWatcher[watchedConfig](&Loader{Dirs: []string{cm.dir}, Lookup: mapLookup(env)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an empty environment value does not shadow the file
+	if status := w.Status(); !slices.Equal(status.Shadowed, []string{"INTERVAL"}) {
+		t.Fatalf("Status.Shadowed = %v, want [INTERVAL]", status.Shadowed)
+	}
+	cm.update(map[string]string{"INTERVAL": "2s", "MESSAGE": "updated"})
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := w.Current().Config; cfg.Interval != 9*time.Second || cfg.Message != "updated" {
+		t.Fatalf("Config after the update = %+v, want the environment interval and the new message", cfg)
+	}
+
+	if got := (&Loader{Lookup: mapLookup(env)}).Shadowed(&watchedConfig{}); got != nil {
+		t.Fatalf("Shadowed without directories = %v, want none", got)
+	}
+	if got := (&Loader{Dirs: []string{cm.dir}, Lookup: mapLookup(env)}).Shadowed(watchedConfig{}); got != nil {
+		t.Fatalf("Shadowed of a non pointer = %v, want none", got)
+	}
+}
+
+func TestRelevant(t *testing.T) {
+	targets := map[string]map[string]bool{
+		"/etc/config": {"": true},
+		"/etc/files":  {"watched.txt": true},
+	}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"/etc/config/INTERVAL", true},
+		{"/etc/config/..data", true},
+		{"/etc/files/watched.txt", true},
+		{"/etc/files/..data", true},
+		{"/etc/files/other.txt", false},
+		{"/etc/other/watched.txt", false},
+	}
+	for _, tt := range tests {
+		if got := relevant(targets, tt.name); got != tt.want {
+			t.Errorf("relevant(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	cm := newConfigMapDir(t, map[string]string{"INTERVAL": "1s"})
+	w, err := NewWatcher[watchedConfig](&Loader{Dirs: []string{cm.dir}, Lookup: mapLookup(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	w.StatusHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+
+	var body struct {
+		Config Status `json:"config"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || body.Config.Revision != 1 || len(body.Config.Values) != 2 {
+		t.Fatalf("StatusHandler = %d %+v, want revision 1 with two values", rec.Code, body.Config)
+	}
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// MigrateUsage documents the migrate subcommand of the service binaries
+const MigrateUsage = `usage: migrate <command>
+
+commands:
+  up        apply every pending migration
+  down      roll back the last applied migration
+  status    list the migrations and when they were applied
+  redo      roll back the last applied migration and apply it again
+  dry-run   print the pending migrations without applying them`
+
+// MigrateCommand implements "<service> migrate <command>": it connects with cfg, runs
+// command against the migrations of fsys and writes the outcome to out.
+func MigrateCommand(ctx context.Context, cfg Config, fsys fs.FS, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		fmt.Fprintln(out, MigrateUsage)
+		return errors.New("expected one migrate command")
+	}
+	command := args[0]
+	switch command {
+	case "up", "down", "status", "redo", "dry-run":
+	default:
+		fmt.Fprintln(out, MigrateUsage)
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+
+	s, err := Open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close(context.WithoutCancel(ctx))
+
+	m, err := NewMigrator(s, fsys)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "schema %s\n", m.schema)
+
+	switch command {
+	case "up":
+		results, err := m.Up(ctx)
+		for _, res := range results {
+			fmt.Fprintln(out, res)
+		}
+		if err == nil && len(results) == 0 {
+			fmt.Fprintln(out, "no pending migration")
+		}
+		return err
+
+	case "down":
+		res, err := m.Down(ctx)
+		if res != nil {
+			fmt.Fprintln(out, res)
+		}
+		return err
+
+	case "redo":
+		results, err := m.Redo(ctx)
+		for _, res := range results {
+			fmt.Fprintln(out, res)
+		}
+		return err
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%-25s %s\n", appliedAt, status.Source.Path)
+		}
+		return nil
+
+	default: // dry-run
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Fprintln(out, "no pending migration")
+			return nil
+		}
+		for _, src := range pending {
+			content, err := m.Source(src)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "-- pending %s\n%s\n", src.Path, content)
+		}
+		return nil
+	}
+}
@@ -1,80 +1,203 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"io/fs"
-	"os"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
-	"github.com/pressly/goose/v3"
 )
 
-var (
-	username = os.Getenv("DB_USERNAME")
-	password = os.Getenv("DB_PASSWORD")
-	host     = os.Getenv("DB_HOST")
-	port     = os.Getenv("DB_PORT")
-	dbName   = os.Getenv("DB_NAME")
-	// dbSchema   = os.Getenv("DB_SCHEMA")
-	dbInstance *DBService
+// startup retry backoff, doubled after each failed ping
+const (
+	initialRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 10 * time.Second
 )
 
+// Config holds the Postgres connection settings, bound with common/config. The credentials are
+// checked by Validate rather than required by the loader, services may run without a database.
+type Config struct {
+	Username string `env:"DB_USERNAME"`
+	Password string `env:"DB_PASSWORD" secret:"true"`
+	Host     string `env:"DB_HOST" default:"localhost"`
+	Port     int    `env:"DB_PORT" default:"5432"`
+	Name     string `env:"DB_NAME" default:"postgres"`
+	// Schema owned by the service, set as the search_path of every connection (see Migrator)
+	Schema string `env:"DB_SCHEMA"`
+
+	// SSLMode is a libpq sslmode: disable, allow, prefer, require, verify-ca or verify-full
+	SSLMode     string `env:"DB_SSLMODE" default:"disable"`
+	SSLRootCert string `env:"DB_SSLROOTCERT"` // CA bundle for verify-ca / verify-full
+	SSLCert     string `env:"DB_SSLCERT"`     // client certificate, when the server requires one
+	SSLKey      string `env:"DB_SSLKEY"`
+
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" default:"10"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" default:"5"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" default:"5m"`
+
+	ConnectTimeout time.Duration `env:"DB_CONNECT_TIMEOUT" default:"5s"`  // per connection attempt
+	StartupTimeout time.Duration `env:"DB_STARTUP_TIMEOUT" default:"60s"` // total retry budget of Connect
+}
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// dsn returns the connection url, redacted when redact is set (for logs)
+func (c Config) dsn(redact bool) string {
+	q := url.Values{}
+	q.Set("sslmode", c.SSLMode)
+	if c.Schema != "" {
+		q.Set("search_path", c.Schema)
+	}
+	if c.SSLRootCert != "" {
+		q.Set("sslrootcert", c.SSLRootCert)
+	}
+	if c.SSLCert != "" {
+		q.Set("sslcert", c.SSLCert)
+		q.Set("sslkey", c.SSLKey)
+	}
+	if c.ConnectTimeout > 0 {
+		q.Set("connect_timeout", strconv.Itoa(max(1, int(c.ConnectTimeout.Seconds()))))
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.Username, c.Password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:     "/" + c.Name,
+		RawQuery: q.Encode(),
+	}
+	if redact {
+		return u.Redacted()
+	}
+	return u.String()
+}
+
+// Validate requires the credentials, call it when the service runs with the database. New only
+// requires the username: the tests connect to a local server trusting every user.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Username == "" {
+		errs = append(errs, errors.New("DB_USERNAME is required"))
+	}
+	if c.Password == "" {
+		errs = append(errs, errors.New("DB_PASSWORD is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// RedactedDSN is the connection url with the password masked
+func (c Config) RedactedDSN() string {
+	return c.dsn(true)
+}
+
 type DBService struct {
-	DB *sql.DB
+	DB  *sql.DB
+	cfg Config
 }
 
 type DatabaseService interface {
-	Open() (*DBService, error)
+	Open(ctx context.Context, cfg Config) (*DBService, error)
 }
 
-func Open() (*DBService, error) {
-	if dbInstance != nil {
-		return dbInstance, nil
+// New builds the pool from cfg without connecting, see Connect
+func New(cfg Config) (*DBService, error) {
+	if cfg.Username == "" {
+		return nil, errors.New("DB_USERNAME is required")
+	}
+	if !sslModes[cfg.SSLMode] {
+		return nil, fmt.Errorf("unknown DB_SSLMODE %q", cfg.SSLMode)
+	}
+	if (cfg.SSLCert == "") != (cfg.SSLKey == "") {
+		return nil, errors.New("DB_SSLCERT and DB_SSLKEY must be set together")
 	}
 
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", username, password, host, port, dbName)
-	fmt.Println(connStr)
-	db, err := sql.Open("pgx", connStr)
+	db, err := sql.Open("pgx", cfg.dsn(false))
 	if err != nil {
-		return nil, fmt.Errorf("could not connect to db: %v", err)
+		return nil, fmt.Errorf("could not open db: %w", err)
 	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	err = db.Ping()
+	return &DBService{
+		DB:  db,
+		cfg: cfg,
+	}, nil
+}
+
+// Open builds the pool and waits for the database to answer, see Connect
+func Open(ctx context.Context, cfg Config) (*DBService, error) {
+	s, err := New(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("could not reach db connection: %v", err)
+		return nil, err
 	}
-
-	dbInstance = &DBService{
-		DB: db,
+	if err := s.Connect(ctx); err != nil {
+		s.DB.Close()
+		return nil, err
 	}
-
-	return dbInstance, nil
+	return s, nil
 }
 
-func MigrateFS(dbService *DBService, migrationFS fs.FS, dir string) error {
-	_, err := dbService.DB.Exec("CREATE SCHEMA IF NOT EXISTS pingpong_sc")
-	if err != nil {
-		return fmt.Errorf("could not create schema: %w", err)
+// Connect pings the database until it answers, with exponential backoff, giving up
+// after the configured StartupTimeout or when ctx is done. Postgres may start after us.
+func (s *DBService) Connect(ctx context.Context) error {
+	if s.cfg.StartupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.StartupTimeout)
+		defer cancel()
 	}
 
-	goose.SetBaseFS(migrationFS)
-	goose.SetTableName("pingpong_sc.goose_db_version")
-	defer func() {
-		goose.SetBaseFS(nil)
-	}()
-	return Migrate(dbService.DB, dir)
+	slog.Info("connecting to database", "dsn", s.cfg.RedactedDSN())
+	backoff := initialRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.Ping(ctx)
+		if err == nil {
+			slog.Info("database connected", "attempts", attempt)
+			return nil
+		}
+		slog.Warn("database not reachable, retrying", "attempt", attempt, "retry_in", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("could not reach db after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
 }
 
-func Migrate(db *sql.DB, dir string) error {
-	err := goose.SetDialect("postgres")
-	if err != nil {
-		return fmt.Errorf("error with goose dialect setup: %w", err)
+// Ping checks the database answers, bounded by ConnectTimeout
+func (s *DBService) Ping(ctx context.Context) error {
+	if s.cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.ConnectTimeout)
+		defer cancel()
 	}
+	return s.DB.PingContext(ctx)
+}
 
-	err = goose.Up(db, dir)
-	if err != nil {
-		return fmt.Errorf("could not run gooseUp: %w", err)
+// Close closes the pool. sql.DB.Close waits for the queries in flight, Close gives up
+// waiting when ctx is done (the pool is still closed once they finish).
+func (s *DBService) Close(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.DB.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("could not close db: %w", ctx.Err())
 	}
-	return nil
 }
@@ -0,0 +1,25 @@
+package db
+
+import "testing"
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  bool
+	}{
+		{"credentials", "ping_pong", "hunter2", false},
+		{"no password", "ping_pong", "", true},
+		{"no username", "", "hunter2", true},
+		{"none", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Config{Username: tt.username, Password: tt.password}.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
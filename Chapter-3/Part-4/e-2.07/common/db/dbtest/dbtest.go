@@ -0,0 +1,296 @@
+// Package dbtest runs store tests against a real Postgres without containers.
+//
+// The server is, in order of preference:
+//   - the one at DBTEST_DATABASE_URL (e.g. the docker-compose database),
+//   - a throwaway instance of the local Postgres binaries (initdb and postgres found in
+//     DBTEST_POSTGRES_BIN, the PATH or /usr/lib/postgresql/*/bin) in a temp dir.
+//
+// Tests are skipped when neither is available. Every call to New hands the test its own
+// schema with the service migrations applied, dropped at the end of the test.
+//
+//	func TestMain(m *testing.M) { os.Exit(dbtest.Main(m)) }
+//
+//	func TestStore(t *testing.T) {
+//		s := dbtest.New(t, migrations.FS)
+//		...
+//	}
+package dbtest
+
+import (
+	"common/db"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	URLEnv = "DBTEST_DATABASE_URL"
+	BinEnv = "DBTEST_POSTGRES_BIN"
+)
+
+// Server is a Postgres reachable by the tests
+type Server struct {
+	Config db.Config
+
+	cmd *exec.Cmd // local instance started by dbtest, nil for DBTEST_DATABASE_URL
+	dir string
+}
+
+var (
+	sharedOnce   sync.Once
+	shared       *Server
+	sharedErr    error
+	sharedCancel = func() {}
+)
+
+// Main runs the tests of a package and stops the shared server afterwards
+func Main(m *testing.M) int {
+	code := m.Run()
+	if shared != nil {
+		if err := shared.Stop(); err != nil {
+			fmt.Fprintln(os.Stderr, "dbtest:", err)
+		}
+	}
+	sharedCancel()
+	return code
+}
+
+// sharedServer starts the package server on first use
+func sharedServer() (*Server, error) {
+	sharedOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		sharedCancel = cancel
+		shared, sharedErr = Start(ctx)
+	})
+	return shared, sharedErr
+}
+
+// ErrUnavailable is returned by Start when there is no server to run the tests against
+var ErrUnavailable = errors.New("dbtest: no Postgres available, set " + URLEnv + " or install the postgres binaries")
+
+// Start returns the DBTEST_DATABASE_URL server, or starts a local instance
+func Start(ctx context.Context) (*Server, error) {
+	if raw := os.Getenv(URLEnv); raw != "" {
+		cfg, err := configFromURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		srv := &Server{Config: cfg}
+		if err := srv.check(ctx); err != nil {
+			return nil, err
+		}
+		return srv, nil
+	}
+	return startLocal(ctx)
+}
+
+func configFromURL(raw string) (db.Config, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return db.Config{}, fmt.Errorf("dbtest: invalid %s: %w", URLEnv, err)
+	}
+	port := 5432
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return db.Config{}, fmt.Errorf("dbtest: invalid port in %s: %w", URLEnv, err)
+		}
+	}
+	password, _ := u.User.Password()
+	cfg := testConfig()
+	cfg.Username = u.User.Username()
+	cfg.Password = password
+	cfg.Host = u.Hostname()
+	cfg.Port = port
+	if name := u.Path; len(name) > 1 {
+		cfg.Name = name[1:]
+	}
+	if mode := u.Query().Get("sslmode"); mode != "" {
+		cfg.SSLMode = mode
+	}
+	return cfg, nil
+}
+
+// testConfig holds the defaults common/config would set
+func testConfig() db.Config {
+	return db.Config{
+		Name:           "postgres",
+		SSLMode:        "disable",
+		MaxOpenConns:   5,
+		MaxIdleConns:   2,
+		ConnectTimeout: 5 * time.Second,
+		StartupTimeout: 30 * time.Second,
+	}
+}
+
+func findBinaries() (initdb, postgres string, ok bool) {
+	dirs := []string{os.Getenv(BinEnv)}
+	if matches, _ := filepath.Glob("/usr/lib/postgresql/*/bin"); len(matches) > 0 {
+		dirs = append(dirs, matches[len(matches)-1])
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		initdb, postgres = filepath.Join(dir, "initdb"), filepath.Join(dir, "postgres")
+		if _, err := os.Stat(postgres); err == nil {
+			return initdb, postgres, true
+		}
+	}
+
+	initdb, errInit := exec.LookPath("initdb")
+	postgres, errPg := exec.LookPath("postgres")
+	return initdb, postgres, errInit == nil && errPg == nil
+}
+
+// startLocal runs initdb and postgres in a temp dir on a free port, trusting local connections
+func startLocal(ctx context.Context) (*Server, error) {
+	initdb, postgres, ok := findBinaries()
+	if !ok {
+		return nil, ErrUnavailable
+	}
+	if os.Geteuid() == 0 {
+		return nil, fmt.Errorf("%w (postgres refuses to run as root)", ErrUnavailable)
+	}
+
+	dir, err := os.MkdirTemp("", "dbtest-")
+	if err != nil {
+		return nil, err
+	}
+	data := filepath.Join(dir, "data")
+	out, err := exec.CommandContext(ctx, initdb, "-D", data, "-U", "postgres", "--auth=trust", "--no-sync", "-E", "UTF8").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("dbtest: initdb: %w\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	cmd := exec.Command(postgres, "-D", data, "-p", strconv.Itoa(port), "-k", dir,
+		"-c", "listen_addresses=127.0.0.1", "-c", "fsync=off", "-c", "synchronous_commit=off", "-c", "full_page_writes=off")
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("dbtest: starting postgres: %w", err)
+	}
+
+	cfg := testConfig()
+	cfg.Username = "postgres"
+	cfg.Host = "127.0.0.1"
+	cfg.Port = port
+	srv := &Server{Config: cfg, cmd: cmd, dir: dir}
+	if err := srv.check(ctx); err != nil {
+		srv.Stop()
+		return nil, err
+	}
+	return srv, nil
+}
+
+// check waits for the server to accept connections, within Config.StartupTimeout
+func (s *Server) check(ctx context.Context) error {
+	conn, err := db.Open(ctx, s.Config)
+	if err != nil {
+		return fmt.Errorf("dbtest: %w", err)
+	}
+	return conn.Close(ctx)
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Stop shuts a local instance down and removes its data, it is a no-op for DBTEST_DATABASE_URL
+func (s *Server) Stop() error {
+	if s.cmd == nil {
+		return nil
+	}
+	defer os.RemoveAll(s.dir)
+
+	// SIGINT is the postgres "fast" shutdown
+	if err := s.cmd.Process.Signal(syscall.SIGINT); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.cmd.Wait() }()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(10 * time.Second):
+		s.cmd.Process.Kill()
+		return errors.New("dbtest: postgres did not stop in time, killed")
+	}
+}
+
+// New returns a DBService bound to a fresh schema of the shared server with the migrations of fsys
+// applied. The schema is dropped when the test ends. The test is skipped when no server is available.
+func New(t testing.TB, fsys fs.FS) *db.DBService {
+	t.Helper()
+
+	srv, err := sharedServer()
+	if errors.Is(err, ErrUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv.NewSchema(t, fsys)
+}
+
+// NewSchema is New on a given server
+func (s *Server) NewSchema(t testing.TB, fsys fs.FS) *db.DBService {
+	t.Helper()
+	ctx := context.Background()
+
+	cfg := s.Config
+	cfg.Schema = "test_" + randomSuffix()
+	svc, err := db.Open(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if _, err := svc.DB.ExecContext(ctx, "DROP SCHEMA IF EXISTS "+pgx.Identifier{cfg.Schema}.Sanitize()+" CASCADE"); err != nil {
+			t.Errorf("dbtest: dropping schema %s: %v", cfg.Schema, err)
+		}
+		svc.Close(ctx)
+	})
+
+	if fsys != nil {
+		migrator, err := db.NewMigrator(svc, fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrator.Up(ctx); err != nil {
+			t.Fatalf("dbtest: migrations: %v", err)
+		}
+	}
+	return svc
+}
+
+func randomSuffix() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pressly/goose/v3"
+)
+
+// DefaultSchema is used when DB_SCHEMA is empty and the service sets no default
+const DefaultSchema = "public"
+
+var schemaPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Migrator runs the goose migrations of one service inside its own schema: the version table
+// is <schema>.goose_db_version and the unqualified names of the migrations resolve to the schema
+// through the search_path set on the connections (DB_SCHEMA).
+// Up, Down, Redo and Status hold a Postgres advisory lock for their whole run, so replicas starting
+// together migrate one at a time and nothing runs between the two steps of a Redo.
+type Migrator struct {
+	db       *DBService
+	fsys     fs.FS
+	schema   string
+	lockID   int64
+	provider *goose.Provider
+}
+
+// NewMigrator reads the migrations at the root of fsys (e.g. an embed.FS of *.sql files)
+func NewMigrator(db *DBService, fsys fs.FS) (*Migrator, error) {
+	schema := db.Schema()
+	if !schemaPattern.MatchString(schema) {
+		return nil, fmt.Errorf("invalid schema name %q", schema)
+	}
+
+	// no goose session locker, it would only cover a single provider call: withLock holds the lock
+	provider, err := goose.NewProvider(goose.DialectPostgres, db.DB, fsys,
+		goose.WithTableName(schema+".goose_db_version"),
+		goose.WithDisableGlobalRegistry(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not load migrations: %w", err)
+	}
+
+	return &Migrator{
+		db:       db,
+		fsys:     fsys,
+		schema:   schema,
+		lockID:   lockID(schema),
+		provider: provider,
+	}, nil
+}
+
+// lockID derives the advisory lock key from the schema, services sharing a database do not wait on each other
+func lockID(schema string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("goose:" + schema))
+	return int64(h.Sum64())
+}
+
+// withLock runs fn holding the migration advisory lock of the schema, on a connection of its own
+// so the lock is released if the process dies. The schema is created first.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	conn, err := m.db.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("could not acquire migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", m.lockID); err != nil {
+		return fmt.Errorf("could not acquire migration lock: %w", err)
+	}
+	defer func() {
+		// a failed unlock is released with the session, drop the connection instead of pooling it
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", m.lockID); err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	// CREATE SCHEMA IF NOT EXISTS is not safe against concurrent callers, hence under the lock
+	if _, err := conn.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{m.schema}.Sanitize()); err != nil {
+		return fmt.Errorf("could not create schema %s: %w", m.schema, err)
+	}
+	return fn()
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) (results []*goose.MigrationResult, err error) {
+	err = m.withLock(ctx, func() error {
+		results, err = m.provider.Up(ctx)
+		return err
+	})
+	return results, err
+}
+
+// Down rolls back the last applied migration
+func (m *Migrator) Down(ctx context.Context) (result *goose.MigrationResult, err error) {
+	err = m.withLock(ctx, func() error {
+		result, err = m.provider.Down(ctx)
+		return err
+	})
+	return result, err
+}
+
+// Redo rolls back the last applied migration and applies it again, both under the same lock
+func (m *Migrator) Redo(ctx context.Context) (results []*goose.MigrationResult, err error) {
+	err = m.withLock(ctx, func() error {
+		current, err := m.provider.GetDBVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return fmt.Errorf("no migration applied in schema %s", m.schema)
+		}
+
+		down, err := m.provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		results = append(results, down)
+		up, err := m.provider.ApplyVersion(ctx, current, true)
+		if err != nil {
+			return err
+		}
+		results = append(results, up)
+		return nil
+	})
+	return results, err
+}
+
+// Status lists every migration with its state
+func (m *Migrator) Status(ctx context.Context) (statuses []*goose.MigrationStatus, err error) {
+	err = m.withLock(ctx, func() error {
+		statuses, err = m.provider.Status(ctx)
+		return err
+	})
+	return statuses, err
+}
+
+// Pending lists the migrations Up would apply
+func (m *Migrator) Pending(ctx context.Context) ([]*goose.Source, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*goose.Source
+	for _, status := range statuses {
+		if status.State == goose.StatePending {
+			pending = append(pending, status.Source)
+		}
+	}
+	return pending, nil
+}
+
+// CheckVersion fails when the schema is behind the newest migration embedded in the binary,
+// for services started without migrating (the migrations run in a Job or init container).
+// A schema ahead of the binary is accepted, it happens during rollouts of a newer release.
+func (m *Migrator) CheckVersion(ctx context.Context) error {
+	current, target, err := m.provider.GetVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read schema version: %w", err)
+	}
+	if current < target {
+		return fmt.Errorf("schema %s is at version %d, this binary expects %d: migrations have not been applied", m.schema, current, target)
+	}
+	return nil
+}
+
+// Source returns the content of a migration file
+func (m *Migrator) Source(src *goose.Source) ([]byte, error) {
+	return fs.ReadFile(m.fsys, src.Path)
+}
+
+// Schema is the schema owned by the service, DefaultSchema when DB_SCHEMA is empty
+func (s *DBService) Schema() string {
+	if s.cfg.Schema == "" {
+		return DefaultSchema
+	}
+	return s.cfg.Schema
+}
@@ -0,0 +1,157 @@
+package db_test
+
+import (
+	"common/db"
+	"common/db/dbtest"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMain(m *testing.M) { os.Exit(dbtest.Main(m)) }
+
+var testMigrations = fstest.MapFS{
+	"00001_items.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+-- +goose Down
+DROP TABLE items;
+`)},
+	"00002_items_tag.sql": {Data: []byte(`-- +goose Up
+ALTER TABLE items ADD COLUMN tag TEXT;
+-- +goose Down
+ALTER TABLE items DROP COLUMN tag;
+`)},
+}
+
+func TestMigrator(t *testing.T) {
+	s := dbtest.New(t, nil)
+	ctx := context.Background()
+
+	m, err := db.NewMigrator(s, testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CheckVersion(ctx); err == nil {
+		t.Fatal("CheckVersion before Up: expected an error")
+	}
+
+	results, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Up applied %d migrations, want 2", len(results))
+	}
+	if err := m.CheckVersion(ctx); err != nil {
+		t.Fatalf("CheckVersion after Up: %v", err)
+	}
+	if pending, err := m.Pending(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("Pending after Up = %v, %v", pending, err)
+	}
+
+	if _, err := m.Down(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := m.Pending(ctx); err != nil || len(pending) != 1 {
+		t.Fatalf("Pending after Down = %v, %v, want one", pending, err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tables live in the test schema, not in public
+	if _, err := s.DB.ExecContext(ctx, `INSERT INTO items (id, name, tag) VALUES (1, 'a', 'b')`); err != nil {
+		t.Fatal(err)
+	}
+	var table *string
+	if err := s.DB.QueryRowContext(ctx, `SELECT to_regclass('public.items')::text`).Scan(&table); err != nil || table != nil {
+		t.Fatalf("public.items = %v, %v, want none", table, err)
+	}
+}
+
+func TestMigratorRedo(t *testing.T) {
+	s := dbtest.New(t, nil)
+	ctx := context.Background()
+
+	m, err := db.NewMigrator(s, testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Redo(ctx); err == nil {
+		t.Fatal("Redo before Up: expected an error")
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// an Up racing the redo waits for it instead of applying the rolled back migration
+	errs := make(chan error, 2)
+	go func() {
+		results, err := m.Redo(ctx)
+		if err == nil && len(results) != 2 {
+			err = errors.New("redo did not roll back and apply the migration")
+		}
+		errs <- err
+	}()
+	go func() {
+		_, err := m.Up(ctx)
+		errs <- err
+	}()
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.CheckVersion(ctx); err != nil {
+		t.Fatalf("CheckVersion after Redo: %v", err)
+	}
+	if _, err := s.DB.ExecContext(ctx, `INSERT INTO items (id, name, tag) VALUES (1, 'a', 'b')`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	s := dbtest.New(t, testMigrations)
+	ctx := context.Background()
+
+	count := func() int {
+		var n int
+		if err := s.DB.QueryRowContext(ctx, `SELECT count(*) FROM items`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	errAbort := errors.New("abort")
+	err := s.WithTx(ctx, nil, func(ctx context.Context, q db.Querier) error {
+		if _, err := q.ExecContext(ctx, `INSERT INTO items (id, name) VALUES (1, 'a')`); err != nil {
+			return err
+		}
+		// the nested call joins the outer transaction and is rolled back with it
+		return s.WithTx(ctx, nil, func(ctx context.Context, _ db.Querier) error {
+			if _, err := s.Querier(ctx).ExecContext(ctx, `INSERT INTO items (id, name) VALUES (2, 'b')`); err != nil {
+				return err
+			}
+			return errAbort
+		})
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx = %v, want %v", err, errAbort)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("%d rows after rollback, want 0", n)
+	}
+
+	err = s.WithTx(ctx, nil, func(ctx context.Context, q db.Querier) error {
+		_, err := q.ExecContext(ctx, `INSERT INTO items (id, name) VALUES (1, 'a')`)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("%d rows after commit, want 1", n)
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// serialization failures are retried this many times, with a growing pause in between
+const (
+	maxTxAttempts  = 3
+	txRetryBackoff = 20 * time.Millisecond
+)
+
+// Querier is the query surface shared by *sql.DB, *sql.Tx and *sql.Conn. Stores take it from
+// DBService.Querier so the same method runs on the pool or inside the caller's transaction.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// Querier returns the transaction started by WithTx for ctx, or the pool outside of one
+func (s *DBService) Querier(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return s.DB
+}
+
+// WithTx runs fn inside a transaction, committed when fn returns nil and rolled back when it
+// returns an error or panics. fn must run its queries through s.Querier(ctx) (or q).
+//
+// Serialization failures and deadlocks are retried by running fn again, so fn must not
+// have side effects outside of the database. A WithTx nested in fn joins the outer transaction.
+func (s *DBService) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, q Querier) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx, tx)
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = s.runTx(ctx, opts, fn)
+		if err == nil || !isRetryable(err) || attempt == maxTxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * txRetryBackoff):
+		}
+	}
+	return err
+}
+
+func (s *DBService) runTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, q Querier) error) (err error) {
+	tx, err := s.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				err = errors.Join(err, fmt.Errorf("could not roll back: %w", rbErr))
+			}
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx), tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// isRetryable reports Postgres serialization_failure and deadlock_detected errors
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
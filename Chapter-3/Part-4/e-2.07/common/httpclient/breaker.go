@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending the request while the breaker of the host is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerPolicy configures the per host circuit breakers
+type BreakerPolicy struct {
+	Disabled bool
+
+	// FailureThreshold consecutive failures (transport errors and 5xx) open the circuit (default 5)
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a single probe request is let
+	// through (default 10s), the probe closes or reopens it
+	OpenTimeout time.Duration
+}
+
+func (p BreakerPolicy) withDefaults() BreakerPolicy {
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = 5
+	}
+	if p.OpenTimeout <= 0 {
+		p.OpenTimeout = 10 * time.Second
+	}
+	return p
+}
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
+
+type breaker struct {
+	policy   BreakerPolicy
+	onChange func(from, to State)
+	now      func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool // the half-open probe is in flight
+}
+
+func newBreaker(policy BreakerPolicy, onChange func(from, to State)) *breaker {
+	return &breaker{policy: policy, onChange: onChange, now: time.Now}
+}
+
+// allow returns ErrCircuitOpen when the request must not be sent
+func (b *breaker) allow() error {
+	if b.policy.Disabled {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.policy.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+		return nil
+	case HalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// release ends an allowed request whose outcome says nothing about the host (the caller
+// gave up), an in-flight half-open probe is cleared so the next request can probe again
+func (b *breaker) release() {
+	if b.policy.Disabled {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// record reports the outcome of an allowed request, every allowed request ends with
+// record or release
+func (b *breaker) record(success bool) {
+	if b.policy.Disabled {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.failures = 0
+		if b.state != Closed {
+			b.probing = false
+			b.setState(Closed)
+		}
+		return
+	}
+
+	b.failures++
+	switch {
+	case b.state == HalfOpen:
+		b.probing = false
+		b.open()
+	case b.state == Closed && b.failures >= b.policy.FailureThreshold:
+		b.open()
+	}
+}
+
+func (b *breaker) open() {
+	b.openedAt = b.now()
+	b.setState(Open)
+}
+
+func (b *breaker) setState(to State) {
+	from := b.state
+	b.state = to
+	if from != to && b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
+
+func (b *breaker) current() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
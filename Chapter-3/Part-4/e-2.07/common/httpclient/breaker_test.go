@@ -0,0 +1,77 @@
+package httpclient
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	policy := BreakerPolicy{FailureThreshold: 2, OpenTimeout: 10 * time.Second}
+
+	// steps run in order on one breaker: "ok", "fail" and "release" end an allowed request,
+	// "wait" moves the clock past OpenTimeout, "allow" expects the request to be let through
+	// and "reject" expects ErrCircuitOpen
+	tests := []struct {
+		name        string
+		steps       []string
+		want        State
+		transitions []State
+	}{
+		{"stays closed under the threshold", []string{"allow", "fail", "allow", "ok", "allow", "fail"}, Closed, nil},
+		{"opens at the threshold", []string{"allow", "fail", "allow", "fail", "reject"}, Open, []State{Open}},
+		{"rejects before the timeout", []string{"allow", "fail", "allow", "fail", "reject", "reject"}, Open, []State{Open}},
+		{"single probe", []string{"allow", "fail", "allow", "fail", "wait", "allow", "reject"}, HalfOpen, []State{Open, HalfOpen}},
+		{"probe success closes", []string{"allow", "fail", "allow", "fail", "wait", "allow", "ok", "allow"}, Closed, []State{Open, HalfOpen, Closed}},
+		{"probe failure reopens", []string{"allow", "fail", "allow", "fail", "wait", "allow", "fail", "reject"}, Open, []State{Open, HalfOpen, Open}},
+		{"cancelled probe lets the next one through", []string{"allow", "fail", "allow", "fail", "wait", "allow", "release", "allow", "ok"}, Closed, []State{Open, HalfOpen, Closed}},
+		{"release while closed", []string{"allow", "release", "allow", "fail", "allow", "fail"}, Open, []State{Open}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var transitions []State
+			b := newBreaker(policy, func(from, to State) { transitions = append(transitions, to) })
+			now := time.Unix(0, 0)
+			b.now = func() time.Time { return now }
+
+			for i, step := range tt.steps {
+				switch step {
+				case "allow", "reject":
+					err := b.allow()
+					if (step == "allow") != (err == nil) {
+						t.Fatalf("step %d: allow() = %v, want %s", i, err, step)
+					}
+					if err != nil && !errors.Is(err, ErrCircuitOpen) {
+						t.Fatalf("step %d: allow() = %v", i, err)
+					}
+				case "ok", "fail":
+					b.record(step == "ok")
+				case "release":
+					b.release()
+				case "wait":
+					now = now.Add(policy.OpenTimeout)
+				}
+			}
+			if got := b.current(); got != tt.want {
+				t.Fatalf("state = %s, want %s", got, tt.want)
+			}
+			if !slices.Equal(transitions, tt.transitions) {
+				t.Fatalf("transitions = %v, want %v", transitions, tt.transitions)
+			}
+		})
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := newBreaker(BreakerPolicy{Disabled: true, FailureThreshold: 1}, nil)
+	for range 3 {
+		if err := b.allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.record(false)
+	}
+	if got := b.current(); got != Closed {
+		t.Fatalf("state = %s, want closed", got)
+	}
+}
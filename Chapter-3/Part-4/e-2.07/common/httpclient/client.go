@@ -0,0 +1,351 @@
+// Package httpclient is the HTTP client shared by the services. Every call takes a context and
+// gets a per attempt timeout distinct from the connect timeout. Idempotent requests are retried
+// with jittered backoff and optionally hedged, every host has its own circuit breaker, and the
+// attempts are reported to Hooks (see NewMetrics).
+package httpclient
+
+import (
+	"bytes"
+	"common/tracing"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configure a Client, zero values get the defaults noted on the fields
+type Options struct {
+	Name    string // client name in metrics and errors, e.g. "pingpong"
+	BaseURL string // prefix of the request paths, paths may also be absolute URLs
+
+	// Transport is the base transport, a clone of http.DefaultTransport using ConnectTimeout
+	// and TLS if nil. Requests are traced on top of it.
+	Transport http.RoundTripper
+	TLS       *tls.Config
+
+	ConnectTimeout time.Duration // dial and TLS handshake (default 2s)
+	Timeout        time.Duration // each attempt, from sending the request to reading the body (default 10s)
+
+	Retry   RetryPolicy
+	Breaker BreakerPolicy
+
+	// HedgeDelay sends a second copy of an idempotent request still unanswered after this delay
+	// and keeps the first good response, 0 disables hedging
+	HedgeDelay time.Duration
+
+	Hooks Hooks
+}
+
+func (o Options) withDefaults() Options {
+	if o.Name == "" {
+		o.Name = "default"
+	}
+	if o.ConnectTimeout <= 0 {
+		o.ConnectTimeout = 2 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	o.Retry = o.Retry.withDefaults()
+	o.Breaker = o.Breaker.withDefaults()
+	return o
+}
+
+// Client sends requests with the retry, breaker and hedging policies of its Options
+type Client struct {
+	opts Options
+	base *url.URL
+	http *http.Client
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+func New(opts Options) (*Client, error) {
+	opts = opts.withDefaults()
+
+	var base *url.URL
+	if opts.BaseURL != "" {
+		var err error
+		if base, err = url.Parse(opts.BaseURL); err != nil {
+			return nil, fmt.Errorf("httpclient %s: invalid base url: %w", opts.Name, err)
+		}
+	}
+
+	transport := opts.Transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = (&net.Dialer{Timeout: opts.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		t.TLSHandshakeTimeout = opts.ConnectTimeout
+		t.TLSClientConfig = opts.TLS
+		transport = t
+	}
+
+	return &Client{
+		opts: opts,
+		base: base,
+		// no http.Client.Timeout, attempts are bounded by their context
+		http:     &http.Client{Transport: tracing.NewTransport(transport)},
+		breakers: make(map[string]*breaker),
+	}, nil
+}
+
+// HTTPClient returns a plain client sharing the transport (TLS, connect timeout, tracing) of c,
+// bounded by Options.Timeout and without the retry, breaker and hedging policies, e.g. for
+// server.HTTPCheck
+func (c *Client) HTTPClient() *http.Client {
+	return &http.Client{Transport: c.http.Transport, Timeout: c.opts.Timeout}
+}
+
+// Request describes a call, Body is sent again on every attempt
+type Request struct {
+	Method string
+	Path   string // joined to Options.BaseURL, or an absolute URL
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+
+	// Idempotent allows retrying and hedging a POST or PATCH, e.g. one carrying an idempotency key.
+	// GET, HEAD, OPTIONS, PUT and DELETE are idempotent by definition.
+	Idempotent bool
+}
+
+// Do sends req and returns the response of the last attempt, whatever its status.
+// The caller closes the body, which also releases the attempt context.
+func (c *Client) Do(ctx context.Context, req Request) (*http.Response, error) {
+	u, err := c.resolve(req.Path, req.Query)
+	if err != nil {
+		return nil, err
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+
+	idempotent := req.Idempotent || isIdempotent(req.Method)
+	attempts := 1
+	if idempotent {
+		attempts = c.opts.Retry.MaxAttempts
+	}
+	br := c.breaker(u.Host)
+
+	for attempt := 1; ; attempt++ {
+		if err := br.allow(); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", req.Method, u.Redacted(), err)
+		}
+
+		var resp *http.Response
+		if idempotent && c.opts.HedgeDelay > 0 {
+			resp, err = c.hedge(ctx, req, u, attempt)
+		} else {
+			resp, err = c.send(ctx, req, u, attempt, false)
+		}
+		if ctx.Err() == nil {
+			br.record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+		} else {
+			br.release()
+		}
+
+		if attempt >= attempts || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay := c.opts.Retry.delay(attempt, resp)
+		if resp != nil {
+			drain(resp)
+		}
+		c.opts.Hooks.retry(c.opts.Name, u.Host, attempt, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if err == nil {
+				err = fmt.Errorf("%s %s: %s", req.Method, u.Redacted(), resp.Status)
+			}
+			return nil, errors.Join(ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// send runs one attempt bounded by Options.Timeout
+func (c *Client) send(ctx context.Context, req Request, u *url.URL, attempt int, hedged bool) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+
+	var body io.Reader
+	if req.Body != nil {
+		body = bytes.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, u.String(), body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if req.Header != nil {
+		httpReq.Header = req.Header.Clone()
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(httpReq)
+
+	info := Attempt{
+		Client:   c.opts.Name,
+		Host:     u.Host,
+		Method:   req.Method,
+		Number:   attempt,
+		Hedged:   hedged,
+		Err:      err,
+		Duration: time.Since(start),
+	}
+	if resp != nil {
+		info.StatusCode = resp.StatusCode
+	}
+	c.opts.Hooks.attempt(info)
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	releaseOnClose(resp, cancel)
+	return resp, nil
+}
+
+func (c *Client) resolve(path string, query url.Values) (*url.URL, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient %s: invalid path %q: %w", c.opts.Name, path, err)
+	}
+	if !u.IsAbs() {
+		if c.base == nil {
+			return nil, fmt.Errorf("httpclient %s: relative path %q without a base url", c.opts.Name, path)
+		}
+		joined := *c.base
+		joined.Path = strings.TrimSuffix(c.base.Path, "/") + "/" + strings.TrimPrefix(u.Path, "/")
+		joined.RawPath = ""
+		joined.RawQuery = u.RawQuery
+		u = &joined
+	}
+	if len(query) > 0 {
+		q := u.Query()
+		for k, vs := range query {
+			q[k] = append(q[k], vs...)
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u, nil
+}
+
+func (c *Client) breaker(host string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	br, ok := c.breakers[host]
+	if !ok {
+		br = newBreaker(c.opts.Breaker, func(from, to State) {
+			c.opts.Hooks.breakerChange(c.opts.Name, host, from, to)
+		})
+		c.breakers[host] = br
+	}
+	return br
+}
+
+// BreakerState returns the circuit state of host, Closed for a host never called
+func (c *Client) BreakerState(host string) State {
+	c.mu.Lock()
+	br, ok := c.breakers[host]
+	c.mu.Unlock()
+	if !ok {
+		return Closed
+	}
+	return br.current()
+}
+
+// StatusError is returned by the JSON helpers for a non 2xx response
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Body       []byte // start of the response body
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+}
+
+// maxErrorBody bounds the body kept by StatusError
+const maxErrorBody = 4 << 10
+
+// Get sends a GET for path
+func (c *Client) Get(ctx context.Context, path string) (*http.Response, error) {
+	return c.Do(ctx, Request{Method: http.MethodGet, Path: path})
+}
+
+// GetJSON sends a GET for path and decodes the JSON response into a T
+func GetJSON[T any](ctx context.Context, c *Client, path string) (T, error) {
+	return DoJSON[T](ctx, c, Request{Method: http.MethodGet, Path: path})
+}
+
+// DoJSON sends req and decodes the JSON response into a T. A non 2xx response is a *StatusError.
+func DoJSON[T any](ctx context.Context, c *Client, req Request) (T, error) {
+	var out T
+
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Accept", "application/json")
+	if req.Body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return out, &StatusError{
+			Method:     resp.Request.Method,
+			URL:        resp.Request.URL.Redacted(),
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       body,
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return out, fmt.Errorf("%s %s: could not decode response body: %w", resp.Request.Method, resp.Request.URL.Redacted(), err)
+	}
+	return out, nil
+}
+
+// releaseOnClose cancels the attempt context once the caller is done with the body
+func releaseOnClose(resp *http.Response, cancel context.CancelFunc) {
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// drain discards a bit of the body so the connection can be reused, and closes it
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+	resp.Body.Close()
+}
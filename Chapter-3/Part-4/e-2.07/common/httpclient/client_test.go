@@ -0,0 +1,266 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, srv *httptest.Server, opts Options) *Client {
+	t.Helper()
+	opts.BaseURL = srv.URL
+	if opts.Retry.BaseDelay == 0 {
+		opts.Retry.BaseDelay = time.Millisecond
+	}
+	c, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        Request
+		status     int
+		retryAfter string
+		attempts   int
+		wantCalls  int32
+		wantStatus int
+	}{
+		{"get retried on 503", Request{Method: http.MethodGet}, http.StatusServiceUnavailable, "", 3, 3, http.StatusServiceUnavailable},
+		{"get retried on 429 with retry-after", Request{Method: http.MethodGet}, http.StatusTooManyRequests, "0", 3, 3, http.StatusTooManyRequests},
+		{"get not retried on 500", Request{Method: http.MethodGet}, http.StatusInternalServerError, "", 3, 1, http.StatusInternalServerError},
+		{"get not retried on 404", Request{Method: http.MethodGet}, http.StatusNotFound, "", 3, 1, http.StatusNotFound},
+		{"post not retried", Request{Method: http.MethodPost}, http.StatusServiceUnavailable, "", 3, 1, http.StatusServiceUnavailable},
+		{"idempotent post retried", Request{Method: http.MethodPost, Idempotent: true}, http.StatusServiceUnavailable, "", 3, 3, http.StatusServiceUnavailable},
+		{"one attempt", Request{Method: http.MethodGet}, http.StatusServiceUnavailable, "", 1, 1, http.StatusServiceUnavailable},
+		{"success", Request{Method: http.MethodGet}, http.StatusOK, "", 3, 1, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			var retries int
+			c := newTestClient(t, srv, Options{
+				Retry:   RetryPolicy{MaxAttempts: tt.attempts},
+				Breaker: BreakerPolicy{Disabled: true},
+				Hooks:   Hooks{OnRetry: func(string, string, int, time.Duration) { retries++ }},
+			})
+			resp, err := c.Do(context.Background(), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("server saw %d calls, want %d", got, tt.wantCalls)
+			}
+			if retries != int(tt.wantCalls)-1 {
+				t.Fatalf("OnRetry called %d times, want %d", retries, tt.wantCalls-1)
+			}
+		})
+	}
+}
+
+func TestRetryRecovers(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"count":7}`))
+	}))
+	defer srv.Close()
+
+	c := newTestClient(t, srv, Options{Retry: RetryPolicy{MaxAttempts: 3}})
+	got, err := GetJSON[struct{ Count int }](context.Background(), c, "/pingpong/count")
+	if err != nil || got.Count != 7 {
+		t.Fatalf("GetJSON = %+v, %v", got, err)
+	}
+	if host := hostOf(t, srv); c.BreakerState(host) != Closed {
+		t.Fatalf("breaker = %s after the recovery, want closed", c.BreakerState(host))
+	}
+}
+
+func TestBreakerOpens(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := newTestClient(t, srv, Options{Breaker: BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Hour}})
+	for range 2 {
+		resp, err := c.Get(context.Background(), "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := c.Get(context.Background(), "/"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("third call = %v, want %v", err, ErrCircuitOpen)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("server saw %d calls, want 2", got)
+	}
+	if host := hostOf(t, srv); c.BreakerState(host) != Open {
+		t.Fatalf("breaker = %s, want open", c.BreakerState(host))
+	}
+}
+
+// a half-open probe abandoned by its caller must not leave the breaker rejecting every request
+func TestBreakerCancelledProbe(t *testing.T) {
+	var fail, hang atomic.Bool
+	fail.Store(true)
+	started := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hang.Load() {
+			started <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	c := newTestClient(t, srv, Options{Breaker: BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Hour}})
+	host := hostOf(t, srv)
+	resp, err := c.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	now := time.Now()
+	c.breaker(host).now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	// the probe hangs and its caller gives up
+	hang.Store(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := c.Get(ctx, "/"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled probe = %v, want %v", err, context.Canceled)
+	}
+	if got := c.BreakerState(host); got != HalfOpen {
+		t.Fatalf("breaker = %s after the cancelled probe, want half-open", got)
+	}
+
+	hang.Store(false)
+	fail.Store(false)
+	resp, err = c.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("next probe = %v, want it sent", err)
+	}
+	resp.Body.Close()
+	if got := c.BreakerState(host); got != Closed {
+		t.Fatalf("breaker = %s after a good probe, want closed", got)
+	}
+}
+
+func TestHedge(t *testing.T) {
+	tests := []struct {
+		name       string
+		slowFirst  bool // the first request hangs until cancelled
+		wantHedges int32
+		wantWinner string
+	}{
+		{"fast first request", false, 0, "1"},
+		{"hedge wins", true, 1, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			loserCancelled := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				if n == 1 && tt.slowFirst {
+					<-r.Context().Done()
+					close(loserCancelled)
+					return
+				}
+				w.Write([]byte{byte('0' + n)})
+			}))
+			defer srv.Close()
+
+			var hedges atomic.Int32
+			c := newTestClient(t, srv, Options{
+				HedgeDelay: 20 * time.Millisecond,
+				Hooks:      Hooks{OnHedge: func(string, string) { hedges.Add(1) }},
+			})
+			resp, err := c.Get(context.Background(), "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := make([]byte, 1)
+			resp.Body.Read(body)
+			resp.Body.Close()
+
+			if string(body) != tt.wantWinner {
+				t.Fatalf("winner = request %s, want %s", body, tt.wantWinner)
+			}
+			if got := hedges.Load(); got != tt.wantHedges {
+				t.Fatalf("%d hedges, want %d", got, tt.wantHedges)
+			}
+			if tt.slowFirst {
+				select {
+				case <-loserCancelled:
+				case <-time.After(5 * time.Second):
+					t.Fatal("the losing request was not cancelled")
+				}
+			}
+		})
+	}
+}
+
+func TestHedgeNotForPost(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	c := newTestClient(t, srv, Options{HedgeDelay: time.Millisecond})
+	resp, err := c.Do(context.Background(), Request{Method: http.MethodPost})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("server saw %d calls, want 1", got)
+	}
+}
+
+func hostOf(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
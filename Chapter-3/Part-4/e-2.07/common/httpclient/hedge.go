@@ -0,0 +1,88 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type hedgeResult struct {
+	resp *http.Response
+	err  error
+	idx  int
+}
+
+func (r hedgeResult) good() bool {
+	return r.err == nil && r.resp.StatusCode < http.StatusInternalServerError
+}
+
+// hedge sends req and, if it is unanswered after HedgeDelay, a second copy. The first good
+// response wins and the other request is cancelled. When both fail the first failure is returned.
+func (c *Client) hedge(ctx context.Context, req Request, u *url.URL, attempt int) (*http.Response, error) {
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	launch := func(hedged bool) {
+		lctx, cancel := context.WithCancel(ctx)
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := c.send(lctx, req, u, attempt, hedged)
+			results <- hedgeResult{resp: resp, err: err, idx: idx}
+		}()
+	}
+
+	launch(false)
+	timer := time.NewTimer(c.opts.HedgeDelay)
+	defer timer.Stop()
+
+	inFlight := 1
+	var failed *hedgeResult
+	for {
+		select {
+		case <-timer.C:
+			if failed == nil {
+				c.opts.Hooks.hedge(c.opts.Name, u.Host)
+				launch(true)
+				inFlight++
+			}
+
+		case res := <-results:
+			inFlight--
+			if !res.good() && inFlight > 0 {
+				failed = &res
+				continue
+			}
+			if res.good() && failed != nil {
+				discard(*failed)
+			}
+			if !res.good() && failed != nil {
+				// both failed, keep the first failure
+				discard(res)
+				res = *failed
+			}
+
+			// cancel the loser, its result is discarded once it returns
+			for i, cancel := range cancels {
+				if i != res.idx {
+					cancel()
+				}
+			}
+			for range inFlight {
+				go func() { discard(<-results) }()
+			}
+			if res.resp != nil {
+				releaseOnClose(res.resp, cancels[res.idx])
+			} else {
+				cancels[res.idx]()
+			}
+			return res.resp, res.err
+		}
+	}
+}
+
+func discard(res hedgeResult) {
+	if res.resp != nil {
+		drain(res.resp)
+	}
+}
@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Attempt describes one request sent on the wire
+type Attempt struct {
+	Client     string
+	Host       string
+	Method     string
+	Number     int  // 1 for the first attempt, incremented by retries
+	Hedged     bool // second copy sent by hedging
+	StatusCode int  // 0 on transport errors
+	Err        error
+	Duration   time.Duration
+}
+
+// Hooks observe the client, nil funcs are skipped. They run on the request goroutine
+// (OnBreakerChange under the breaker lock) and must be fast.
+type Hooks struct {
+	OnAttempt       func(Attempt)
+	OnRetry         func(client, host string, attempt int, delay time.Duration)
+	OnHedge         func(client, host string)
+	OnBreakerChange func(client, host string, from, to State)
+}
+
+func (h Hooks) attempt(a Attempt) {
+	if h.OnAttempt != nil {
+		h.OnAttempt(a)
+	}
+}
+
+func (h Hooks) retry(client, host string, attempt int, delay time.Duration) {
+	if h.OnRetry != nil {
+		h.OnRetry(client, host, attempt, delay)
+	}
+}
+
+func (h Hooks) hedge(client, host string) {
+	if h.OnHedge != nil {
+		h.OnHedge(client, host)
+	}
+}
+
+func (h Hooks) breakerChange(client, host string, from, to State) {
+	if h.OnBreakerChange != nil {
+		h.OnBreakerChange(client, host, from, to)
+	}
+}
+
+// Metrics are the Prometheus metrics of the clients of a service, labelled by client and host
+type Metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	retries  *prometheus.CounterVec
+	hedges   *prometheus.CounterVec
+	breaker  *prometheus.GaugeVec
+}
+
+// NewMetrics registers the client metrics, once per registry
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_client_requests_total",
+			Help: "Outgoing HTTP attempts, by client, host, method and status code (error for transport failures).",
+		}, []string{"client", "host", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_client_request_duration_seconds",
+			Help:    "Latency of the outgoing HTTP attempts until the response headers, by client, host and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"client", "host", "method"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_client_retries_total",
+			Help: "Outgoing HTTP requests retried, by client and host.",
+		}, []string{"client", "host"}),
+		hedges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_client_hedges_total",
+			Help: "Hedged copies of outgoing HTTP requests, by client and host.",
+		}, []string{"client", "host"}),
+		breaker: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_client_circuit_state",
+			Help: "Circuit breaker state by client and host: 0 closed, 1 half-open, 2 open.",
+		}, []string{"client", "host"}),
+	}
+	reg.MustRegister(m.requests, m.duration, m.retries, m.hedges, m.breaker)
+	return m
+}
+
+// Hooks returns the hooks feeding the metrics
+func (m *Metrics) Hooks() Hooks {
+	return Hooks{
+		OnAttempt: func(a Attempt) {
+			status := "error"
+			if a.Err == nil {
+				status = strconv.Itoa(a.StatusCode)
+			}
+			m.requests.WithLabelValues(a.Client, a.Host, a.Method, status).Inc()
+			m.duration.WithLabelValues(a.Client, a.Host, a.Method).Observe(a.Duration.Seconds())
+		},
+		OnRetry: func(client, host string, _ int, _ time.Duration) {
+			m.retries.WithLabelValues(client, host).Inc()
+		},
+		OnHedge: func(client, host string) {
+			m.hedges.WithLabelValues(client, host).Inc()
+		},
+		OnBreakerChange: func(client, host string, _, to State) {
+			m.breaker.WithLabelValues(client, host).Set(float64(to))
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"common/utils"
+	"log/slog"
+	"net/http"
+)
+
+// LevelHandler serves the current level on GET and changes it on PUT,
+// with either ?level=debug or a {"level": "debug"} body.
+func LevelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		utils.OK(w, utils.Envelope{"level": Level().String()})
+		return
+	}
+
+	raw := r.URL.Query().Get("level")
+	if raw == "" {
+		var body struct {
+			Level string `json:"level" validate:"required"`
+		}
+		if err := utils.DecodeJSONLimit(w, r, &body, 1024); err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		raw = body.Level
+	}
+
+	lvl, err := ParseLevel(raw)
+	if err != nil {
+		utils.BadRequest(w, r, utils.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	prev := Level()
+	SetLevel(lvl)
+	FromContext(r.Context()).Warn("log level changed", slog.String("from", prev.String()), slog.String("to", lvl.String()))
+	utils.OK(w, utils.Envelope{"level": lvl.String()})
+}
@@ -0,0 +1,84 @@
+// Package logging sets up log/slog for the services: JSON or text output,
+// a level adjustable at runtime and request scoped loggers.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Config selects the output format and the initial level, bound with common/config
+type Config struct {
+	Format string `env:"LOG_FORMAT" default:"text"` // text | json
+	Level  string `env:"LOG_LEVEL" default:"info"`  // debug | info | warn | error
+}
+
+// level is shared by every logger built by Setup so it can be changed at runtime
+var level = new(slog.LevelVar)
+
+// Setup builds the service logger writing to stdout and installs it as the slog and log default
+func Setup(cfg Config, service string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	level.Set(lvl)
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	case "text", "":
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (expected text or json)", cfg.Format)
+	}
+
+	logger := slog.New(handler).With("service", service)
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// ParseLevel accepts debug, info, warn and error (case insensitive)
+func ParseLevel(s string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return lvl, nil
+}
+
+// Level returns the current level
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the level of every logger built by Setup
+func SetLevel(lvl slog.Level) {
+	level.Set(lvl)
+}
+
+type ctxKey struct{}
+
+// WithContext returns a copy of ctx carrying logger
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the request scoped logger, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Fatal logs msg at error level and exits, the slog counterpart of log.Fatal
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
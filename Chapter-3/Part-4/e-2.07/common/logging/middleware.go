@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// quietRoutes are polled by the kubelet, successful calls are only logged at debug level
+var quietRoutes = map[string]bool{
+	"/livez":    true,
+	"/readyz":   true,
+	"/startupz": true,
+	"/health":   true,
+}
+
+// Middleware stores a request scoped logger (request id, method, path) in the request
+// context and logs every request with its route pattern, status and latency. The access
+// log carries the raw path only for unmatched requests, so IDs in paths do not split
+// the entries of one route.
+// It expects chi's middleware.RequestID (and tracing.Middleware for the trace ids) to run before it.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			reqID := middleware.GetReqID(r.Context())
+			if reqID != "" {
+				w.Header().Set(middleware.RequestIDHeader, reqID)
+			}
+			accessLogger := logger.With(
+				"request_id", reqID,
+				"method", r.Method,
+			)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				accessLogger = accessLogger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+			}
+			reqLogger := accessLogger.With("path", r.URL.Path)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(WithContext(r.Context(), reqLogger)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := RoutePattern(r)
+			lvl := slog.LevelInfo
+			switch {
+			case quietRoutes[route] && status < http.StatusBadRequest:
+				lvl = slog.LevelDebug
+			case status >= http.StatusInternalServerError:
+				lvl = slog.LevelError
+			case status >= http.StatusBadRequest:
+				lvl = slog.LevelWarn
+			}
+
+			attrs := []slog.Attr{
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			}
+			if route == "" {
+				attrs = append(attrs, slog.String("path", r.URL.Path))
+			}
+			accessLogger.LogAttrs(r.Context(), lvl, "request", attrs...)
+		})
+	}
+}
+
+// RoutePattern returns the chi route pattern matched by r (e.g. /counters/{name}), "" if unmatched
+func RoutePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return rctx.RoutePattern()
+}
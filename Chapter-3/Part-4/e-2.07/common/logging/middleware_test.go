@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestMiddlewareLogsRoutePattern(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		wantRoute string
+		wantPath  string // "" when the access log must not carry the raw path
+		wantLevel string
+	}{
+		{"route with id", "/counters/visits-42?namespace=web", "/counters/{name}", "", "INFO"},
+		{"nested route", "/counters/visits-42/increment", "/counters/{name}/increment", "", "INFO"},
+		{"probe", "/livez", "/livez", "", "DEBUG"},
+		{"unmatched", "/wp-login.php", "", "/wp-login.php", "WARN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			var handlerPath any
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			r.Use(Middleware(logger))
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				FromContext(r.Context()).Info("handled")
+			}
+			r.Get("/livez", handler)
+			r.Route("/counters/{name}", func(r chi.Router) {
+				r.Get("/", handler)
+				r.Get("/increment", handler)
+			})
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			var access map[string]any
+			for line := range bytes.Lines(buf.Bytes()) {
+				var entry map[string]any
+				if err := json.Unmarshal(line, &entry); err != nil {
+					t.Fatal(err)
+				}
+				switch entry["msg"] {
+				case "request":
+					access = entry
+				case "handled":
+					handlerPath = entry["path"]
+				}
+			}
+			if access == nil {
+				t.Fatalf("no access log in:\n%s", buf.String())
+			}
+
+			if access["route"] != tt.wantRoute {
+				t.Errorf("route = %v, want %q", access["route"], tt.wantRoute)
+			}
+			if path, ok := access["path"]; tt.wantPath == "" && ok || tt.wantPath != "" && path != tt.wantPath {
+				t.Errorf("path = %v, want %q", path, tt.wantPath)
+			}
+			if access["level"] != tt.wantLevel {
+				t.Errorf("level = %v, want %s", access["level"], tt.wantLevel)
+			}
+			if access["request_id"] == "" || access["method"] != http.MethodGet {
+				t.Errorf("request_id, method = %v, %v", access["request_id"], access["method"])
+			}
+			if tt.wantRoute != "" && handlerPath == nil {
+				t.Error("the request logger has no path")
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newInstrumentedRouter(reg prometheus.Registerer) (*chi.Mux, *HTTPMetrics) {
+	m := NewHTTPMetrics(reg)
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/pingpong", func(w http.ResponseWriter, r *http.Request) {})
+	r.Route("/counters/{name}", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if chi.URLParam(r, "name") == "missing" {
+				http.NotFound(w, r)
+			}
+		})
+		r.Post("/increment", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+	})
+	r.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	return r, m
+}
+
+func TestMiddlewareLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r, m := newInstrumentedRouter(reg)
+
+	requests := []struct{ method, target string }{
+		{http.MethodGet, "/pingpong"},
+		{http.MethodGet, "/pingpong"},
+		{http.MethodGet, "/counters/a"},
+		{http.MethodGet, "/counters/b"},
+		{http.MethodGet, "/counters/missing"},
+		{http.MethodPost, "/counters/a/increment"},
+		{http.MethodGet, "/fail"},
+		{http.MethodGet, "/no/such/route/1"},
+		{http.MethodGet, "/no/such/route/2"},
+		{http.MethodDelete, "/pingpong"},
+	}
+	for _, req := range requests {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.target, nil))
+	}
+
+	want := `
+# HELP http_requests_total HTTP requests handled, by method, chi route pattern and status code.
+# TYPE http_requests_total counter
+http_requests_total{method="DELETE",route="unmatched",status="405"} 1
+http_requests_total{method="GET",route="/counters/{name}",status="200"} 2
+http_requests_total{method="GET",route="/counters/{name}",status="404"} 1
+http_requests_total{method="GET",route="/fail",status="500"} 1
+http_requests_total{method="GET",route="/pingpong",status="200"} 2
+http_requests_total{method="GET",route="unmatched",status="404"} 2
+http_requests_total{method="POST",route="/counters/{name}/increment",status="201"} 1
+`
+	if err := testutil.CollectAndCompare(m.requests, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(m.duration); n != 7 {
+		t.Fatalf("http_request_duration_seconds has %d series, want 7", n)
+	}
+	if v := testutil.ToFloat64(m.inFlight); v != 0 {
+		t.Fatalf("http_requests_in_flight = %v after the requests, want 0", v)
+	}
+}
+
+func TestMiddlewareInFlight(t *testing.T) {
+	m := NewHTTPMetrics(prometheus.NewRegistry())
+	var during float64
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		during = testutil.ToFloat64(m.inFlight)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if during != 1 {
+		t.Fatalf("http_requests_in_flight = %v during the request, want 1", during)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	reg := NewRegistry()
+	r, _ := newInstrumentedRouter(reg)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/pingpong", nil))
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, metric := range []string{
+		`http_requests_total{method="GET",route="/pingpong",status="200"} 1`,
+		"go_goroutines",
+		"go_build_info",
+	} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("/metrics is missing %s", metric)
+		}
+	}
+}
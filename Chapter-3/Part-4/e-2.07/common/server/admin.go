@@ -0,0 +1,131 @@
+package server
+
+import (
+	"common/logging"
+	"common/metrics"
+	"common/utils"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewAdmin returns the admin listener. It has no TLS and must not be exposed by the
+// Service/Ingress, the kubelet and Prometheus reach it on the pod ip.
+func NewAdmin(port int) *http.Server {
+	return &http.Server{
+		Addr:        fmt.Sprintf(":%d", port),
+		IdleTimeout: time.Minute,
+		ReadTimeout: 10 * time.Second,
+		// pprof profile and trace endpoints stream for up to 30s by default
+		WriteTimeout: 2 * time.Minute,
+	}
+}
+
+// AdminConfig holds the operational endpoints mounted by NewAdminRouter
+type AdminConfig struct {
+	Probes  *Probes              // mounted on /livez, /readyz, /startupz and /health (empty registry if nil)
+	Metrics *prometheus.Registry // mounted on /metrics when set
+	Config  http.Handler         // runtime configuration dump mounted on /admin/config when set
+	PreStop http.Handler         // preStop hook mounted on POST /admin/prestop, loopback only, when set (boot.PreStopHandler)
+	Logger  *slog.Logger         // base of the request loggers (slog.Default() if nil)
+
+	// Tokens authenticate /debug/pprof and /admin/log-level. Without tokens they are served
+	// on loopback only, e.g. through kubectl port-forward: the admin port is reachable from any pod.
+	Tokens BearerTokens
+}
+
+// NewAdminRouter returns the router of the admin listener:
+//
+//	/livez /readyz /startupz /health   probes
+//	/metrics                           Prometheus
+//	/debug/pprof/*                     net/http/pprof, bearer token or loopback
+//	/buildinfo                         module, Go and vcs versions
+//	/admin/config                      effective configuration, secrets redacted
+//	/admin/log-level                   GET / PUT the log level, bearer token or loopback
+//	/admin/prestop                     POST from loopback: starts the drain, answers after the drain delay
+func NewAdminRouter(cfg AdminConfig) *chi.Mux {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(logging.Middleware(logger))
+	r.Use(recoverer)
+	mountErrorHandlers(r)
+
+	probes := cfg.Probes
+	if probes == nil {
+		probes = NewProbes()
+	}
+	probes.Mount(r)
+
+	if cfg.Metrics != nil {
+		r.Handle("/metrics", metrics.Handler(cfg.Metrics))
+	}
+	r.Get("/buildinfo", BuildInfoHandler)
+	if cfg.Config != nil {
+		r.Handle("/admin/config", cfg.Config)
+	}
+	if cfg.PreStop != nil {
+		// the drain cannot be undone, only the hook exec'd in the container (boot.PreStop) may start it
+		r.With(loopbackOnly).Method(http.MethodPost, "/admin/prestop", cfg.PreStop)
+	}
+
+	// profiles expose the process memory and the log level changes it
+	guard := loopbackOnly
+	if len(cfg.Tokens.entries) > 0 {
+		guard = RequireBearer(cfg.Tokens)
+	}
+	r.Group(func(r chi.Router) {
+		r.Use(guard)
+		r.Mount("/debug", middleware.Profiler())
+		r.Get("/admin/log-level", logging.LevelHandler)
+		r.Put("/admin/log-level", logging.LevelHandler)
+	})
+
+	return r
+}
+
+// loopbackOnly rejects the requests not coming from the pod itself. The admin listener has no
+// trusted proxies, RemoteAddr is the peer.
+func loopbackOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := parseAddr(r.RemoteAddr); !ok || !addr.IsLoopback() {
+			utils.WriteProblem(w, r, utils.NewProblem(http.StatusForbidden, utils.CodeForbidden,
+				fmt.Sprintf("%s is only served on loopback", r.URL.Path)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BuildInfoHandler serves the build information embedded by the Go toolchain
+func BuildInfoHandler(w http.ResponseWriter, r *http.Request) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		utils.NotFound(w, r, "build information not available")
+		return
+	}
+
+	build := utils.Envelope{
+		"go_version": info.GoVersion,
+		"path":       info.Path,
+		"module":     info.Main.Path,
+		"version":    info.Main.Version,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified", "GOOS", "GOARCH":
+			build[setting.Key] = setting.Value
+		}
+	}
+	utils.OK(w, utils.Envelope{"build": build})
+}
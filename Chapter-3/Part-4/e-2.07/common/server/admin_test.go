@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminPreStop(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		remote     string
+		want       int
+		wantCalled bool
+	}{
+		{"post from loopback", http.MethodPost, "127.0.0.1:40000", http.StatusNoContent, true},
+		{"post from ipv6 loopback", http.MethodPost, "[::1]:40000", http.StatusNoContent, true},
+		{"post from the pod network", http.MethodPost, "10.42.0.7:40000", http.StatusForbidden, false},
+		{"get from loopback", http.MethodGet, "127.0.0.1:40000", http.StatusMethodNotAllowed, false},
+		{"get from the pod network", http.MethodGet, "10.42.0.7:40000", http.StatusMethodNotAllowed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			r := NewAdminRouter(AdminConfig{PreStop: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusNoContent)
+			})})
+
+			req := httptest.NewRequest(tt.method, "/admin/prestop", nil)
+			req.RemoteAddr = tt.remote
+			// forwarded headers are ignored, the admin listener trusts no proxy
+			req.Header.Set("X-Forwarded-For", "127.0.0.1")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want || called != tt.wantCalled {
+				t.Fatalf("%s from %s = %d (hook called %v), want %d (%v)", tt.method, tt.remote, rec.Code, called, tt.want, tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestAdminOperatorRoutes(t *testing.T) {
+	tokens, err := NewBearerTokens(map[string]string{"alice": aliceToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		tokens        BearerTokens
+		method, path  string
+		remote        string
+		authorization string
+		want          int
+	}{
+		{"pprof from loopback without tokens", BearerTokens{}, http.MethodGet, "/debug/pprof/", "127.0.0.1:40000", "", http.StatusOK},
+		{"pprof from the pod network without tokens", BearerTokens{}, http.MethodGet, "/debug/pprof/", "10.42.0.7:40000", "", http.StatusForbidden},
+		{"log level from the pod network without tokens", BearerTokens{}, http.MethodPut, "/admin/log-level?level=info", "10.42.0.7:40000", "", http.StatusForbidden},
+		{"pprof with a token", tokens, http.MethodGet, "/debug/pprof/", "10.42.0.7:40000", "Bearer " + aliceToken, http.StatusOK},
+		{"log level with a token", tokens, http.MethodGet, "/admin/log-level", "10.42.0.7:40000", "Bearer " + aliceToken, http.StatusOK},
+		{"log level change with a token", tokens, http.MethodPut, "/admin/log-level?level=info", "10.42.0.7:40000", "Bearer " + aliceToken, http.StatusOK},
+		{"pprof without a token", tokens, http.MethodGet, "/debug/pprof/", "10.42.0.7:40000", "", http.StatusUnauthorized},
+		{"loopback needs the token once configured", tokens, http.MethodPut, "/admin/log-level?level=info", "127.0.0.1:40000", "", http.StatusUnauthorized},
+		{"probes stay open", tokens, http.MethodGet, "/livez", "10.42.0.7:40000", "", http.StatusOK},
+		{"build info stays open", tokens, http.MethodGet, "/buildinfo", "10.42.0.7:40000", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewAdminRouter(AdminConfig{Tokens: tt.tokens})
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = tt.remote
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("%s %s from %s = %d, want %d", tt.method, tt.path, tt.remote, rec.Code, tt.want)
+			}
+		})
+	}
+}
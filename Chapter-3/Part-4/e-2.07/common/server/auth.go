@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bufio"
+	"common/utils"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// minTokenLen rejects guessable bearer tokens when they are loaded
+const minTokenLen = 32
+
+// BearerTokens are the bearer tokens accepted by RequireBearer and the principals they
+// authenticate. Only the token hashes are kept.
+type BearerTokens struct {
+	entries []tokenEntry
+}
+
+type tokenEntry struct {
+	hash      [sha256.Size]byte
+	principal string
+}
+
+// NewBearerTokens maps each principal to its token
+func NewBearerTokens(tokens map[string]string) (BearerTokens, error) {
+	var bt BearerTokens
+	seen := make(map[[sha256.Size]byte]string, len(tokens))
+	for principal, token := range tokens {
+		if principal == "" || strings.ContainsAny(principal, " \t") {
+			return BearerTokens{}, fmt.Errorf("invalid principal %q", principal)
+		}
+		if len(token) < minTokenLen {
+			return BearerTokens{}, fmt.Errorf("token of %s: must be at least %d characters", principal, minTokenLen)
+		}
+		hash := sha256.Sum256([]byte(token))
+		if other, ok := seen[hash]; ok {
+			return BearerTokens{}, fmt.Errorf("%s and %s share the same token", other, principal)
+		}
+		seen[hash] = principal
+		bt.entries = append(bt.entries, tokenEntry{hash: hash, principal: principal})
+	}
+	return bt, nil
+}
+
+// LoadBearerTokens reads a "<principal> <token>" per line file, e.g. mounted from a Secret.
+// Blank lines and lines starting with # are skipped.
+func LoadBearerTokens(path string) (BearerTokens, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BearerTokens{}, fmt.Errorf("could not read bearer tokens: %w", err)
+	}
+	defer f.Close()
+
+	tokens := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return BearerTokens{}, fmt.Errorf("%s:%d: expected \"<principal> <token>\"", path, n)
+		}
+		if _, ok := tokens[fields[0]]; ok {
+			return BearerTokens{}, fmt.Errorf("%s:%d: duplicate principal %s", path, n, fields[0])
+		}
+		tokens[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return BearerTokens{}, fmt.Errorf("could not read bearer tokens: %w", err)
+	}
+	bt, err := NewBearerTokens(tokens)
+	if err != nil {
+		return BearerTokens{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return bt, nil
+}
+
+// principal returns the principal of token, every entry is compared in constant time
+func (bt BearerTokens) principal(token string) (string, bool) {
+	hash := sha256.Sum256([]byte(token))
+	principal, found := "", false
+	for _, e := range bt.entries {
+		if subtle.ConstantTimeCompare(hash[:], e.hash[:]) == 1 {
+			principal, found = e.principal, true
+		}
+	}
+	return principal, found
+}
+
+type principalKey struct{}
+
+// RequireBearer answers 401 to the requests without a valid "Authorization: Bearer <token>"
+// and records the principal of the others for Principal. With no tokens every request is refused.
+func RequireBearer(tokens BearerTokens) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			principal, ok := "", false
+			if strings.EqualFold(scheme, "Bearer") {
+				principal, ok = tokens.principal(strings.TrimSpace(token))
+			}
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				utils.WriteProblem(w, r, utils.NewProblem(http.StatusUnauthorized, utils.CodeUnauthenticated,
+					"a valid bearer token is required"))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+		})
+	}
+}
+
+// Principal returns the identity authenticated by RequireBearer, "" when there is none.
+// Client set headers such as X-Forwarded-User are never trusted for it.
+func Principal(r *http.Request) string {
+	principal, _ := r.Context().Value(principalKey{}).(string)
+	return principal
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	aliceToken = "alice-0123456789abcdef0123456789abcdef"
+	bobToken   = "bob-0123456789abcdef0123456789abcdef"
+)
+
+func TestRequireBearer(t *testing.T) {
+	tokens, err := NewBearerTokens(map[string]string{"alice": aliceToken, "bob": bobToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		tokens        BearerTokens
+		authorization string
+		forwardedUser string
+		want          int
+		wantPrincipal string
+	}{
+		{"alice", tokens, "Bearer " + aliceToken, "", http.StatusOK, "alice"},
+		{"bob, lower case scheme", tokens, "bearer " + bobToken, "", http.StatusOK, "bob"},
+		{"header ignored", tokens, "Bearer " + aliceToken, "bob", http.StatusOK, "alice"},
+		{"no authorization", tokens, "", "alice", http.StatusUnauthorized, ""},
+		{"unknown token", tokens, "Bearer " + strings.Repeat("x", 40), "", http.StatusUnauthorized, ""},
+		{"token prefix", tokens, "Bearer " + aliceToken[:20], "", http.StatusUnauthorized, ""},
+		{"basic auth", tokens, "Basic " + aliceToken, "", http.StatusUnauthorized, ""},
+		{"no tokens configured", BearerTokens{}, "Bearer " + aliceToken, "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var principal string
+			h := RequireBearer(tt.tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal = Principal(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/counters/pingpong/reset", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.forwardedUser != "" {
+				req.Header.Set("X-Forwarded-User", tt.forwardedUser)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want || principal != tt.wantPrincipal {
+				t.Fatalf("status %d principal %q, want %d %q", rec.Code, principal, tt.want, tt.wantPrincipal)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestLoadBearerTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", "# admins\nalice " + aliceToken + "\n\nbob " + bobToken + "\n", false},
+		{"missing token", "alice\n", true},
+		{"short token", "alice secret\n", true},
+		{"duplicate principal", "alice " + aliceToken + "\nalice " + bobToken + "\n", true},
+		{"shared token", "alice " + aliceToken + "\nbob " + aliceToken + "\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			tokens, err := LoadBearerTokens(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadBearerTokens() = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if p, ok := tokens.principal(bobToken); !ok || p != "bob" {
+					t.Fatalf("principal(bob's token) = %q, %v", p, ok)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadBearerTokens(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("LoadBearerTokens on a missing file: expected an error")
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// InFlight tracks the requests being served, boot.HTTPServer logs the ones still running
+// when the shutdown deadline expires
+type InFlight struct {
+	mu     sync.Mutex
+	nextID uint64
+	active map[uint64]activeRequest
+}
+
+type activeRequest struct {
+	method    string
+	path      string
+	requestID string
+	remote    string
+	start     time.Time
+}
+
+func NewInFlight() *InFlight {
+	return &InFlight{active: make(map[uint64]activeRequest)}
+}
+
+// Middleware registers each request for the time it is served
+func (f *InFlight) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		id := f.nextID
+		f.nextID++
+		f.active[id] = activeRequest{
+			method:    r.Method,
+			path:      r.URL.Path,
+			requestID: middleware.GetReqID(r.Context()),
+			remote:    r.RemoteAddr,
+			start:     time.Now(),
+		}
+		f.mu.Unlock()
+
+		defer func() {
+			f.mu.Lock()
+			delete(f.active, id)
+			f.mu.Unlock()
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Len returns the number of requests being served
+func (f *InFlight) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.active)
+}
+
+// LogActive logs one line per request being served
+func (f *InFlight) LogActive(logger *slog.Logger) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, req := range f.active {
+		logger.Warn("request still in flight",
+			"method", req.method,
+			"path", req.path,
+			"request_id", req.requestID,
+			"remote_addr", req.remote,
+			"elapsed", time.Since(req.start).Round(time.Millisecond),
+		)
+	}
+}
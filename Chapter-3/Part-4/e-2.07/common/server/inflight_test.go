@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestInFlight(t *testing.T) {
+	f := NewInFlight()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := middleware.RequestID(f.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})))
+
+	var wg sync.WaitGroup
+	for _, path := range []string{"/a", "/b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, path, nil)
+			req.Header.Set(middleware.RequestIDHeader, "req"+path)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+		<-entered
+	}
+	if got := f.Len(); got != 2 {
+		t.Fatalf("Len() = %d with two requests being served, want 2", got)
+	}
+
+	var logs bytes.Buffer
+	f.LogActive(slog.New(slog.NewTextHandler(&logs, nil)))
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("LogActive logged %d lines, want 2:\n%s", len(lines), logs.String())
+	}
+	for _, want := range []string{"path=/a request_id=req/a", "path=/b request_id=req/b", "method=POST", "elapsed="} {
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("LogActive output misses %q:\n%s", want, logs.String())
+		}
+	}
+
+	close(release)
+	wg.Wait()
+	if got := f.Len(); got != 0 {
+		t.Fatalf("Len() = %d after the requests returned, want 0", got)
+	}
+}
+
+func TestInFlightPanic(t *testing.T) {
+	f := NewInFlight()
+	h := f.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	if got := f.Len(); got != 0 {
+		t.Fatalf("Len() = %d after a panic, want the request removed", got)
+	}
+
+	var logs bytes.Buffer
+	f.LogActive(slog.New(slog.NewTextHandler(&logs, nil)))
+	if logs.Len() != 0 {
+		t.Fatalf("LogActive without requests logged:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"common/utils"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LimitPolicy configures the rate limiting and load shedding of the public router
+type LimitPolicy struct {
+	// Rate and Burst size the token bucket of each client ip on each route,
+	// a zero Rate disables rate limiting
+	Rate  float64 `env:"RATE_LIMIT_RPS" default:"0"`
+	Burst int     `env:"RATE_LIMIT_BURST" default:"20"`
+
+	// MaxInFlight sheds requests with a 503 while that many are being served, 0 disables it
+	MaxInFlight int `env:"MAX_IN_FLIGHT" default:"0"`
+
+	// Exempt are route patterns (or paths) neither limited nor shed, e.g. probes mounted on the public router
+	Exempt []string `env:"RATE_LIMIT_EXEMPT" default:"/livez,/readyz,/startupz,/health,/metrics"`
+
+	// Routes overrides Rate and Burst for chi route patterns (e.g. /pingpong), set in code
+	Routes map[string]RateLimit
+}
+
+// RateLimit is a token bucket refilled with Rate tokens per second up to Burst
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (p LimitPolicy) validate() error {
+	limits := map[string]RateLimit{"default": {Rate: p.Rate, Burst: p.Burst}}
+	maps.Copy(limits, p.Routes)
+	for route, l := range limits {
+		if l.Rate < 0 || (l.Rate > 0 && l.Burst < 1) {
+			return fmt.Errorf("rate limit %s: rate must be positive with a burst of at least 1", route)
+		}
+	}
+	if p.MaxInFlight < 0 {
+		return fmt.Errorf("max in flight must not be negative")
+	}
+	return nil
+}
+
+func (p LimitPolicy) limitFor(route string) RateLimit {
+	if l, ok := p.Routes[route]; ok {
+		return l
+	}
+	return RateLimit{Rate: p.Rate, Burst: p.Burst}
+}
+
+func (p LimitPolicy) enabled() bool {
+	if p.MaxInFlight > 0 || p.Rate > 0 {
+		return true
+	}
+	for _, l := range p.Routes {
+		if l.Rate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// limiter applies a LimitPolicy, it runs before routing and resolves the route pattern itself
+type limiter struct {
+	policy   LimitPolicy
+	inFlight atomic.Int64
+	rejected *prometheus.CounterVec
+	now      func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	route  string
+	client string
+}
+
+// bucket is a token bucket, tokens are refilled lazily on take
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// sweepInterval is how often full (idle) buckets are dropped
+const sweepInterval = time.Minute
+
+func newLimiter(policy LimitPolicy, reg *prometheus.Registry) *limiter {
+	l := &limiter{
+		policy:    policy,
+		now:       time.Now,
+		buckets:   make(map[bucketKey]*bucket),
+		lastSweep: time.Now(),
+	}
+	if reg != nil {
+		l.rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_rejected_total",
+			Help: "HTTP requests rejected before reaching the handlers, by reason (rate_limited, overloaded).",
+		}, []string{"reason"})
+		reg.MustRegister(l.rejected)
+	}
+	return l
+}
+
+func (l *limiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routePattern(r)
+		if slices.Contains(l.policy.Exempt, route) || slices.Contains(l.policy.Exempt, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if l.policy.MaxInFlight > 0 {
+			if l.inFlight.Add(1) > int64(l.policy.MaxInFlight) {
+				l.inFlight.Add(-1)
+				l.reject("overloaded")
+				w.Header().Set("Retry-After", "1")
+				utils.WriteProblem(w, r, utils.NewProblem(http.StatusServiceUnavailable, utils.CodeOverloaded,
+					"the server is handling too many requests, retry later"))
+				return
+			}
+			defer l.inFlight.Add(-1)
+		}
+
+		if limit := l.policy.limitFor(route); limit.Rate > 0 {
+			ok, remaining, retryAfter, reset := l.take(bucketKey{route: route, client: ClientIP(r).String()}, limit)
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+			if !ok {
+				l.reject("rate_limited")
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+				utils.WriteProblem(w, r, utils.NewProblem(http.StatusTooManyRequests, utils.CodeRateLimited,
+					"too many requests, retry after the Retry-After delay"))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take removes a token from the bucket of key. It returns whether one was available, the tokens
+// left, the wait for the next token and the time until the bucket is full again.
+func (l *limiter) take(key bucketKey, limit RateLimit) (bool, int, time.Duration, time.Duration) {
+	now := l.now()
+	burst := float64(limit.Burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	retryAfter := time.Duration(max(0, 1-b.tokens) / limit.Rate * float64(time.Second))
+	reset := time.Duration((burst - b.tokens) / limit.Rate * float64(time.Second))
+	return allowed, int(b.tokens), retryAfter, reset
+}
+
+// sweep drops the buckets refilled to their burst, called with l.mu held
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		limit := l.policy.limitFor(key.route)
+		if b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *limiter) reject(reason string) {
+	if l.rejected != nil {
+		l.rejected.WithLabelValues(reason).Inc()
+	}
+}
+
+// routePattern resolves the chi pattern of r ahead of routing, so limits are per route and not
+// per raw path. Unknown routes share one bucket per client.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return r.URL.Path
+	}
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, path) {
+		return unmatchedRoute
+	}
+	// mounted sub routers report a pattern per level ("/api/*" then "/items/{id}")
+	return strings.ReplaceAll(strings.Join(tctx.RoutePatterns, ""), "/*/", "/")
+}
+
+// unmatchedRoute is the bucket route of requests matching no route
+const unmatchedRoute = "unmatched"
+
+func ceilSeconds(d time.Duration) int {
+	return max(0, int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testClock is the limiter clock, moved by hand
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter(policy LimitPolicy, reg *prometheus.Registry) (*limiter, *testClock) {
+	clock := &testClock{now: time.Unix(1_700_000_000, 0)}
+	l := newLimiter(policy, reg)
+	l.now = clock.Now
+	l.lastSweep = clock.now
+	return l, clock
+}
+
+// newLimitedRouter serves the limiter in front of a few routes, as NewRouter does
+func newLimitedRouter(l *limiter) *chi.Mux {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r := chi.NewRouter()
+	r.Use(l.middleware)
+	r.Get("/pingpong", ok)
+	r.Get("/counters/{name}", ok)
+	r.Get("/livez", ok)
+	return r
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, clock := newTestLimiter(LimitPolicy{}, nil)
+	key := bucketKey{route: "/pingpong", client: "192.0.2.1"}
+	limit := RateLimit{Rate: 2, Burst: 3}
+
+	// each step takes a token after advancing the clock
+	tests := []struct {
+		advance       time.Duration
+		wantOK        bool
+		wantRemaining int
+		wantRetry     time.Duration
+		wantReset     time.Duration
+	}{
+		{0, true, 2, 0, 500 * time.Millisecond},
+		{0, true, 1, 0, time.Second},
+		{0, true, 0, 500 * time.Millisecond, 1500 * time.Millisecond},
+		{0, false, 0, 500 * time.Millisecond, 1500 * time.Millisecond},
+		{250 * time.Millisecond, false, 0, 250 * time.Millisecond, 1250 * time.Millisecond},
+		{250 * time.Millisecond, true, 0, 500 * time.Millisecond, 1500 * time.Millisecond},
+		// a long idle period refills up to the burst only
+		{time.Hour, true, 2, 0, 500 * time.Millisecond},
+	}
+	for i, tt := range tests {
+		clock.Advance(tt.advance)
+		ok, remaining, retry, reset := l.take(key, limit)
+		if ok != tt.wantOK || remaining != tt.wantRemaining || retry != tt.wantRetry || reset != tt.wantReset {
+			t.Fatalf("step %d: take() = %v, %d, %v, %v, want %v, %d, %v, %v",
+				i, ok, remaining, retry, reset, tt.wantOK, tt.wantRemaining, tt.wantRetry, tt.wantReset)
+		}
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	l, clock := newTestLimiter(LimitPolicy{Rate: 1, Burst: 2}, reg)
+	r := newLimitedRouter(l)
+
+	get := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/pingpong", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		advance       time.Duration
+		remote        string
+		wantStatus    int
+		wantRemaining string
+		wantReset     string
+		wantRetry     string
+	}{
+		{0, "192.0.2.1:1", http.StatusOK, "1", "1", ""},
+		{0, "192.0.2.1:2", http.StatusOK, "0", "2", ""},
+		{0, "192.0.2.1:3", http.StatusTooManyRequests, "0", "2", "1"},
+		// another client has its own bucket
+		{0, "192.0.2.2:1", http.StatusOK, "1", "1", ""},
+		{time.Second, "192.0.2.1:4", http.StatusOK, "0", "2", ""},
+	}
+	for i, tt := range tests {
+		clock.Advance(tt.advance)
+		rec := get(tt.remote)
+		h := rec.Header()
+		if rec.Code != tt.wantStatus || h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != tt.wantRemaining ||
+			h.Get("RateLimit-Reset") != tt.wantReset || h.Get("Retry-After") != tt.wantRetry {
+			t.Fatalf("request %d: %d limit=%s remaining=%s reset=%s retry-after=%s, want %d limit=2 remaining=%s reset=%s retry-after=%s",
+				i, rec.Code, h.Get("RateLimit-Limit"), h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset"), h.Get("Retry-After"),
+				tt.wantStatus, tt.wantRemaining, tt.wantReset, tt.wantRetry)
+		}
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Content-Type") != "application/problem+json" {
+			t.Fatalf("request %d: 429 sent as %s", i, rec.Header().Get("Content-Type"))
+		}
+	}
+	if got := testutil.ToFloat64(l.rejected.WithLabelValues("rate_limited")); got != 1 {
+		t.Fatalf("rate_limited rejections = %v, want 1", got)
+	}
+}
+
+func TestRateLimitRoutes(t *testing.T) {
+	l, _ := newTestLimiter(LimitPolicy{
+		Rate:   1,
+		Burst:  1,
+		Routes: map[string]RateLimit{"/counters/{name}": {Rate: 1, Burst: 3}},
+	}, nil)
+	r := newLimitedRouter(l)
+
+	// every counter shares the bucket of its route pattern
+	var codes []int
+	for _, path := range []string{"/counters/a", "/counters/b", "/counters/c", "/counters/d"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		codes = append(codes, rec.Code)
+	}
+	want := []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	if !slices.Equal(codes, want) {
+		t.Fatalf("statuses = %v, want %v", codes, want)
+	}
+}
+
+func TestLimitExemptions(t *testing.T) {
+	l, _ := newTestLimiter(LimitPolicy{Rate: 1, Burst: 1, MaxInFlight: 1, Exempt: DefaultPolicy().Limits.Exempt}, nil)
+	r := newLimitedRouter(l)
+
+	// hold the only in-flight slot so every non exempt request is shed
+	l.inFlight.Add(1)
+	for range 3 {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("exempt /livez = %d, want 200", rec.Code)
+		}
+		if rec.Header().Get("RateLimit-Limit") != "" {
+			t.Fatal("exempt /livez got rate limit headers")
+		}
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pingpong", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("shed /pingpong = %d, Retry-After %q, want 503 and 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+// the probe paths are exempt by default, the value comes from the tag like every other default
+func TestDefaultExempt(t *testing.T) {
+	exempt := DefaultPolicy().Limits.Exempt
+	for _, path := range []string{"/livez", "/readyz", "/startupz", "/health", "/metrics"} {
+		if !slices.Contains(exempt, path) {
+			t.Errorf("default Exempt %v is missing %s", exempt, path)
+		}
+	}
+
+	// NewRouter with limits on still answers the public /livez of RouterConfig.Health
+	policy := DefaultPolicy()
+	policy.Limits.Rate, policy.Limits.Burst = 1, 1
+	r := NewRouter(RouterConfig{Policy: &policy, Health: NewProbes()})
+	for range 3 {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("/livez = %d with limits on, want 200", rec.Code)
+		}
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l, clock := newTestLimiter(LimitPolicy{Rate: 1, Burst: 5, Routes: map[string]RateLimit{"/slow": {Rate: 0.01, Burst: 5}}}, nil)
+	limit := l.policy.limitFor
+
+	l.take(bucketKey{route: "/pingpong", client: "a"}, limit("/pingpong"))
+	l.take(bucketKey{route: "/slow", client: "a"}, limit("/slow"))
+	if len(l.buckets) != 2 {
+		t.Fatalf("%d buckets, want 2", len(l.buckets))
+	}
+
+	// the next take sweeps: the /pingpong bucket is full again, /slow is not yet
+	clock.Advance(sweepInterval)
+	l.take(bucketKey{route: "/pingpong", client: "b"}, limit("/pingpong"))
+	if _, ok := l.buckets[bucketKey{route: "/pingpong", client: "a"}]; ok {
+		t.Fatal("the idle /pingpong bucket was not swept")
+	}
+	if _, ok := l.buckets[bucketKey{route: "/slow", client: "a"}]; !ok {
+		t.Fatal("the /slow bucket was swept before being full")
+	}
+
+	// no sweep before sweepInterval
+	clock.Advance(sweepInterval / 2)
+	l.take(bucketKey{route: "/pingpong", client: "c"}, limit("/pingpong"))
+	if len(l.buckets) != 3 {
+		t.Fatalf("%d buckets between two sweeps, want 3", len(l.buckets))
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	api := chi.NewRouter()
+	api.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	var got string
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			got = routePattern(req)
+			next.ServeHTTP(w, req)
+		})
+	})
+	r.Get("/pingpong", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/counters/{name}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/files/*", func(w http.ResponseWriter, r *http.Request) {})
+	r.Mount("/api", api)
+
+	tests := []struct {
+		method, target, want string
+	}{
+		{http.MethodGet, "/pingpong", "/pingpong"},
+		{http.MethodGet, "/counters/a", "/counters/{name}"},
+		{http.MethodGet, "/counters/a%2Fb", "/counters/{name}"},
+		{http.MethodGet, "/files/a/b", "/files/*"},
+		{http.MethodGet, "/api/items/7", "/api/items/{id}"},
+		{http.MethodGet, "/nope", unmatchedRoute},
+		{http.MethodPost, "/pingpong", unmatchedRoute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			got = ""
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.target, nil))
+			if got != tt.want {
+				t.Fatalf("routePattern = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// outside of a chi router the raw path is the route
+	req := httptest.NewRequest(http.MethodGet, "/x/y", nil)
+	if got := routePattern(req); got != "/x/y" {
+		t.Fatalf("routePattern without chi = %q", got)
+	}
+}
+
+func TestLimitPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  LimitPolicy
+		wantErr string
+	}{
+		{"disabled", LimitPolicy{}, ""},
+		{"rate and burst", LimitPolicy{Rate: 1, Burst: 1}, ""},
+		{"negative rate", LimitPolicy{Rate: -1, Burst: 1}, "rate must be positive"},
+		{"no burst", LimitPolicy{Rate: 1}, "rate must be positive"},
+		{"route without burst", LimitPolicy{Routes: map[string]RateLimit{"/x": {Rate: 1}}}, "/x"},
+		{"negative in flight", LimitPolicy{MaxInFlight: -1}, "max in flight"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validate() = %v, want an error about %q", err, tt.wantErr)
+			}
+		})
+	}
+}
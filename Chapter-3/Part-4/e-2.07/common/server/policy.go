@@ -0,0 +1,287 @@
+package server
+
+import (
+	"common/config"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/cors"
+)
+
+// Content security policies for the two kinds of services
+const (
+	// CSPAPI suits json/text apis, nothing is loaded and the responses cannot be framed
+	CSPAPI = "default-src 'none'; frame-ancestors 'none'"
+
+	// CSPHTMX suits server rendered htmx/tailwind pages: scripts from self and the unpkg
+	// and tailwind cdns, inline styles (htmx indicators, tailwind play cdn) and same origin hx-* requests
+	CSPHTMX = "default-src 'self'; script-src 'self' https://unpkg.com https://cdn.tailwindcss.com; " +
+		"style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; " +
+		"form-action 'self'; base-uri 'self'; frame-ancestors 'none'"
+)
+
+// Policy is the per service http policy applied by NewRouter, bound with common/config
+type Policy struct {
+	CORS     CORSPolicy
+	Security SecurityHeaders
+
+	// TrustedProxies are the CIDRs (e.g. 10.0.0.0/8, 10.1.2.3/32) of the ingress/load balancers
+	// allowed to set X-Forwarded-For and X-Real-IP. Forwarded headers from anyone else are ignored.
+	TrustedProxies []netip.Prefix `env:"TRUSTED_PROXIES"`
+
+	// CORSRoutes overrides CORS for the paths under a prefix (longest prefix wins), set in code
+	CORSRoutes map[string]CORSPolicy
+
+	Limits LimitPolicy
+}
+
+// CORSPolicy lists the cross origin callers allowed, no CORS headers are sent when AllowedOrigins is empty
+type CORSPolicy struct {
+	// AllowedOrigins are exact origins (https://app.example.com) or single wildcard patterns (https://*.example.com)
+	AllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,DELETE,OPTIONS,PATCH"`
+	AllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" default:"Accept,Authorization,Content-Type"`
+	AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" default:"false"`
+	MaxAge           time.Duration `env:"CORS_MAX_AGE" default:"5m"`
+}
+
+// SecurityHeaders are sent on every response, empty values disable the matching header
+type SecurityHeaders struct {
+	CSP            string        `env:"SECURITY_CSP" default:"default-src 'none'; frame-ancestors 'none'"`
+	HSTSMaxAge     time.Duration `env:"SECURITY_HSTS_MAX_AGE" default:"8760h"` // only sent over https, see IsHTTPS
+	FrameOptions   string        `env:"SECURITY_FRAME_OPTIONS" default:"DENY"`
+	ReferrerPolicy string        `env:"SECURITY_REFERRER_POLICY" default:"strict-origin-when-cross-origin"`
+}
+
+// DefaultPolicy is used when RouterConfig.Policy is nil. It is built from the default tags,
+// the single source of the defaults: same origin only, api CSP, no trusted proxy, no limits.
+func DefaultPolicy() Policy {
+	var p Policy
+	if err := config.Defaults(&p); err != nil {
+		panic(fmt.Sprintf("server: invalid policy defaults: %v", err))
+	}
+	return p
+}
+
+// Validate rejects policies trusting every origin with credentials and invalid limits
+func (p Policy) Validate() error {
+	var errs []error
+	if err := p.CORS.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	for prefix, c := range p.CORSRoutes {
+		if err := c.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("cors route %s: %w", prefix, err))
+		}
+	}
+	if err := p.Limits.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (c CORSPolicy) validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if strings.Count(origin, "*") > 1 {
+			return fmt.Errorf("origin %q: only one wildcard is supported", origin)
+		}
+		if c.AllowCredentials && (origin == "*" || strings.HasSuffix(origin, "://*")) {
+			return fmt.Errorf("origin %q allows every origin, it cannot be used with credentials", origin)
+		}
+	}
+	return nil
+}
+
+func (c CORSPolicy) handler() func(http.Handler) http.Handler {
+	if len(c.AllowedOrigins) == 0 {
+		// go-chi/cors treats an empty list as "*", keep the browser same origin policy instead
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return cors.Handler(cors.Options{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           int(c.MaxAge.Seconds()),
+	})
+}
+
+// corsMiddleware applies the CORS policy of the longest CORSRoutes prefix matching the path,
+// the default one otherwise. It runs before routing so preflight requests are covered too.
+func (p Policy) corsMiddleware() func(http.Handler) http.Handler {
+	prefixes := make([]string, 0, len(p.CORSRoutes))
+	for prefix := range p.CORSRoutes {
+		prefixes = append(prefixes, prefix)
+	}
+	// longest first
+	slices.SortFunc(prefixes, func(a, b string) int { return len(b) - len(a) })
+
+	return func(next http.Handler) http.Handler {
+		def := p.CORS.handler()(next)
+		routes := make(map[string]http.Handler, len(prefixes))
+		for _, prefix := range prefixes {
+			routes[prefix] = p.CORSRoutes[prefix].handler()(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					routes[prefix].ServeHTTP(w, r)
+					return
+				}
+			}
+			def.ServeHTTP(w, r)
+		})
+	}
+}
+
+// middleware sets the configured headers before the handler runs, handlers may override them
+func (s SecurityHeaders) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if s.CSP != "" {
+			h.Set("Content-Security-Policy", s.CSP)
+		}
+		if s.HSTSMaxAge > 0 && IsHTTPS(r) {
+			h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(s.HSTSMaxAge.Seconds()))+"; includeSubDomains")
+		}
+		if s.FrameOptions != "" {
+			h.Set("X-Frame-Options", s.FrameOptions)
+		}
+		if s.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", s.ReferrerPolicy)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithCSP overrides the Content-Security-Policy of a route group, e.g. r.With(server.WithCSP(server.CSPHTMX))
+func WithCSP(csp string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Security-Policy", csp)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type (
+	clientIPKey       struct{}
+	forwardedHTTPSKey struct{}
+)
+
+// realIP replaces r.RemoteAddr by the client address when the request comes through a trusted proxy.
+// X-Forwarded-For is read right to left and the first hop which is not a trusted proxy is the client,
+// X-Real-IP is used when there is no X-Forwarded-For. X-Forwarded-Proto is only believed from a
+// trusted proxy too.
+func realIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, ok := parseAddr(r.RemoteAddr)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := peer
+			ctx := r.Context()
+			if isTrusted(peer) {
+				client = forwardedClient(r.Header, isTrusted, peer)
+				if forwardedProto(r.Header) == "https" {
+					ctx = context.WithValue(ctx, forwardedHTTPSKey{}, true)
+				}
+			}
+			if client != peer {
+				r.RemoteAddr = client.String()
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, clientIPKey{}, client)))
+		})
+	}
+}
+
+func forwardedClient(h http.Header, isTrusted func(netip.Addr) bool, peer netip.Addr) netip.Addr {
+	var hops []string
+	for _, value := range h.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	if len(hops) == 0 {
+		if addr, ok := parseAddr(h.Get("X-Real-IP")); ok {
+			return addr
+		}
+		return peer
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			// a garbled hop cannot be trusted, stop at the last valid one
+			break
+		}
+		client = addr
+		if !isTrusted(addr) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedProto returns the scheme set by the nearest proxy, the last X-Forwarded-Proto value
+func forwardedProto(h http.Header) string {
+	values := h.Values("X-Forwarded-Proto")
+	if len(values) == 0 {
+		return ""
+	}
+	protos := strings.Split(values[len(values)-1], ",")
+	return strings.ToLower(strings.TrimSpace(protos[len(protos)-1]))
+}
+
+// parseAddr accepts an ip or an ip:port
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// ClientIP returns the client address resolved by the router, honouring the trusted proxies
+func ClientIP(r *http.Request) netip.Addr {
+	if addr, ok := r.Context().Value(clientIPKey{}).(netip.Addr); ok {
+		return addr
+	}
+	addr, _ := parseAddr(r.RemoteAddr)
+	return addr
+}
+
+// IsHTTPS reports whether the client reached the service over https, directly or through a
+// trusted proxy terminating TLS (X-Forwarded-Proto: https)
+func IsHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	https, _ := r.Context().Value(forwardedHTTPSKey{}).(bool)
+	return https
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	p := DefaultPolicy()
+
+	if p.Security.CSP != CSPAPI {
+		t.Errorf("default CSP = %q, want CSPAPI", p.Security.CSP)
+	}
+	if p.Security.HSTSMaxAge != 365*24*time.Hour || p.Security.FrameOptions != "DENY" {
+		t.Errorf("default security headers = %+v", p.Security)
+	}
+	if len(p.CORS.AllowedOrigins) != 0 || len(p.TrustedProxies) != 0 {
+		t.Errorf("default policy allows origins %v or trusts proxies %v", p.CORS.AllowedOrigins, p.TrustedProxies)
+	}
+	if p.Limits.enabled() {
+		t.Error("default policy enables the limits")
+	}
+	if err := p.Validate(); err != nil {
+		t.Errorf("default policy does not validate: %v", err)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	const hsts = "max-age=31536000; includeSubDomains"
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name     string
+		remote   string
+		tls      bool
+		proto    []string // X-Forwarded-Proto values
+		wantHSTS string
+	}{
+		{"plain http", "192.0.2.1:1234", false, nil, ""},
+		{"tls", "192.0.2.1:1234", true, nil, hsts},
+		{"trusted proxy terminating tls", "10.0.0.5:1234", false, []string{"https"}, hsts},
+		{"trusted proxy over http", "10.0.0.5:1234", false, []string{"http"}, ""},
+		{"nearest proxy wins", "10.0.0.5:1234", false, []string{"https, http"}, ""},
+		{"spoofed by the client", "192.0.2.1:1234", false, []string{"https"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := realIP(trusted)(DefaultPolicy().Security.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for _, proto := range tt.proto {
+				req.Header.Add("X-Forwarded-Proto", proto)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Strict-Transport-Security"); got != tt.wantHSTS {
+				t.Errorf("Strict-Transport-Security = %q, want %q", got, tt.wantHSTS)
+			}
+			for header, want := range map[string]string{
+				"Content-Security-Policy": CSPAPI,
+				"X-Content-Type-Options":  "nosniff",
+				"X-Frame-Options":         "DENY",
+				"Referrer-Policy":         "strict-origin-when-cross-origin",
+			} {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	tests := []struct {
+		name       string
+		remote     string
+		xff        []string
+		realIP     string
+		want       string
+		wantRemote string // r.RemoteAddr seen by the handler, remote when empty
+	}{
+		{name: "direct", remote: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "direct ignores xff", remote: "192.0.2.1:1234", xff: []string{"203.0.113.9"}, want: "192.0.2.1"},
+		{name: "direct ignores x-real-ip", remote: "192.0.2.1:1234", realIP: "203.0.113.9", want: "192.0.2.1"},
+		{name: "one trusted proxy", remote: "10.0.0.5:1234", xff: []string{"203.0.113.9"}, want: "203.0.113.9", wantRemote: "203.0.113.9"},
+		{name: "proxy chain", remote: "10.0.0.5:1234", xff: []string{"203.0.113.9, 10.1.1.1"}, want: "203.0.113.9", wantRemote: "203.0.113.9"},
+		{name: "chain over several headers", remote: "10.0.0.5:1234", xff: []string{"203.0.113.9", "10.1.1.1"}, want: "203.0.113.9", wantRemote: "203.0.113.9"},
+		// the client prepends a fake hop, the first untrusted hop from the right is still the real client
+		{name: "spoofed hop", remote: "10.0.0.5:1234", xff: []string{"1.2.3.4, 203.0.113.9"}, want: "203.0.113.9", wantRemote: "203.0.113.9"},
+		{name: "spoofed trusted hop", remote: "10.0.0.5:1234", xff: []string{"10.9.9.9, 203.0.113.9"}, want: "203.0.113.9", wantRemote: "203.0.113.9"},
+		{name: "garbled hop", remote: "10.0.0.5:1234", xff: []string{"203.0.113.9, garbage, 10.1.1.1"}, want: "10.1.1.1", wantRemote: "10.1.1.1"},
+		{name: "only trusted hops", remote: "10.0.0.5:1234", xff: []string{"10.1.1.1"}, want: "10.1.1.1", wantRemote: "10.1.1.1"},
+		{name: "x-real-ip from trusted proxy", remote: "10.0.0.5:1234", realIP: "203.0.113.9", want: "203.0.113.9", wantRemote: "203.0.113.9"},
+		{name: "xff wins over x-real-ip", remote: "10.0.0.5:1234", xff: []string{"203.0.113.9"}, realIP: "198.51.100.1", want: "203.0.113.9", wantRemote: "203.0.113.9"},
+		{name: "ipv6 proxy", remote: "[fd00::1]:1234", xff: []string{"2001:db8::9"}, want: "2001:db8::9", wantRemote: "2001:db8::9"},
+		{name: "ipv4 mapped peer", remote: "[::ffff:10.0.0.5]:1234", xff: []string{"203.0.113.9:5555"}, want: "203.0.113.9", wantRemote: "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got netip.Addr
+			var gotRemote string
+			h := realIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+				gotRemote = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for _, xff := range tt.xff {
+				req.Header.Add("X-Forwarded-For", xff)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got.String() != tt.want {
+				t.Errorf("ClientIP = %s, want %s", got, tt.want)
+			}
+			wantRemote := tt.wantRemote
+			if wantRemote == "" {
+				wantRemote = tt.remote
+			}
+			if gotRemote != wantRemote {
+				t.Errorf("RemoteAddr = %s, want %s", gotRemote, wantRemote)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutMiddleware(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	if got := ClientIP(req); got.String() != "192.0.2.1" {
+		t.Fatalf("ClientIP = %s, want the peer address", got)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cors    CORSPolicy
+		wantErr bool
+	}{
+		{"exact origin", CORSPolicy{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, false},
+		{"subdomain wildcard", CORSPolicy{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}, false},
+		{"any origin without credentials", CORSPolicy{AllowedOrigins: []string{"*"}}, false},
+		{"any origin with credentials", CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, true},
+		{"any https origin with credentials", CORSPolicy{AllowedOrigins: []string{"https://*"}, AllowCredentials: true}, true},
+		{"two wildcards", CORSPolicy{AllowedOrigins: []string{"https://*.*.example.com"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultPolicy()
+			p.CORS = tt.cors
+			if err := p.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+			p = DefaultPolicy()
+			p.CORSRoutes = map[string]CORSPolicy{"/api": tt.cors}
+			if err := p.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() with a route override = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCORSRoutes(t *testing.T) {
+	p := DefaultPolicy()
+	p.CORSRoutes = map[string]CORSPolicy{
+		"/api":        {AllowedOrigins: []string{"https://api.example.com"}, AllowedMethods: []string{"GET"}},
+		"/api/public": {AllowedOrigins: []string{"https://*"}, AllowedMethods: []string{"GET"}},
+	}
+	h := p.corsMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		path, origin, want string
+	}{
+		{"/pingpong", "https://api.example.com", ""},
+		{"/api/items", "https://api.example.com", "https://api.example.com"},
+		{"/api/items", "https://evil.example.com", ""},
+		{"/api/public/items", "https://evil.example.com", "https://evil.example.com"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s from %s: Access-Control-Allow-Origin = %q, want %q", tt.path, tt.origin, got, tt.want)
+		}
+	}
+	if !slices.Contains(DefaultPolicy().CORS.AllowedMethods, http.MethodGet) {
+		t.Error("default CORS methods do not include GET")
+	}
+}
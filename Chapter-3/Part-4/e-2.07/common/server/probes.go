@@ -0,0 +1,230 @@
+package server
+
+import (
+	"common/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Check reports the health of a single dependency, a nil error means healthy
+type Check func(ctx context.Context) error
+
+// ProbeKind selects the probe endpoints a check contributes to (can be combined)
+type ProbeKind uint8
+
+const (
+	Liveness ProbeKind = 1 << iota
+	Readiness
+	Startup
+)
+
+const (
+	defaultCheckTimeout = 2 * time.Second
+	defaultCheckTTL     = 2 * time.Second
+)
+
+// CheckOptions configures a registered check. The zero value runs it on readiness only.
+type CheckOptions struct {
+	Kinds    ProbeKind     // default Readiness
+	Timeout  time.Duration // default 2s
+	CacheTTL time.Duration // how long a result is reused between probe calls (default 2s)
+}
+
+// CheckResult is the per-check detail returned by the probe endpoints
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type namedCheck struct {
+	name string
+	fn   Check
+	opts CheckOptions
+
+	now    func() time.Time
+	mu     sync.Mutex
+	last   CheckResult
+	expiry time.Time
+}
+
+// Probes is the registry behind /livez, /readyz and /startupz
+type Probes struct {
+	mu       sync.RWMutex
+	checks   []*namedCheck
+	draining atomic.Bool
+	started  atomic.Bool // latched once every startup check passed
+	now      func() time.Time
+}
+
+func NewProbes() *Probes {
+	return &Probes{now: time.Now}
+}
+
+// Register adds a named check
+func (p *Probes) Register(name string, fn Check, opts CheckOptions) {
+	if opts.Kinds == 0 {
+		opts.Kinds = Readiness
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultCheckTimeout
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = defaultCheckTTL
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checks = append(p.checks, &namedCheck{name: name, fn: fn, opts: opts, now: p.now})
+}
+
+// SetDraining flips readiness off for good, used as soon as the shutdown begins
+func (p *Probes) SetDraining() {
+	p.draining.Store(true)
+}
+
+func (p *Probes) Draining() bool {
+	return p.draining.Load()
+}
+
+// Mount adds the probe routes to r. /health is kept as an alias of /livez.
+func (p *Probes) Mount(r chi.Router) {
+	r.Get("/livez", p.LivenessHandler)
+	r.Get("/health", p.LivenessHandler)
+	r.Get("/readyz", p.ReadinessHandler)
+	r.Get("/startupz", p.StartupHandler)
+}
+
+func (p *Probes) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	p.respond(w, r, Liveness, "")
+}
+
+func (p *Probes) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	status := ""
+	if p.Draining() {
+		status = "draining"
+	}
+	p.respond(w, r, Readiness, status)
+}
+
+func (p *Probes) StartupHandler(w http.ResponseWriter, r *http.Request) {
+	if p.started.Load() {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"status": "ok"})
+		return
+	}
+	if ok := p.respond(w, r, Startup, ""); ok {
+		p.started.Store(true)
+	}
+}
+
+// respond runs the checks of the given kind and writes the aggregated result.
+// A non empty forcedStatus fails the probe regardless of the checks.
+func (p *Probes) respond(w http.ResponseWriter, r *http.Request, kind ProbeKind, forcedStatus string) bool {
+	results, ok := p.run(r.Context(), kind)
+
+	status := "ok"
+	if !ok {
+		status = "fail"
+	}
+	if forcedStatus != "" {
+		status = forcedStatus
+		ok = false
+	}
+
+	code := http.StatusOK
+	if !ok {
+		code = http.StatusServiceUnavailable
+	}
+	utils.WriteJSON(w, code, utils.Envelope{
+		"status": status,
+		"checks": results,
+	})
+	return ok
+}
+
+func (p *Probes) run(ctx context.Context, kind ProbeKind) (map[string]CheckResult, bool) {
+	p.mu.RLock()
+	checks := make([]*namedCheck, 0, len(p.checks))
+	for _, c := range p.checks {
+		if c.opts.Kinds&kind != 0 {
+			checks = append(checks, c)
+		}
+	}
+	p.mu.RUnlock()
+
+	results := make(map[string]CheckResult, len(checks))
+	var resMu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := c.result(ctx)
+			resMu.Lock()
+			results[c.name] = res
+			resMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	ok := true
+	for _, res := range results {
+		if res.Status != "ok" {
+			ok = false
+		}
+	}
+	return results, ok
+}
+
+// result returns the cached result if still fresh, otherwise runs the check
+func (c *namedCheck) result(ctx context.Context) CheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Before(c.expiry) {
+		return c.last
+	}
+
+	// the result is shared with other callers, don't let one aborted request fail it
+	checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.opts.Timeout)
+	defer cancel()
+
+	res := CheckResult{Status: "ok", CheckedAt: now}
+	if err := c.fn(checkCtx); err != nil {
+		res.Status = "fail"
+		res.Error = err.Error()
+	}
+	res.Duration = c.now().Sub(now).String()
+
+	c.last = res
+	c.expiry = now.Add(c.opts.CacheTTL)
+	return res
+}
+
+// HTTPCheck reports whether url answers with a non 5xx status
+func HTTPCheck(client *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+		return nil
+	}
+}
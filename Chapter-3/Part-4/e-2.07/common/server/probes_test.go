@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeCheck counts its calls and fails with err when set
+type fakeCheck struct {
+	calls atomic.Int32
+	err   atomic.Pointer[error]
+}
+
+func (f *fakeCheck) check(ctx context.Context) error {
+	f.calls.Add(1)
+	if err := f.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func (f *fakeCheck) fail(err error) {
+	f.err.Store(&err)
+}
+
+// probeBody is the JSON body of the probe endpoints
+type probeBody struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func probe(t *testing.T, h http.HandlerFunc, ctx context.Context) (int, probeBody) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	var body probeBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid probe body %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, body
+}
+
+// newTestProbes returns probes whose clock only moves with the returned func
+func newTestProbes() (*Probes, func(time.Duration)) {
+	p := NewProbes()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	var elapsed atomic.Int64
+	p.now = func() time.Time { return now.Add(time.Duration(elapsed.Load())) }
+	return p, func(d time.Duration) { elapsed.Add(int64(d)) }
+}
+
+func TestProbeKinds(t *testing.T) {
+	p, _ := newTestProbes()
+	var live, db, migrations fakeCheck
+	db.fail(errors.New("connection refused"))
+	p.Register("live", live.check, CheckOptions{Kinds: Liveness})
+	p.Register("db", db.check, CheckOptions{Kinds: Readiness | Startup})
+	p.Register("migrations", migrations.check, CheckOptions{}) // readiness by default
+	ctx := context.Background()
+
+	code, body := probe(t, p.LivenessHandler, ctx)
+	if code != http.StatusOK || body.Status != "ok" || len(body.Checks) != 1 || body.Checks["live"].Status != "ok" {
+		t.Fatalf("livez = %d %+v, want 200 with the live check only", code, body)
+	}
+
+	code, body = probe(t, p.ReadinessHandler, ctx)
+	if code != http.StatusServiceUnavailable || body.Status != "fail" || len(body.Checks) != 2 {
+		t.Fatalf("readyz = %d %+v, want 503 with the db and migrations checks", code, body)
+	}
+	if res := body.Checks["db"]; res.Status != "fail" || res.Error != "connection refused" || res.CheckedAt.IsZero() || res.Duration == "" {
+		t.Fatalf("db result = %+v, want the failure detail", res)
+	}
+	if res := body.Checks["migrations"]; res.Status != "ok" || res.Error != "" {
+		t.Fatalf("migrations result = %+v, want ok", res)
+	}
+
+	code, body = probe(t, p.StartupHandler, ctx)
+	if code != http.StatusServiceUnavailable || len(body.Checks) != 1 {
+		t.Fatalf("startupz = %d %+v, want 503 with the db check", code, body)
+	}
+}
+
+func TestProbeCache(t *testing.T) {
+	p, advance := newTestProbes()
+	var db fakeCheck
+	p.Register("db", db.check, CheckOptions{CacheTTL: 5 * time.Second})
+	ctx := context.Background()
+
+	probe(t, p.ReadinessHandler, ctx)
+	advance(4 * time.Second)
+	db.fail(errors.New("down"))
+	if code, _ := probe(t, p.ReadinessHandler, ctx); code != http.StatusOK || db.calls.Load() != 1 {
+		t.Fatalf("readyz within the ttl = %d after %d calls, want the cached 200 after 1", code, db.calls.Load())
+	}
+
+	advance(time.Second)
+	if code, _ := probe(t, p.ReadinessHandler, ctx); code != http.StatusServiceUnavailable || db.calls.Load() != 2 {
+		t.Fatalf("readyz past the ttl = %d after %d calls, want 503 after 2", code, db.calls.Load())
+	}
+	// failures are cached as well
+	db.err.Store(nil)
+	if code, _ := probe(t, p.ReadinessHandler, ctx); code != http.StatusServiceUnavailable || db.calls.Load() != 2 {
+		t.Fatalf("readyz after a cached failure = %d after %d calls, want 503 after 2", code, db.calls.Load())
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	p := NewProbes()
+	p.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, CheckOptions{Timeout: 10 * time.Millisecond})
+
+	code, body := probe(t, p.ReadinessHandler, context.Background())
+	if code != http.StatusServiceUnavailable || body.Checks["slow"].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("readyz with a hanging check = %d %+v, want 503 deadline exceeded", code, body)
+	}
+}
+
+func TestProbeIgnoresCallerCancel(t *testing.T) {
+	p := NewProbes()
+	p.Register("db", func(ctx context.Context) error { return ctx.Err() }, CheckOptions{})
+
+	// the result is cached for every caller, a probe request going away does not fail it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if code, body := probe(t, p.ReadinessHandler, ctx); code != http.StatusOK {
+		t.Fatalf("readyz of a cancelled request = %d %+v, want 200", code, body)
+	}
+}
+
+func TestStartupLatch(t *testing.T) {
+	p, advance := newTestProbes()
+	var db fakeCheck
+	db.fail(errors.New("starting"))
+	p.Register("db", db.check, CheckOptions{Kinds: Startup | Readiness})
+	ctx := context.Background()
+
+	if code, _ := probe(t, p.StartupHandler, ctx); code != http.StatusServiceUnavailable {
+		t.Fatalf("startupz before the db is up = %d, want 503", code)
+	}
+	db.err.Store(nil)
+	advance(time.Minute)
+	if code, _ := probe(t, p.StartupHandler, ctx); code != http.StatusOK {
+		t.Fatalf("startupz once the db is up = %d, want 200", code)
+	}
+
+	// latched: later failures are readiness business, the check is not run again
+	db.fail(errors.New("down"))
+	advance(time.Minute)
+	calls := db.calls.Load()
+	code, body := probe(t, p.StartupHandler, ctx)
+	if code != http.StatusOK || body.Status != "ok" || db.calls.Load() != calls {
+		t.Fatalf("startupz after the latch = %d %+v with %d more calls, want 200 without running the check", code, body, db.calls.Load()-calls)
+	}
+	if code, _ := probe(t, p.ReadinessHandler, ctx); code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz with the db down = %d, want 503", code)
+	}
+}
+
+func TestProbeDraining(t *testing.T) {
+	p := NewProbes()
+	var db fakeCheck
+	p.Register("db", db.check, CheckOptions{Kinds: Liveness | Readiness})
+	ctx := context.Background()
+
+	if code, _ := probe(t, p.ReadinessHandler, ctx); code != http.StatusOK {
+		t.Fatalf("readyz = %d, want 200", code)
+	}
+	p.SetDraining()
+	if !p.Draining() {
+		t.Fatal("Draining() = false after SetDraining")
+	}
+	code, body := probe(t, p.ReadinessHandler, ctx)
+	if code != http.StatusServiceUnavailable || body.Status != "draining" || body.Checks["db"].Status != "ok" {
+		t.Fatalf("readyz while draining = %d %+v, want 503 draining with the passing check", code, body)
+	}
+	if code, _ := probe(t, p.LivenessHandler, ctx); code != http.StatusOK {
+		t.Fatalf("livez while draining = %d, want 200", code)
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	check := HTTPCheck(srv.Client(), srv.URL)
+
+	for _, tt := range []struct {
+		status  int
+		wantErr bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNotFound, false},
+		{http.StatusServiceUnavailable, true},
+	} {
+		status = tt.status
+		if err := check(context.Background()); (err != nil) != tt.wantErr {
+			t.Errorf("HTTPCheck of a %d = %v, want error %v", tt.status, err, tt.wantErr)
+		}
+	}
+
+	srv.Close()
+	if err := check(context.Background()); err == nil {
+		t.Error("HTTPCheck of a closed server: expected an error")
+	}
+}
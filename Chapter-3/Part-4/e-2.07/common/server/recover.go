@@ -0,0 +1,36 @@
+package server
+
+import (
+	"common/logging"
+	"common/utils"
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverer turns a panic of the handlers into a 500 problem, logged with its stack.
+// http.ErrAbortHandler is re-panicked, net/http aborts the response silently.
+func recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			logging.FromContext(r.Context()).Error("panic serving request", "panic", rec, "stack", string(debug.Stack()))
+			utils.InternalServerError(w, r)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// mountErrorHandlers makes the router answer unknown routes and methods with problems
+func mountErrorHandlers(r interface {
+	NotFound(http.HandlerFunc)
+	MethodNotAllowed(http.HandlerFunc)
+}) {
+	r.NotFound(utils.NotFoundHandler)
+	r.MethodNotAllowed(utils.MethodNotAllowedHandler)
+}
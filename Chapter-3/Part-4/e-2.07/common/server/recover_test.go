@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"common/utils"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// panicRouter is the public router with a route panicking with v, logging to the returned buffer
+func panicRouter(v any) (http.Handler, *bytes.Buffer) {
+	var logs bytes.Buffer
+	r := NewRouter(RouterConfig{Logger: slog.New(slog.NewJSONHandler(&logs, nil))})
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic(v)
+	})
+	return r, &logs
+}
+
+func TestRecovererProblem(t *testing.T) {
+	r, logs := panicRouter("boom")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, utils.MediaProblem) {
+		t.Fatalf("Content-Type = %q, want %s", ct, utils.MediaProblem)
+	}
+	var p utils.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("invalid problem %q: %v", rec.Body.String(), err)
+	}
+	reqID := rec.Header().Get(middleware.RequestIDHeader)
+	if p.Status != http.StatusInternalServerError || p.Code != utils.CodeInternal || p.Instance != "/panic" || p.RequestID == "" || p.RequestID != reqID {
+		t.Fatalf("problem = %+v, want an internal error with the request id %q", p, reqID)
+	}
+	// the panic value and the stack are for the logs only
+	for _, leak := range []string{"boom", "goroutine", "recover_test.go"} {
+		if strings.Contains(rec.Body.String(), leak) {
+			t.Fatalf("body leaks %q: %s", leak, rec.Body.String())
+		}
+	}
+
+	var entry struct {
+		Msg       string `json:"msg"`
+		Panic     string `json:"panic"`
+		Stack     string `json:"stack"`
+		RequestID string `json:"request_id"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Msg == "panic serving request" {
+			break
+		}
+	}
+	if entry.Msg != "panic serving request" || entry.Panic != "boom" || !strings.Contains(entry.Stack, "goroutine") || entry.RequestID != reqID {
+		t.Fatalf("log entry = %+v, want the panic with its stack and request id", entry)
+	}
+}
+
+func TestRecovererRepanicsAbortHandler(t *testing.T) {
+	r, logs := panicRouter(http.ErrAbortHandler)
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != http.ErrAbortHandler {
+				t.Fatalf("recovered %v, want http.ErrAbortHandler re-panicked", rec)
+			}
+		}()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		t.Fatalf("ServeHTTP returned %d, want a panic", rec.Code)
+	}()
+	if strings.Contains(logs.String(), "panic serving request") {
+		t.Fatalf("aborted request logged as a panic: %s", logs.String())
+	}
+
+	// net/http drops the connection without a response
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL + "/panic")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("GET /panic = %d, want the connection aborted", resp.StatusCode)
+	}
+}
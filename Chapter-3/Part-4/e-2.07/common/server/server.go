@@ -1,27 +1,20 @@
 package server
 
 import (
+	"common/logging"
+	"common/metrics"
+	"common/tracing"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-	_ "github.com/joho/godotenv/autoload"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-func New(defaultPort int) *http.Server {
-	port, _ := strconv.Atoi(os.Getenv("PORT"))
-	if defaultPort == 0 {
-
-		port = defaultPort
-		log.Printf("No PORT environment variable detected, starting server on default port: %d", port)
-	}
-
+func New(port int) *http.Server {
 	return &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
 		IdleTimeout:  time.Minute,
@@ -30,15 +23,57 @@ func New(defaultPort int) *http.Server {
 	}
 }
 
-func NewRouter() *chi.Mux {
+// RouterConfig holds the optional features of the public router.
+// Probes, /metrics and the admin endpoints are served by NewAdminRouter on their own listener.
+type RouterConfig struct {
+	Logger *slog.Logger // base of the request loggers (slog.Default() if nil)
+	Policy *Policy      // cors, security headers and trusted proxies (DefaultPolicy() if nil)
+
+	// Health serves its /livez on the public port too when set, for the services depending on
+	// this one which must not reach the admin listener. Readiness and startup stay admin only.
+	Health *Probes
+
+	// Metrics enables the request instrumentation when set
+	Metrics *prometheus.Registry
+	// InFlight tracks the requests for the shutdown logs when set (see boot.HTTPServer.Track)
+	InFlight *InFlight
+}
+
+// NewRouter returns the public router, it panics if cfg.Policy does not validate
+func NewRouter(cfg RouterConfig) *chi.Mux {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	policy := DefaultPolicy()
+	if cfg.Policy != nil {
+		policy = *cfg.Policy
+	}
+	if err := policy.Validate(); err != nil {
+		panic(fmt.Sprintf("server: invalid http policy: %v", err))
+	}
+
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
+	r.Use(middleware.RequestID)
+	if cfg.InFlight != nil {
+		r.Use(cfg.InFlight.Middleware)
+	}
+	r.Use(realIP(policy.TrustedProxies))
+	r.Use(tracing.Middleware)
+	if cfg.Metrics != nil {
+		r.Use(metrics.NewHTTPMetrics(cfg.Metrics).Middleware)
+	}
+	r.Use(logging.Middleware(logger))
+	r.Use(recoverer)
+	r.Use(policy.Security.middleware)
+	r.Use(policy.corsMiddleware())
+	if policy.Limits.enabled() {
+		r.Use(newLimiter(policy.Limits, cfg.Metrics).middleware)
+	}
+	mountErrorHandlers(r)
+	if cfg.Health != nil {
+		r.Get("/livez", cfg.Health.LivenessHandler)
+	}
+
 	return r
 }
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterHealth(t *testing.T) {
+	probes := NewProbes()
+	probes.Register("db", func(ctx context.Context) error { return errors.New("down") }, CheckOptions{})
+
+	tests := []struct {
+		name   string
+		health *Probes
+		path   string
+		want   int
+	}{
+		{"no health", nil, "/livez", http.StatusNotFound},
+		{"livez", probes, "/livez", http.StatusOK},
+		{"readyz stays on the admin listener", probes, "/readyz", http.StatusNotFound},
+		{"startupz stays on the admin listener", probes, "/startupz", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter(RouterConfig{Health: tt.health})
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+			}
+		})
+	}
+}
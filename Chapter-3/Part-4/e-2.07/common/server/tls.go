@@ -0,0 +1,277 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadCheckInterval bounds how often the certificate files are stat'ed during handshakes
+var reloadCheckInterval = time.Second
+
+// TLSConfig enables https on the server, bound with common/config. Files are usually
+// mounted from a kubernetes tls Secret and are reloaded when they change.
+type TLSConfig struct {
+	CertFile string `env:"TLS_CERT_FILE"` // e.g. /etc/tls/tls.crt, plaintext http when empty
+	KeyFile  string `env:"TLS_KEY_FILE"`  // e.g. /etc/tls/tls.key
+
+	// ClientCAFile enables mutual TLS: client certificates are verified against this bundle
+	ClientCAFile string `env:"TLS_CLIENT_CA_FILE"`
+	// ClientAuth is require (no certificate, no connection) or optional (verified only if sent,
+	// e.g. when the kubelet probes the same port)
+	ClientAuth string `env:"TLS_CLIENT_AUTH" default:"require"`
+}
+
+// Enabled reports whether a certificate is configured
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// ConfigureTLS sets srv.TLSConfig when cfg is enabled, boot.HTTPServer then serves https.
+// The certificate and the client CA bundle are reloaded on the first handshake after they change.
+func ConfigureTLS(srv *http.Server, cfg TLSConfig) error {
+	if !cfg.Enabled() {
+		if cfg.ClientCAFile != "" {
+			return errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	certs, err := newKeyPairReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return certs.get(), nil },
+		// ServeTLS only adds the ALPN protocols to its own copy, GetConfigForClient has to repeat them
+		NextProtos: nextProtos(srv),
+	}
+	if cfg.ClientCAFile == "" {
+		srv.TLSConfig = base
+		return nil
+	}
+
+	switch cfg.ClientAuth {
+	case "require", "":
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		base.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return fmt.Errorf("unknown TLS_CLIENT_AUTH %q (expected require or optional)", cfg.ClientAuth)
+	}
+	cas, err := newCAReloader(cfg.ClientCAFile)
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: base.NextProtos,
+		// a per connection config picks up a rotated client CA bundle
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			conf := base.Clone()
+			conf.ClientCAs = cas.get()
+			return conf, nil
+		},
+	}
+	return nil
+}
+
+// nextProtos returns the ALPN protocols http.Server.ServeTLS advertises for srv
+func nextProtos(srv *http.Server) []string {
+	http2 := true
+	if srv.Protocols != nil {
+		http2 = srv.Protocols.HTTP2()
+	} else if srv.TLSNextProto != nil {
+		// the historic way of disabling http/2
+		_, http2 = srv.TLSNextProto["h2"]
+	}
+	if http2 {
+		return []string{"h2", "http/1.1"}
+	}
+	return []string{"http/1.1"}
+}
+
+// ClientTLS configures the outbound side of (mutual) TLS, the zero value keeps the system defaults
+type ClientTLS struct {
+	CAFile     string // bundle trusted for the server certificate, system roots when empty
+	CertFile   string // client certificate presented for mutual TLS
+	KeyFile    string
+	ServerName string // overrides the name verified in the server certificate
+}
+
+// NewClientTLSConfig returns the tls.Config for http.Transport.TLSClientConfig, nil when c is the zero value.
+// The client certificate and the CA bundle are reloaded when their files change.
+func NewClientTLSConfig(c ClientTLS) (*tls.Config, error) {
+	if c == (ClientTLS{}) {
+		return nil, nil
+	}
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if c.CAFile != "" {
+		cas, err := newCAReloader(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		// RootCAs is read once per tls.Config, the default verification is replaced by
+		// the same checks against the current bundle
+		conf.InsecureSkipVerify = true
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyServer(cs, cas.get(), c.ServerName)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, errors.New("client certificate and key must be set together")
+		}
+		certs, err := newKeyPairReloader(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certs.get(), nil
+		}
+	}
+	return conf, nil
+}
+
+// verifyServer does what crypto/tls does with RootCAs: verify the chain sent by the server
+// and that it is valid for the name dialed (or ServerName).
+func verifyServer(cs tls.ConnectionState, roots *x509.CertPool, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: server sent no certificate")
+	}
+	name := cs.ServerName
+	if serverName != "" {
+		name = serverName
+	}
+	if name == "" {
+		// the SNI is empty when dialing an ip, without a name the certificate cannot be matched
+		return errors.New("tls: no server name to verify, set the client ServerName")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       name,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return &tls.CertificateVerificationError{UnverifiedCertificates: cs.PeerCertificates, Err: err}
+	}
+	return nil
+}
+
+// fileReloader holds a value parsed from files and parses them again when their
+// modification time or size changes. A failed reload keeps the previous value, which
+// covers the window where a Secret rotation has written the certificate but not yet the key.
+type fileReloader[T any] struct {
+	files []string
+	parse func() (T, error)
+
+	mu        sync.Mutex
+	value     T
+	stamp     string
+	checkedAt time.Time
+}
+
+func newFileReloader[T any](parse func() (T, error), files ...string) (*fileReloader[T], error) {
+	fr := &fileReloader[T]{
+		files: files,
+		parse: parse,
+	}
+	stamp, err := fr.currentStamp()
+	if err != nil {
+		return nil, err
+	}
+	value, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	fr.value, fr.stamp, fr.checkedAt = value, stamp, time.Now()
+	return fr, nil
+}
+
+func (fr *fileReloader[T]) currentStamp() (string, error) {
+	var stamp string
+	for _, file := range fr.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", file, info.ModTime().UnixNano(), info.Size())
+	}
+	return stamp, nil
+}
+
+func (fr *fileReloader[T]) get() T {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if time.Since(fr.checkedAt) < reloadCheckInterval {
+		return fr.value
+	}
+	fr.checkedAt = time.Now()
+
+	stamp, err := fr.currentStamp()
+	if err != nil || stamp == fr.stamp {
+		return fr.value
+	}
+	value, err := fr.parse()
+	if err != nil {
+		slog.Warn("could not reload tls files, keeping the previous ones", "files", fr.files, "error", err)
+		return fr.value
+	}
+	fr.value, fr.stamp = value, stamp
+	slog.Info("tls files reloaded", "files", fr.files)
+	return fr.value
+}
+
+func newKeyPairReloader(certFile, keyFile string) (*fileReloader[*tls.Certificate], error) {
+	fr, err := newFileReloader(func() (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load key pair %s: %w", certFile, err)
+		}
+		return &cert, nil
+	}, certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	return fr, nil
+}
+
+func newCAReloader(caFile string) (*fileReloader[*x509.CertPool], error) {
+	fr, err := newFileReloader(func() (*x509.CertPool, error) {
+		return loadCAPool(caFile)
+	}, caFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	return fr, nil
+}
+
+func loadCAPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in CA bundle %s", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,397 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues short lived certificates for the tls tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a pem certificate and key for name, a server certificate for the dns names
+// when client is false
+func (ca *testCA) issue(t *testing.T, name string, client bool) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{name},
+	}
+	if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		tmpl.DNSNames = nil
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+var fileGeneration int
+
+// writeFile writes data with a new modification time, so a rewrite is always seen as a change
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fileGeneration++
+	mtime := time.Now().Add(time.Duration(fileGeneration) * time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tlsFiles lays out the files of a mounted tls Secret
+type tlsFiles struct {
+	dir                string
+	cert, key, ca, cca string
+}
+
+func newTLSFiles(t *testing.T) tlsFiles {
+	dir := t.TempDir()
+	return tlsFiles{
+		dir:  dir,
+		cert: filepath.Join(dir, "tls.crt"),
+		key:  filepath.Join(dir, "tls.key"),
+		ca:   filepath.Join(dir, "ca.crt"),
+		cca:  filepath.Join(dir, "client-ca.crt"),
+	}
+}
+
+// startTLSServer serves the negotiated protocol and the client certificate name over https
+func startTLSServer(t *testing.T, cfg TLSConfig) string {
+	t.Helper()
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := "-"
+		if len(r.TLS.VerifiedChains) > 0 {
+			client = r.TLS.VerifiedChains[0][0].Subject.CommonName
+		}
+		fmt.Fprintf(w, "%s %s", r.Proto, client)
+	})}
+	if err := ConfigureTLS(srv, cfg); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeTLS(ln, "", "")
+	t.Cleanup(func() { srv.Close() })
+	return "https://localhost:" + fmt.Sprint(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// get does a request on a new connection, so every call is a full handshake
+func get(url string, c ClientTLS) (string, error) {
+	conf, err := NewClientTLSConfig(c)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   conf,
+			ForceAttemptHTTP2: true,
+			DisableKeepAlives: true,
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func withReloadCheckInterval(t *testing.T, d time.Duration) {
+	prev := reloadCheckInterval
+	reloadCheckInterval = d
+	t.Cleanup(func() { reloadCheckInterval = prev })
+}
+
+func TestConfigureTLSErrors(t *testing.T) {
+	files := newTLSFiles(t)
+	ca := newTestCA(t, "ca")
+	cert, key := ca.issue(t, "localhost", false)
+	writeFile(t, files.cert, cert)
+	writeFile(t, files.key, key)
+	writeFile(t, files.ca, ca.pem)
+
+	tests := []struct {
+		name    string
+		cfg     TLSConfig
+		wantErr string
+	}{
+		{"disabled", TLSConfig{}, ""},
+		{"client ca without certificate", TLSConfig{ClientCAFile: files.ca}, "requires TLS_CERT_FILE"},
+		{"certificate without key", TLSConfig{CertFile: files.cert}, "must be set together"},
+		{"missing files", TLSConfig{CertFile: files.cert, KeyFile: filepath.Join(files.dir, "nope")}, "no such file"},
+		{"unknown client auth", TLSConfig{CertFile: files.cert, KeyFile: files.key, ClientCAFile: files.ca, ClientAuth: "maybe"}, "unknown TLS_CLIENT_AUTH"},
+		{"tls", TLSConfig{CertFile: files.cert, KeyFile: files.key}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ConfigureTLS(&http.Server{}, tt.cfg)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ConfigureTLS() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	files := newTLSFiles(t)
+	ca := newTestCA(t, "ca")
+	other := newTestCA(t, "other")
+	cert, key := ca.issue(t, "localhost", false)
+	writeFile(t, files.cert, cert)
+	writeFile(t, files.key, key)
+	writeFile(t, files.ca, ca.pem)
+	writeFile(t, files.cca, ca.pem)
+
+	clientCert, clientKey := ca.issue(t, "log-output", true)
+	otherCert, otherKey := other.issue(t, "intruder", true)
+	for name, data := range map[string][]byte{
+		"client.crt": clientCert, "client.key": clientKey,
+		"other.crt": otherCert, "other.key": otherKey,
+	} {
+		writeFile(t, filepath.Join(files.dir, name), data)
+	}
+	valid := ClientTLS{
+		CAFile:   files.ca,
+		CertFile: filepath.Join(files.dir, "client.crt"),
+		KeyFile:  filepath.Join(files.dir, "client.key"),
+	}
+	untrusted := ClientTLS{
+		CAFile:   files.ca,
+		CertFile: filepath.Join(files.dir, "other.crt"),
+		KeyFile:  filepath.Join(files.dir, "other.key"),
+	}
+	anonymous := ClientTLS{CAFile: files.ca}
+
+	tests := []struct {
+		name       string
+		clientAuth string // "" when the server does not ask for a client certificate
+		client     ClientTLS
+		want       string // "" when the handshake must fail
+	}{
+		{"tls only", "", anonymous, "HTTP/2.0 -"},
+		{"require with certificate", "require", valid, "HTTP/2.0 log-output"},
+		{"require without certificate", "require", anonymous, ""},
+		{"require with an untrusted certificate", "require", untrusted, ""},
+		{"optional without certificate", "optional", anonymous, "HTTP/2.0 -"},
+		{"optional with certificate", "optional", valid, "HTTP/2.0 log-output"},
+		{"optional with an untrusted certificate", "optional", untrusted, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := TLSConfig{CertFile: files.cert, KeyFile: files.key}
+			if tt.clientAuth != "" {
+				cfg.ClientCAFile, cfg.ClientAuth = files.cca, tt.clientAuth
+			}
+			url := startTLSServer(t, cfg)
+
+			got, err := get(url, tt.client)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("request succeeded with %q, want a failed handshake", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// "HTTP/2.0" checks the ALPN protocols survive the per connection mTLS config
+			if got != tt.want {
+				t.Fatalf("response = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerReloadsFiles(t *testing.T) {
+	withReloadCheckInterval(t, 0)
+	files := newTLSFiles(t)
+	oldCA, newCA := newTestCA(t, "old"), newTestCA(t, "new")
+	cert, key := oldCA.issue(t, "localhost", false)
+	writeFile(t, files.cert, cert)
+	writeFile(t, files.key, key)
+	writeFile(t, files.ca, slices.Concat(oldCA.pem, newCA.pem))
+	writeFile(t, files.cca, oldCA.pem)
+
+	clientCert, clientKey := newCA.issue(t, "log-output", true)
+	writeFile(t, filepath.Join(files.dir, "client.crt"), clientCert)
+	writeFile(t, filepath.Join(files.dir, "client.key"), clientKey)
+	client := ClientTLS{
+		CAFile:   files.ca,
+		CertFile: filepath.Join(files.dir, "client.crt"),
+		KeyFile:  filepath.Join(files.dir, "client.key"),
+	}
+	url := startTLSServer(t, TLSConfig{CertFile: files.cert, KeyFile: files.key, ClientCAFile: files.cca})
+
+	if _, err := get(url, client); err == nil {
+		t.Fatal("a client certificate from the new CA was accepted before the client CA rotation")
+	}
+
+	// the client CA bundle and the server certificate rotate to the new CA
+	writeFile(t, files.cca, newCA.pem)
+	cert, key = newCA.issue(t, "localhost", false)
+	writeFile(t, files.cert, cert)
+	writeFile(t, files.key, key)
+
+	got, err := get(url, client)
+	if err != nil {
+		t.Fatalf("request after the rotation: %v", err)
+	}
+	if got != "HTTP/2.0 log-output" {
+		t.Fatalf("response = %q", got)
+	}
+}
+
+func TestClientReloadsCA(t *testing.T) {
+	withReloadCheckInterval(t, 0)
+	files := newTLSFiles(t)
+	oldCA, newCA := newTestCA(t, "old"), newTestCA(t, "new")
+	cert, key := oldCA.issue(t, "localhost", false)
+	writeFile(t, files.cert, cert)
+	writeFile(t, files.key, key)
+	writeFile(t, files.ca, oldCA.pem)
+	url := startTLSServer(t, TLSConfig{CertFile: files.cert, KeyFile: files.key})
+
+	conf, err := NewClientTLSConfig(ClientTLS{CAFile: files.ca})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: conf, DisableKeepAlives: true},
+	}
+	fetch := func() error {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := fetch(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server moves to the new CA first, the long lived client config does not trust it yet
+	cert, key = newCA.issue(t, "localhost", false)
+	writeFile(t, files.cert, cert)
+	writeFile(t, files.key, key)
+	if err := fetch(); err == nil {
+		t.Fatal("a server certificate from the new CA was trusted before the CA rotation")
+	}
+
+	writeFile(t, files.ca, newCA.pem)
+	if err := fetch(); err != nil {
+		t.Fatalf("request after the CA rotation: %v", err)
+	}
+}
+
+func TestClientVerifiesServerName(t *testing.T) {
+	files := newTLSFiles(t)
+	ca := newTestCA(t, "ca")
+	cert, key := ca.issue(t, "ping-pong-svc", false)
+	writeFile(t, files.cert, cert)
+	writeFile(t, files.key, key)
+	writeFile(t, files.ca, ca.pem)
+	url := startTLSServer(t, TLSConfig{CertFile: files.cert, KeyFile: files.key})
+
+	if _, err := get(url, ClientTLS{CAFile: files.ca}); err == nil {
+		t.Fatal("a certificate for ping-pong-svc was accepted for localhost")
+	}
+	if _, err := get(url, ClientTLS{CAFile: files.ca, ServerName: "ping-pong-svc"}); err != nil {
+		t.Fatalf("request with ServerName: %v", err)
+	}
+	ip := strings.Replace(url, "localhost", "127.0.0.1", 1)
+	if _, err := get(ip, ClientTLS{CAFile: files.ca}); err == nil || !strings.Contains(err.Error(), "no server name") {
+		t.Fatalf("request to an ip without ServerName = %v, want a missing server name error", err)
+	}
+}
+
+func TestNextProtos(t *testing.T) {
+	var http1 http.Protocols
+	http1.SetHTTP1(true)
+
+	tests := []struct {
+		name string
+		srv  *http.Server
+		want []string
+	}{
+		{"default", &http.Server{}, []string{"h2", "http/1.1"}},
+		{"protocols without http2", &http.Server{Protocols: &http1}, []string{"http/1.1"}},
+		{"empty TLSNextProto", &http.Server{TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){}}, []string{"http/1.1"}},
+	}
+	for _, tt := range tests {
+		if got := nextProtos(tt.srv); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: nextProtos = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
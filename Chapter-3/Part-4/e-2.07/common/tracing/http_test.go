@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a global provider recording the ended spans in memory and the W3C
+// propagator, both reset when the test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	resetGlobals(t)
+
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return sr
+}
+
+func TestTransportInjectsTraceparent(t *testing.T) {
+	sr := recordSpans(t)
+
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	ctx, parent := Tracer("test").Start(context.Background(), "handler")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/pingpong", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans recorded, want the client and parent spans", len(spans))
+	}
+	clientSpan := spans[0]
+	if clientSpan.SpanKind() != trace.SpanKindClient || clientSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("client span %s of kind %s, parent %s, want a client child of the handler span",
+			clientSpan.Name(), clientSpan.SpanKind(), clientSpan.Parent().SpanID())
+	}
+	want := "00-" + clientSpan.SpanContext().TraceID().String() + "-" + clientSpan.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Fatalf("traceparent = %q, want %q", traceparent, want)
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Fatal("the caller's request was modified")
+	}
+
+	req, _ = http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/fail", nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if span := sr.Ended()[2]; span.Status().Code != codes.Error || span.Parent().IsValid() {
+		t.Fatalf("span of a 502 = status %v parent %v, want an error root span", span.Status(), span.Parent())
+	}
+}
+
+func TestMiddlewareExtractsTraceparent(t *testing.T) {
+	sr := recordSpans(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	var handlerSpan trace.SpanContext
+	r.Get("/counters/{name}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+	})
+	r.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	req := httptest.NewRequest(http.MethodGet, "/counters/visits", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans recorded, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /counters/{name}" || span.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("span %q of kind %s, want a server span named after the route", span.Name(), span.SpanKind())
+	}
+	if span.SpanContext().TraceID().String() != traceID || span.Parent().SpanID().String() != spanID || !span.Parent().IsRemote() {
+		t.Fatalf("span trace %s parent %s, want a child of the remote %s-%s", span.SpanContext().TraceID(), span.Parent().SpanID(), traceID, spanID)
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Fatal("the handler context does not carry the server span")
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	if span := sr.Ended()[1]; span.Status().Code != codes.Error || span.Parent().IsValid() {
+		t.Fatalf("span of a 500 = status %v parent %v, want an error root span", span.Status(), span.Parent())
+	}
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// resetGlobals sets the provider and propagator Setup replaces back to the no-op ones of a
+// process without tracing set up when the test ends
+func resetGlobals(t *testing.T) {
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+}
+
+func TestSetupExporters(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          Config
+		wantErr      bool
+		wantProvider bool // an sdk provider is installed
+	}{
+		{"none", Config{Exporter: "none"}, false, false},
+		{"empty is none", Config{}, false, false},
+		{"stdout", Config{Exporter: "stdout", SampleRatio: 1}, false, true},
+		{"file", Config{Exporter: "file", File: filepath.Join(t.TempDir(), "traces.json"), SampleRatio: 1}, false, true},
+		{"file without a path", Config{Exporter: "file"}, true, false},
+		{"otlp", Config{Exporter: "OTLP", OTLPEndpoint: "http://127.0.0.1:4318", SampleRatio: 1}, false, true},
+		{"unknown", Config{Exporter: "jaeger"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetGlobals(t)
+			before := otel.GetTracerProvider()
+
+			shutdown, err := Setup(context.Background(), tt.cfg, "test")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			defer shutdown(ctx)
+
+			_, isSDK := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+			if isSDK != tt.wantProvider || (!tt.wantProvider && otel.GetTracerProvider() != before) {
+				t.Fatalf("sdk provider installed = %v, want %v", isSDK, tt.wantProvider)
+			}
+			// trace context is propagated with every exporter, none included
+			if fields := otel.GetTextMapPropagator().Fields(); !slices.Contains(fields, "traceparent") {
+				t.Fatalf("propagator fields = %v, want traceparent", fields)
+			}
+		})
+	}
+}
+
+func TestSetupNoneDoesNotRecord(t *testing.T) {
+	resetGlobals(t)
+	if _, err := Setup(context.Background(), Config{Exporter: "none"}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	_, span := Tracer("test").Start(context.Background(), "ignored")
+	defer span.End()
+	if span.IsRecording() {
+		t.Fatal("span recorded with the none exporter")
+	}
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	resetGlobals(t)
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), Config{Exporter: "file", File: path, SampleRatio: 1}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := Tracer("test").Start(context.Background(), "exported-span")
+	span.End()
+	// shutdown flushes the batch and closes the file
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"exported-span"`) || !strings.Contains(string(data), `"test"`) {
+		t.Fatalf("trace file misses the span or the service name:\n%s", data)
+	}
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes bounds the request bodies read by DecodeJSON
+const DefaultMaxBodyBytes = 1 << 20
+
+const (
+	CodeBodyTooLarge = "body_too_large"
+	CodeValidation   = "validation_failed"
+)
+
+// DecodeJSON reads the JSON body of r into dst and validates it (see Validate), the body
+// is limited to DefaultMaxBodyBytes. The returned error is a Problem for WriteError, or a
+// plain error when the validate tags of dst are malformed.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	return DecodeJSONLimit(w, r, dst, DefaultMaxBodyBytes)
+}
+
+// DecodeJSONLimit is DecodeJSON with a custom body limit. Unknown fields, trailing data
+// and bodies over maxBytes are rejected.
+func DecodeJSONLimit(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeProblem(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return NewProblem(http.StatusBadRequest, CodeBadRequest, "body must contain a single JSON value")
+	}
+
+	var fields FieldErrors
+	if err := Validate(dst); errors.As(err, &fields) {
+		return fields.Problem()
+	} else if err != nil {
+		return err // malformed tag, a 500 for WriteError
+	}
+	return nil
+}
+
+// decodeProblem explains a json.Decoder error to the client
+func decodeProblem(err error) Problem {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		maxErr    *http.MaxBytesError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return NewProblem(http.StatusBadRequest, CodeBadRequest, "body must not be empty")
+	case errors.As(err, &syntaxErr):
+		return NewProblem(http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return NewProblem(http.StatusBadRequest, CodeBadRequest, "malformed JSON")
+	case errors.As(err, &typeErr):
+		p := NewProblem(http.StatusBadRequest, CodeValidation, "invalid field type")
+		p.Errors = map[string]string{typeErr.Field: fmt.Sprintf("must be a %s", typeErr.Type)}
+		return p
+	case errors.As(err, &maxErr):
+		return NewProblem(http.StatusRequestEntityTooLarge, CodeBodyTooLarge, fmt.Sprintf("body must not be larger than %d bytes", maxErr.Limit))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		p := NewProblem(http.StatusBadRequest, CodeValidation, "unknown field")
+		p.Errors = map[string]string{field: "unknown field"}
+		return p
+	default:
+		return NewProblem(http.StatusBadRequest, CodeBadRequest, err.Error())
+	}
+}
+
+// WriteError sends err as a problem: Problem and FieldErrors values as they are,
+// any other error as an opaque 500 (the caller logs it)
+func WriteError(w http.ResponseWriter, r *http.Request, err error) error {
+	var (
+		p      Problem
+		fields FieldErrors
+	)
+	switch {
+	case errors.As(err, &p):
+		return WriteProblem(w, r, p)
+	case errors.As(err, &fields):
+		return WriteProblem(w, r, fields.Problem())
+	default:
+		return InternalServerError(w, r)
+	}
+}
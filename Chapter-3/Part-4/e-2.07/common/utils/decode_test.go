@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type input struct {
+		Name  string `json:"name" validate:"required"`
+		Count int    `json:"count"`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		limit      int64
+		wantStatus int // 0 when the body is accepted
+		wantCode   string
+		wantErrors map[string]string
+	}{
+		{name: "valid", body: `{"name":"a","count":2}`},
+		{name: "empty", body: ``, wantStatus: http.StatusBadRequest, wantCode: CodeBadRequest},
+		{name: "syntax error", body: `{"name":}`, wantStatus: http.StatusBadRequest, wantCode: CodeBadRequest},
+		{name: "truncated", body: `{"name":"a"`, wantStatus: http.StatusBadRequest, wantCode: CodeBadRequest},
+		{name: "wrong type", body: `{"name":"a","count":"2"}`, wantStatus: http.StatusBadRequest, wantCode: CodeValidation,
+			wantErrors: map[string]string{"count": "must be a int"}},
+		{name: "unknown field", body: `{"name":"a","admin":true}`, wantStatus: http.StatusBadRequest, wantCode: CodeValidation,
+			wantErrors: map[string]string{"admin": "unknown field"}},
+		{name: "trailing value", body: `{"name":"a"}{"name":"b"}`, wantStatus: http.StatusBadRequest, wantCode: CodeBadRequest},
+		{name: "trailing whitespace", body: "{\"name\":\"a\"}\n"},
+		{name: "too large", body: `{"name":"` + strings.Repeat("a", 64) + `"}`, limit: 32,
+			wantStatus: http.StatusRequestEntityTooLarge, wantCode: CodeBodyTooLarge},
+		{name: "at the limit", body: `{"name":"a"}`, limit: 12},
+		{name: "invalid", body: `{"count":1}`, wantStatus: http.StatusUnprocessableEntity, wantCode: CodeValidation,
+			wantErrors: map[string]string{"name": "is required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.limit
+			if limit == 0 {
+				limit = DefaultMaxBodyBytes
+			}
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			var dst input
+			err := DecodeJSONLimit(httptest.NewRecorder(), r, &dst, limit)
+
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("DecodeJSON() = %v, want nil", err)
+				}
+				if dst.Name == "" {
+					t.Fatal("the body was not decoded")
+				}
+				return
+			}
+			var p Problem
+			if !errors.As(err, &p) {
+				t.Fatalf("DecodeJSON() = %v, want a problem", err)
+			}
+			if p.Status != tt.wantStatus || p.Code != tt.wantCode {
+				t.Fatalf("problem = %d %s (%s), want %d %s", p.Status, p.Code, p.Detail, tt.wantStatus, tt.wantCode)
+			}
+			for field, msg := range tt.wantErrors {
+				if p.Errors[field] != msg {
+					t.Fatalf("errors = %v, want %s: %s", p.Errors, field, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeJSONMalformedTag(t *testing.T) {
+	var dst struct {
+		Name string `json:"name" validate:"email"`
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"a"}`))
+	err := DecodeJSON(httptest.NewRecorder(), r, &dst)
+
+	var p Problem
+	if err == nil || errors.As(err, &p) {
+		t.Fatalf("DecodeJSON() = %v, want a plain error", err)
+	}
+	rec := httptest.NewRecorder()
+	WriteError(rec, r, err)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("WriteError status = %d, want 500", rec.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	MediaJSON    = "application/json"
+	MediaProblem = "application/problem+json"
+	MediaText    = "text/plain"
+)
+
+// Negotiate returns the offer preferred by the Accept header of r. Without an Accept header
+// the first offer wins, "" means none of the offers is acceptable.
+func Negotiate(r *http.Request, offers ...string) string {
+	header := r.Header.Values("Accept")
+	if len(header) == 0 || len(offers) == 0 {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	best, bestQ, bestSpecificity := "", 0.0, -1
+	for _, offer := range offers {
+		q, specificity := acceptQuality(header, offer)
+		// ties go to the more specific range first, then to the order of the offers
+		if q > bestQ || (q == bestQ && q > 0 && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the q value the most specific matching range gives to offer
+func acceptQuality(header []string, offer string) (float64, int) {
+	offerType, offerSub, _ := strings.Cut(offer, "/")
+	q, specificity := 0.0, -1
+	for _, line := range header {
+		for _, accepted := range strings.Split(line, ",") {
+			mediaRange, params, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+			typ, sub, _ := strings.Cut(strings.TrimSpace(mediaRange), "/")
+
+			s := 0
+			switch {
+			case typ == offerType && sub == offerSub:
+				s = 2
+			case typ == offerType && sub == "*":
+				s = 1
+			case typ == "*" && sub == "*":
+				s = 0
+			default:
+				continue
+			}
+			if s > specificity {
+				q, specificity = parseQ(params), s
+			}
+		}
+	}
+	return q, specificity
+}
+
+func parseQ(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.TrimSpace(key) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
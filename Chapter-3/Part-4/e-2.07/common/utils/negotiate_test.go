@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{MediaJSON, MediaText}
+
+	tests := []struct {
+		name   string
+		accept []string // Accept header lines
+		offers []string
+		want   string
+	}{
+		{"no accept header", nil, offers, MediaJSON},
+		{"no offers", []string{MediaJSON}, nil, ""},
+		{"exact", []string{MediaText}, offers, MediaText},
+		{"any", []string{"*/*"}, offers, MediaJSON},
+		{"subtype wildcard", []string{"text/*"}, offers, MediaText},
+		{"browser", []string{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}, offers, MediaJSON},
+		{"q values", []string{"application/json;q=0.5, text/plain;q=0.9"}, offers, MediaText},
+		{"q value with spaces", []string{"application/json ; q = 0.5, text/plain"}, offers, MediaText},
+		{"q zero refuses", []string{"application/json;q=0"}, offers, ""},
+		{"specific range wins over wildcard", []string{"*/*, application/json;q=0"}, offers, MediaText},
+		{"tie goes to the more specific range", []string{"text/plain;q=0.5, */*;q=0.5"}, offers, MediaText},
+		{"tie goes to the offer order", []string{"application/json, text/plain"}, offers, MediaJSON},
+		{"several header lines", []string{"application/json;q=0.1", "text/plain"}, offers, MediaText},
+		{"invalid q", []string{"application/json;q=abc, text/plain;q=0.1"}, offers, MediaText},
+		{"q out of range", []string{"application/json;q=2, text/plain;q=0.1"}, offers, MediaText},
+		{"nothing acceptable", []string{"image/png"}, offers, ""},
+		{"parameters other than q", []string{"text/plain;charset=utf-8"}, offers, MediaText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, line := range tt.accept {
+				r.Header.Add("Accept", line)
+			}
+			if got := Negotiate(r, tt.offers...); got != tt.want {
+				t.Fatalf("Negotiate(%q, %q) = %q, want %q", tt.accept, tt.offers, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Parser converts the raw value of a path or query parameter
+type Parser[T any] func(raw string) (T, error)
+
+// PathParam reads the chi URL parameter name, it fails if the parameter is empty.
+// Errors are 400 problems naming the parameter.
+func PathParam[T any](r *http.Request, name string, parse Parser[T]) (T, error) {
+	raw := chi.URLParam(r, name)
+	if raw == "" {
+		var zero T
+		return zero, InvalidParameter(name, "is required")
+	}
+	return parseParam(name, raw, parse)
+}
+
+// QueryParam reads the query parameter name, def is returned when it is absent or empty
+func QueryParam[T any](r *http.Request, name string, def T, parse Parser[T]) (T, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	return parseParam(name, raw, parse)
+}
+
+func parseParam[T any](name string, raw string, parse Parser[T]) (T, error) {
+	v, err := parse(raw)
+	if err != nil {
+		return v, InvalidParameter(name, err.Error())
+	}
+	return v, nil
+}
+
+// InvalidParameter is the 400 problem of a parameter failing a check, for the checks
+// spanning several parameters
+func InvalidParameter(name string, msg string) Problem {
+	p := NewProblem(http.StatusBadRequest, CodeInvalidParameter, fmt.Sprintf("invalid parameter %q", name))
+	p.Errors = map[string]string{name: msg}
+	return p
+}
+
+// Int parses a base 10 integer in [min, max]
+func Int(min, max int) Parser[int] {
+	return func(raw string) (int, error) {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return 0, fmt.Errorf("must be an integer")
+		}
+		if n < min || n > max {
+			return 0, fmt.Errorf("must be between %d and %d", min, max)
+		}
+		return n, nil
+	}
+}
+
+// Bool parses the values accepted by strconv.ParseBool
+func Bool() Parser[bool] {
+	return func(raw string) (bool, error) {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	}
+}
+
+// Time parses a time in layout, e.g. time.RFC3339
+func Time(layout string) Parser[time.Time] {
+	return func(raw string) (time.Time, error) {
+		t, err := time.Parse(layout, raw)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("must be a time formatted as %s", layout)
+		}
+		return t, nil
+	}
+}
+
+// Duration parses a time.ParseDuration value
+func Duration() Parser[time.Duration] {
+	return func(raw string) (time.Duration, error) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, fmt.Errorf("must be a duration such as 30s or 5m")
+		}
+		return d, nil
+	}
+}
+
+// Enum accepts one of values
+func Enum[T ~string](values ...T) Parser[T] {
+	return func(raw string) (T, error) {
+		if v := T(raw); slices.Contains(values, v) {
+			return v, nil
+		}
+		names := make([]string, len(values))
+		for i, v := range values {
+			names[i] = string(v)
+		}
+		return "", fmt.Errorf("must be one of %s", strings.Join(names, ", "))
+	}
+}
+
+// Page is a limit/offset window read by ReadPage
+type Page struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// PageOptions bound the pages accepted by ReadPage
+type PageOptions struct {
+	DefaultLimit int // limit when the query has none
+	MaxLimit     int
+}
+
+// ReadPage reads the limit and offset query parameters
+func ReadPage(r *http.Request, opts PageOptions) (Page, error) {
+	limit, err := QueryParam(r, "limit", opts.DefaultLimit, Int(1, opts.MaxLimit))
+	if err != nil {
+		return Page{}, err
+	}
+	offset, err := QueryParam(r, "offset", 0, Int(0, math.MaxInt))
+	if err != nil {
+		return Page{}, err
+	}
+	return Page{Limit: limit, Offset: offset}, nil
+}
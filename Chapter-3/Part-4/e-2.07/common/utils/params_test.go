@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// paramError returns the message of the invalid parameter name in err, "" when err is nil
+func paramError(t *testing.T, err error, name string) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var p Problem
+	if !errors.As(err, &p) || p.Status != http.StatusBadRequest || p.Code != CodeInvalidParameter {
+		t.Fatalf("error = %v, want an invalid parameter problem", err)
+	}
+	return p.Errors[name]
+}
+
+func TestQueryParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr string
+	}{
+		{"", 10, ""},
+		{"n=", 10, ""},
+		{"n=5", 5, ""},
+		{"n=1", 1, ""},
+		{"n=100", 100, ""},
+		{"n=0", 0, "must be between 1 and 100"},
+		{"n=101", 0, "must be between 1 and 100"},
+		{"n=-3", 0, "must be between 1 and 100"},
+		{"n=abc", 0, "must be an integer"},
+		{"n=1.5", 0, "must be an integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+			got, err := QueryParam(r, "n", 10, Int(1, 100))
+			if msg := paramError(t, err, "n"); msg != tt.wantErr {
+				t.Fatalf("error = %q, want %q", msg, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Fatalf("QueryParam = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathParam(t *testing.T) {
+	withParam := func(value string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("name", value)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	}
+	kinds := Enum[string]("a", "b")
+
+	if got, err := PathParam(withParam("a"), "name", kinds); err != nil || got != "a" {
+		t.Fatalf("PathParam = %q, %v", got, err)
+	}
+	_, err := PathParam(withParam(""), "name", kinds)
+	if msg := paramError(t, err, "name"); msg != "is required" {
+		t.Fatalf("empty parameter: %q", msg)
+	}
+	_, err = PathParam(withParam("c"), "name", kinds)
+	if msg := paramError(t, err, "name"); msg != "must be one of a, b" {
+		t.Fatalf("invalid parameter: %q", msg)
+	}
+}
+
+func TestParsers(t *testing.T) {
+	if b, err := Bool()("true"); err != nil || !b {
+		t.Errorf("Bool(true) = %v, %v", b, err)
+	}
+	if _, err := Bool()("yes"); err == nil {
+		t.Error("Bool(yes): expected an error")
+	}
+	if d, err := Duration()("5m"); err != nil || d != 5*time.Minute {
+		t.Errorf("Duration(5m) = %v, %v", d, err)
+	}
+	if _, err := Duration()("5"); err == nil {
+		t.Error("Duration(5): expected an error")
+	}
+	if ts, err := Time(time.RFC3339)("2024-01-02T03:04:05Z"); err != nil || !ts.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Time = %v, %v", ts, err)
+	}
+	if _, err := Time(time.RFC3339)("2024-01-02"); err == nil {
+		t.Error("Time(2024-01-02): expected an error")
+	}
+}
+
+func TestReadPage(t *testing.T) {
+	opts := PageOptions{DefaultLimit: 50, MaxLimit: 500}
+
+	tests := []struct {
+		query     string
+		want      Page
+		wantParam string // parameter reported invalid
+	}{
+		{"", Page{Limit: 50, Offset: 0}, ""},
+		{"limit=1&offset=0", Page{Limit: 1, Offset: 0}, ""},
+		{"limit=500&offset=1000", Page{Limit: 500, Offset: 1000}, ""},
+		{"limit=0", Page{}, "limit"},
+		{"limit=501", Page{}, "limit"},
+		{"limit=-1", Page{}, "limit"},
+		{"offset=-1", Page{}, "offset"},
+		{"offset=x", Page{}, "offset"},
+		{"offset=99999999999999999999", Page{}, "offset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := ReadPage(httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil), opts)
+			if tt.wantParam != "" {
+				if msg := paramError(t, err, tt.wantParam); msg == "" {
+					t.Fatalf("ReadPage() = %v, want %s reported invalid", err, tt.wantParam)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("ReadPage() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
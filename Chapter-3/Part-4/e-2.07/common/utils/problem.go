@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Stable error codes, clients match on Problem.Code rather than on the detail text
+const (
+	CodeBadRequest       = "bad_request"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeUnauthenticated  = "unauthenticated"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeNotAcceptable    = "not_acceptable"
+	CodeConflict         = "conflict"
+	CodeIdempotencyReuse = "idempotency_key_reused"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+	CodeUpstream         = "upstream_error"
+	CodeUnavailable      = "service_unavailable"
+	CodeOverloaded       = "overloaded"
+)
+
+// problemTypePrefix makes the type URI of a problem from its code
+const problemTypePrefix = "urn:problem-type:"
+
+// Problem is an RFC 7807 error response, sent as application/problem+json
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// extension members
+	Code      string            `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"` // per field messages of invalid input
+}
+
+// NewProblem returns the problem of the given status and code, titled after the status
+func NewProblem(status int, code string, detail string) Problem {
+	return Problem{
+		Type:   problemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func (p Problem) Error() string {
+	msg := p.Title
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	if len(p.Errors) > 0 {
+		msg += " [" + FieldErrors(p.Errors).list() + "]"
+	}
+	return fmt.Sprintf("%s (%s)", msg, p.Code)
+}
+
+// WriteProblem sends p as problem+json, or as text to clients that only accept text/plain.
+// Instance and RequestID default to the request path and chi request id.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p Problem) error {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = middleware.GetReqID(r.Context())
+	}
+
+	if Negotiate(r, MediaProblem, MediaJSON, MediaText) == MediaText {
+		return Write(w, p.Status, fmt.Sprintf("%d %s\n", p.Status, p.Error()))
+	}
+	return writeJSON(w, p.Status, MediaProblem, p)
+}
+
+// BadRequest sends a 400 problem with the given code
+func BadRequest(w http.ResponseWriter, r *http.Request, code string, detail string) error {
+	return WriteProblem(w, r, NewProblem(http.StatusBadRequest, code, detail))
+}
+
+// NotFound sends a 404 problem
+func NotFound(w http.ResponseWriter, r *http.Request, detail string) error {
+	return WriteProblem(w, r, NewProblem(http.StatusNotFound, CodeNotFound, detail))
+}
+
+// Conflict sends a 409 problem with the given code
+func Conflict(w http.ResponseWriter, r *http.Request, code string, detail string) error {
+	return WriteProblem(w, r, NewProblem(http.StatusConflict, code, detail))
+}
+
+// InternalServerError sends a 500 problem. The cause is not exposed to the client,
+// the caller logs it.
+func InternalServerError(w http.ResponseWriter, r *http.Request) error {
+	return WriteProblem(w, r, NewProblem(http.StatusInternalServerError, CodeInternal, "the server could not process the request"))
+}
+
+// BadGateway sends a 502 problem for a failed call to another service
+func BadGateway(w http.ResponseWriter, r *http.Request, detail string) error {
+	return WriteProblem(w, r, NewProblem(http.StatusBadGateway, CodeUpstream, detail))
+}
+
+// ServiceUnavailable sends a 503 problem
+func ServiceUnavailable(w http.ResponseWriter, r *http.Request, detail string) error {
+	return WriteProblem(w, r, NewProblem(http.StatusServiceUnavailable, CodeUnavailable, detail))
+}
+
+// NotFoundHandler is the problem+json replacement of http.NotFound for routers
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	NotFound(w, r, fmt.Sprintf("no route for %s", r.URL.Path))
+}
+
+// MethodNotAllowedHandler answers requests with a method the route does not serve
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, NewProblem(http.StatusMethodNotAllowed, CodeMethodNotAllowed,
+		fmt.Sprintf("method %s is not allowed on %s", r.Method, r.URL.Path)))
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestWriteProblem(t *testing.T) {
+	p := NewProblem(http.StatusBadRequest, CodeInvalidParameter, "invalid limit")
+	p.Errors = map[string]string{"limit": "must be at most 100"}
+
+	tests := []struct {
+		name       string
+		accept     string
+		wantType   string
+		wantBody   string // text responses only
+		wantStatus int
+	}{
+		{"no accept header", "", MediaProblem, "", http.StatusBadRequest},
+		{"problem", MediaProblem, MediaProblem, "", http.StatusBadRequest},
+		{"json", MediaJSON, MediaProblem, "", http.StatusBadRequest},
+		{"text", MediaText, MediaText + "; charset=utf-8", "400 Bad Request: invalid limit [limit: must be at most 100] (invalid_parameter)\n", http.StatusBadRequest},
+		// a client accepting none of the offers still gets the problem instead of a 406
+		{"nothing acceptable", "image/png", MediaProblem, "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/counters/a?limit=500", nil)
+			r = r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, "req-1"))
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			if err := WriteProblem(rec, r, p); err != nil {
+				t.Fatal(err)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Fatalf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if tt.wantBody != "" {
+				if got := rec.Body.String(); got != tt.wantBody {
+					t.Fatalf("body = %q, want %q", got, tt.wantBody)
+				}
+				return
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]any{
+				"type":       "urn:problem-type:invalid_parameter",
+				"title":      "Bad Request",
+				"status":     float64(http.StatusBadRequest),
+				"detail":     "invalid limit",
+				"instance":   "/counters/a",
+				"code":       "invalid_parameter",
+				"request_id": "req-1",
+				"errors":     map[string]any{"limit": "must be at most 100"},
+			}
+			if !reflect.DeepEqual(body, want) {
+				t.Fatalf("body = %v, want %v", body, want)
+			}
+		})
+	}
+}
+
+func TestProblemOmitsEmptyMembers(t *testing.T) {
+	js, err := json.Marshal(NewProblem(http.StatusNotFound, CodeNotFound, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"urn:problem-type:not_found","title":"Not Found","status":404,"code":"not_found"}`
+	if string(js) != want {
+		t.Fatalf("problem = %s, want %s", js, want)
+	}
+}
+
+func TestProblemHandlers(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(w http.ResponseWriter, r *http.Request)
+		status int
+		code   string
+	}{
+		{"not found", NotFoundHandler, http.StatusNotFound, CodeNotFound},
+		{"method not allowed", MethodNotAllowedHandler, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"internal", func(w http.ResponseWriter, r *http.Request) { InternalServerError(w, r) }, http.StatusInternalServerError, CodeInternal},
+		{"bad gateway", func(w http.ResponseWriter, r *http.Request) { BadGateway(w, r, "pingpong is down") }, http.StatusBadGateway, CodeUpstream},
+		{"conflict", func(w http.ResponseWriter, r *http.Request) { Conflict(w, r, CodeIdempotencyReuse, "key reused") }, http.StatusConflict, CodeIdempotencyReuse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.write(rec, httptest.NewRequest(http.MethodGet, "/x", nil))
+
+			var p Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.status || p.Status != tt.status || p.Code != tt.code || p.Type != problemTypePrefix+tt.code {
+				t.Fatalf("got %d %+v, want status %d and code %s", rec.Code, p, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+// the 406 of a handler whose formats the client refuses is itself sent as problem+json
+func TestNotAcceptableProblem(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "image/png")
+	rec := httptest.NewRecorder()
+	if Negotiate(r, MediaText, MediaJSON) != "" {
+		t.Fatal("Negotiate accepted an offer for image/png")
+	}
+	WriteProblem(rec, r, NewProblem(http.StatusNotAcceptable, CodeNotAcceptable, "available formats: text/plain, application/json"))
+
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusNotAcceptable || rec.Header().Get("Content-Type") != MediaProblem || p.Code != CodeNotAcceptable {
+		t.Fatalf("got %d %s %+v", rec.Code, rec.Header().Get("Content-Type"), p)
+	}
+}
@@ -2,47 +2,58 @@ package utils
 
 import (
 	"encoding/json"
-	"errors"
+	"io"
 	"net/http"
-	"strconv"
-
-	"github.com/go-chi/chi/v5"
 )
 
 type Envelope map[string]any
 
+// Write sends data as a plain text response with the given status
 func Write(w http.ResponseWriter, status int, data string) error {
-	w.Header().Set("Content-Type", "plain/text")
-	_, err := w.Write([]byte(data))
-	if err != nil {
-		return err
-	}
-	return nil
+	w.Header().Set("Content-Type", MediaText+"; charset=utf-8")
+	w.WriteHeader(status)
+	_, err := io.WriteString(w, data)
+	return err
 }
 
+// WriteJSON sends data as a JSON response with the given status. A marshalling error
+// is returned before anything is written, the caller can still send an error response.
 func WriteJSON(w http.ResponseWriter, status int, data Envelope) error {
+	return writeJSON(w, status, MediaJSON, data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, contentType string, data any) error {
 	js, err := json.MarshalIndent(data, "", " ")
 	if err != nil {
 		return err
 	}
 
 	js = append(js, '\n')
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
-	w.Write(js)
-	return nil
+	_, err = w.Write(js)
+	return err
 }
 
-func ReadParam(r *http.Request) (int64, error) {
-	idParam := chi.URLParam(r, "n")
-	if idParam == "" {
-		return 0, errors.New("invalid parameter")
-	}
+// OK sends data with 200 OK
+func OK(w http.ResponseWriter, data Envelope) error {
+	return WriteJSON(w, http.StatusOK, data)
+}
 
-	id, err := strconv.ParseInt(idParam, 10, 64)
-	if err != nil {
-		return 0, errors.New("invalid parameter type")
+// Created sends data with 201 Created and the Location of the new resource, if any
+func Created(w http.ResponseWriter, location string, data Envelope) error {
+	if location != "" {
+		w.Header().Set("Location", location)
 	}
+	return WriteJSON(w, http.StatusCreated, data)
+}
+
+// Accepted sends data with 202 Accepted
+func Accepted(w http.ResponseWriter, data Envelope) error {
+	return WriteJSON(w, http.StatusAccepted, data)
+}
 
-	return id, nil
+// NoContent sends an empty 204 No Content
+func NoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
 }
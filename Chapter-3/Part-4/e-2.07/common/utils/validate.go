@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldErrors maps the JSON names of invalid fields to what is wrong with them
+type FieldErrors map[string]string
+
+func (fe FieldErrors) Error() string {
+	return "invalid input: " + fe.list()
+}
+
+// list returns the errors sorted by field
+func (fe FieldErrors) list() string {
+	msgs := make([]string, 0, len(fe))
+	for _, field := range slices.Sorted(maps.Keys(fe)) {
+		msgs = append(msgs, field+": "+fe[field])
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// Problem returns the 422 problem listing the field errors
+func (fe FieldErrors) Problem() Problem {
+	p := NewProblem(http.StatusUnprocessableEntity, CodeValidation, "the request contains invalid fields")
+	p.Errors = fe
+	return p
+}
+
+// Validate checks the validate struct tags of v, a struct or a pointer to one:
+//
+//	required    not the zero value (not nil for pointers, slices and maps)
+//	min=N max=N length of strings (in runes), slices and maps, value of numbers
+//	oneof=a b c the value is one of the listed ones
+//
+// Nested structs are validated too, their errors are keyed "parent.child". Fields are named
+// after their json tag. It returns nil, FieldErrors, or a plain error for a malformed tag,
+// a bug of the caller and not of the input.
+func Validate(v any) error {
+	errs := FieldErrors{}
+	if err := validateStruct(reflect.ValueOf(v), "", errs); err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateStruct(v reflect.Value, prefix string, errs FieldErrors) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		name = prefix + name
+		fv := v.Field(i)
+
+		if tag := field.Tag.Get("validate"); tag != "" {
+			msg, err := checkField(fv, tag, t.Name()+"."+field.Name)
+			if err != nil {
+				return err
+			}
+			if msg != "" {
+				errs[name] = msg
+				continue
+			}
+		}
+		if err := validateStruct(fv, name+".", errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// checkField returns what is wrong with v according to tag, or "".
+// The error reports a malformed tag.
+func checkField(v reflect.Value, tag string, where string) (string, error) {
+	rules := strings.Split(tag, ",")
+	if slices.Contains(rules, "required") && v.IsZero() {
+		return "is required", nil
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil // optional and not set
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return "", fmt.Errorf("utils: invalid %s in validate tag of %s: %q", name, where, arg)
+			}
+			if msg, err := checkBound(v, name, limit, where); msg != "" || err != nil {
+				return msg, err
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			if !slices.Contains(allowed, fmt.Sprint(v.Interface())) {
+				return "must be one of " + strings.Join(allowed, ", "), nil
+			}
+		default:
+			return "", fmt.Errorf("utils: unknown rule %q in validate tag of %s", name, where)
+		}
+	}
+	return "", nil
+}
+
+func checkBound(v reflect.Value, rule string, limit float64, where string) (string, error) {
+	var (
+		n    float64
+		unit string
+	)
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		n, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return "", fmt.Errorf("utils: %s does not apply to %s (%s)", rule, where, v.Kind())
+	}
+
+	limitStr := strconv.FormatFloat(limit, 'f', -1, 64)
+	if rule == "min" && n < limit {
+		if unit != "" {
+			return "must have at least " + limitStr + unit, nil
+		}
+		return "must be at least " + limitStr, nil
+	}
+	if rule == "max" && n > limit {
+		if unit != "" {
+			return "must have at most " + limitStr + unit, nil
+		}
+		return "must be at most " + limitStr, nil
+	}
+	return "", nil
+}
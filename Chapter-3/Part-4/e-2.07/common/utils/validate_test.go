@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type address struct {
+		City string `json:"city" validate:"required"`
+	}
+	type input struct {
+		Name    string         `json:"name" validate:"required,min=2,max=5"`
+		Tags    []string       `json:"tags" validate:"max=2"`
+		Limit   int            `json:"limit" validate:"min=1,max=100"`
+		Ratio   float64        `json:"ratio" validate:"max=1"`
+		Size    uint           `json:"size" validate:"max=10"`
+		Kind    string         `json:"kind" validate:"oneof=a b"`
+		Value   *int           `json:"value" validate:"required"`
+		Note    *string        `json:"note" validate:"min=1"`
+		Meta    map[string]int `json:"meta" validate:"max=1"`
+		Address address        `json:"address"`
+		Home    *address       `json:"home"`
+		Skipped string         `json:"-" validate:"required"`
+		NoJSON  string         `validate:"max=1"`
+		private string         `validate:"required"`
+	}
+	one, empty := 1, ""
+	valid := func() input {
+		return input{Name: "héllo", Limit: 1, Kind: "a", Value: &one, Address: address{City: "x"}}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*input)
+		want   FieldErrors
+	}{
+		{"valid", func(in *input) {}, nil},
+		{"required string", func(in *input) { in.Name = "" }, FieldErrors{"name": "is required"}},
+		{"min runes", func(in *input) { in.Name = "é" }, FieldErrors{"name": "must have at least 2 characters"}},
+		{"max runes", func(in *input) { in.Name = "ééééééé" }, FieldErrors{"name": "must have at most 5 characters"}},
+		{"max items", func(in *input) { in.Tags = []string{"a", "b", "c"} }, FieldErrors{"tags": "must have at most 2 items"}},
+		{"max map items", func(in *input) { in.Meta = map[string]int{"a": 1, "b": 2} }, FieldErrors{"meta": "must have at most 1 items"}},
+		{"min int", func(in *input) { in.Limit = 0 }, FieldErrors{"limit": "must be at least 1"}},
+		{"max int", func(in *input) { in.Limit = 101 }, FieldErrors{"limit": "must be at most 100"}},
+		{"max float", func(in *input) { in.Ratio = 1.5 }, FieldErrors{"ratio": "must be at most 1"}},
+		{"max uint", func(in *input) { in.Size = 11 }, FieldErrors{"size": "must be at most 10"}},
+		{"oneof", func(in *input) { in.Kind = "c" }, FieldErrors{"kind": "must be one of a, b"}},
+		{"required pointer", func(in *input) { in.Value = nil }, FieldErrors{"value": "is required"}},
+		{"optional pointer set", func(in *input) { in.Note = &empty }, FieldErrors{"note": "must have at least 1 characters"}},
+		{"nested struct", func(in *input) { in.Address.City = "" }, FieldErrors{"address.city": "is required"}},
+		{"nested pointer", func(in *input) { in.Home = &address{} }, FieldErrors{"home.city": "is required"}},
+		{"field without json tag", func(in *input) { in.NoJSON = "ab" }, FieldErrors{"NoJSON": "must have at most 1 characters"}},
+		{"several fields", func(in *input) { in.Name, in.Limit = "", 0 }, FieldErrors{"name": "is required", "limit": "must be at least 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid()
+			tt.modify(&in)
+			err := Validate(&in)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var got FieldErrors
+			if !errors.As(err, &got) || !maps.Equal(got, tt.want) {
+				t.Fatalf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMalformedTag(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"unknown rule", &struct {
+			A string `validate:"email"`
+		}{}},
+		{"invalid bound", &struct {
+			A string `validate:"min=two"`
+		}{}},
+		{"bound on a bool", &struct {
+			A bool `validate:"max=1"`
+		}{}},
+		{"nested", &struct {
+			B struct {
+				A int `validate:"maximum=1"`
+			}
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.v)
+			var fields FieldErrors
+			if err == nil || errors.As(err, &fields) {
+				t.Fatalf("Validate() = %v, want a tag error", err)
+			}
+		})
+	}
+}
+
+func TestValidateNonStruct(t *testing.T) {
+	var nilPtr *struct {
+		A string `validate:"required"`
+	}
+	for _, v := range []any{nil, 1, "a", nilPtr} {
+		if err := Validate(v); err != nil {
+			t.Errorf("Validate(%#v) = %v, want nil", v, err)
+		}
+	}
+}
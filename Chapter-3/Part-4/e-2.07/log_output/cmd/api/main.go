@@ -2,21 +2,87 @@ package main
 
 import (
 	"common/boot"
+	"common/config"
+	"common/logging"
 	common_server "common/server"
-	"log"
+	"common/tracing"
+	"context"
+	"flag"
+	"os"
 
 	"log_output/internal/app"
 	"log_output/internal/server"
 )
 
 func main() {
-	application, err := app.NewApplication()
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration (secrets redacted) and exit")
+	preStop := flag.Bool("prestop", false, "Ask the server running in the container to drain and exit once drained, for the preStop exec hook")
+	flag.Parse()
+
+	// before the watcher, so missing keys are listed instead of failing the load
+	if *printConfig {
+		var cfg app.Config
+		if err := config.PrintLoaded(os.Stdout, &cfg, config.Load(&cfg)); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *preStop {
+		var cfg app.Config
+		if err := config.Load(&cfg); err != nil {
+			logging.Fatal("could not load configuration", "error", err)
+		}
+		if err := boot.PreStop(cfg.AdminPort, cfg.Shutdown); err != nil {
+			logging.Fatal("preStop hook failed", "error", err)
+		}
+		return
+	}
+
+	cfgWatcher, err := config.NewWatcher[app.Config](config.NewLoader())
+	if err != nil {
+		logging.Fatal("could not load configuration", "error", err)
+	}
+	cfg := cfgWatcher.Current().Config
+	if err := cfg.HTTP.Validate(); err != nil {
+		logging.Fatal("invalid http policy", "error", err)
+	}
+	if _, err := logging.Setup(cfg.Logging, "log_output"); err != nil {
+		logging.Fatal("could not set up logging", "error", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, "log_output")
 	if err != nil {
-		log.Fatalf("failed to create application")
+		logging.Fatal("could not set up tracing", "error", err)
 	}
 
-	srv := common_server.New(8091)
+	application, err := app.NewApplication(cfgWatcher)
+	if err != nil {
+		logging.Fatal("failed to create application", "error", err)
+	}
+
+	srv := common_server.New(cfg.Port)
+	if err := common_server.ConfigureTLS(srv, cfg.TLS); err != nil {
+		logging.Fatal("could not configure tls", "error", err)
+	}
 	srv.Handler = server.RegisterRoutes(application)
+	sup := boot.NewSupervisor()
+	adminSrv := common_server.NewAdmin(cfg.AdminPort)
+	adminSrv.Handler = server.RegisterAdminRoutes(application, boot.PreStopHandler(sup, cfg.Shutdown))
+
+	// the http server depends on tracing so pending spans are flushed once it is stopped
+	sup.Register("tracing", &boot.Resource{StopFn: shutdownTracing}, boot.Options{})
+	// the admin listener starts before and stops after the other components, probes
+	// and metrics stay reachable while the database connects and the public server drains
+	sup.Register("admin", boot.NewHTTPServer(adminSrv), boot.Options{
+		DependsOn: []string{"tracing"},
+	})
+	application.Register(sup)
+	// stopped first: it stops accepting and waits for the in-flight requests before the
+	// components they use are stopped
+	sup.Register("http", boot.NewHTTPServer(srv).Track(application.InFlight), boot.Options{
+		DependsOn:   []string{"tracing", "admin", "logger"},
+		StopTimeout: cfg.Shutdown.Timeout,
+	})
 
-	boot.Run(application, srv)
+	boot.Run(sup, cfg.Shutdown)
 }
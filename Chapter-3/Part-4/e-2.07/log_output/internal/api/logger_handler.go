@@ -1,104 +1,131 @@
 package api
 
 import (
+	"common/logging"
 	"common/utils"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	client "log_output/internal/client/pingpong"
 	"log_output/internal/store"
 )
 
+// latestInfo holds the config derived lines of GetLatestData, swapped on config reload
+type latestInfo struct {
+	fileContentTxt string
+	envVarMsg      string
+
+	fileContent string // raw values of the JSON representation
+	message     string
+}
+
 type LoggerEntryHandler struct {
 	loggerStore    store.LogStorage // Use interface, not concrete type
-	logger         *log.Logger
 	pingpongClient client.Client
-	fileInfoPath   string
+	info           atomic.Pointer[latestInfo]
 }
 
-func NewLoggerEntryHandler(loggerMemoryStore store.LogStorage, logger *log.Logger, pingpongClient client.Client, fileInfoPath string) *LoggerEntryHandler {
-	return &LoggerEntryHandler{
+func NewLoggerEntryHandler(loggerMemoryStore store.LogStorage, pingpongClient client.Client, fileInfoPath string, message string) *LoggerEntryHandler {
+	leh := &LoggerEntryHandler{
 		loggerStore:    loggerMemoryStore,
-		logger:         logger,
 		pingpongClient: pingpongClient,
-		fileInfoPath:   fileInfoPath,
 	}
+	leh.SetInfo(fileInfoPath, message)
+	return leh
 }
 
-func (leh *LoggerEntryHandler) GetLatestData(w http.ResponseWriter, r *http.Request) {
-
-	msg := os.Getenv("MESSAGE")
-	if msg == "" {
-		msg = "no message found for env variable MESSAGE"
-	}
-	envVarMsg := fmt.Sprintf("env variable: %s=%s", "MESSAGE", msg)
-
-	fileContent, err := os.ReadFile(leh.fileInfoPath)
+// SetInfo reads the info file and stores it with the message, called on startup and on config reload
+func (leh *LoggerEntryHandler) SetInfo(fileInfoPath string, message string) {
+	fileContent, err := os.ReadFile(fileInfoPath)
 	var fileContentTxt string
 	if err != nil {
-		fileContentTxt = fmt.Sprintf("file content: ERROR - Could not read file from path %s: %v", leh.fileInfoPath, err)
+		fileContentTxt = fmt.Sprintf("file content: ERROR - Could not read file from path %s: %v", fileInfoPath, err)
 	} else {
 		fileContentTxt = fmt.Sprintf("file content: %s", strings.TrimSpace(string(fileContent)))
 	}
 
+	leh.info.Store(&latestInfo{
+		fileContentTxt: fileContentTxt,
+		envVarMsg:      fmt.Sprintf("env variable: %s=%s", "MESSAGE", message),
+		fileContent:    strings.TrimSpace(string(fileContent)),
+		message:        message,
+	})
+}
+
+// GetLatestData serves the info lines, the latest log and the pingpong count,
+// as text unless the client asks for JSON
+func (leh *LoggerEntryHandler) GetLatestData(w http.ResponseWriter, r *http.Request) {
+	format := utils.Negotiate(r, utils.MediaText, utils.MediaJSON)
+	if format == "" {
+		utils.WriteProblem(w, r, utils.NewProblem(http.StatusNotAcceptable, utils.CodeNotAcceptable,
+			fmt.Sprintf("available formats: %s, %s", utils.MediaText, utils.MediaJSON)))
+		return
+	}
+
+	info := leh.info.Load()
+
 	logs := leh.loggerStore.GetLatest(1)
-	pingpongCount, err := leh.pingpongClient.GetCount()
+	pingpongCount, err := leh.pingpongClient.GetCount(r.Context())
 
 	if err != nil {
-		utils.WriteJSON(w, http.StatusInternalServerError,
-			utils.Envelope{
-				"error": fmt.Sprintf("internal server error: %v", err),
-			},
-		)
+		logging.FromContext(r.Context()).Error("could not get pingpong count", "error", err)
+		utils.BadGateway(w, r, "could not get the pingpong count")
 		return
 	}
-	logLine := fmt.Sprintf("%s: %s", logs[0].Timestamp.Format(time.RFC3339), logs[0].Value)
+
+	// the logger goroutine may not have written yet
+	var latest *store.LogEntry
+	logLine := "no log entry yet"
+	if len(logs) > 0 {
+		latest = &logs[0]
+		logLine = fmt.Sprintf("%s: %s", latest.Timestamp.Format(time.RFC3339), latest.Value)
+	}
+
+	if format == utils.MediaJSON {
+		utils.OK(w, utils.Envelope{
+			"file_content": info.fileContent,
+			"message":      info.message,
+			"log":          latest,
+			"pingpongs":    pingpongCount,
+		})
+		return
+	}
+
 	ppsLine := fmt.Sprintf("Ping / Pongs: %d\n", pingpongCount)
 
-	fullResponse := fmt.Sprintf("%s\n%s\n%s\n%s\n", fileContentTxt, envVarMsg, logLine, ppsLine)
+	fullResponse := fmt.Sprintf("%s\n%s\n%s\n%s\n", info.fileContentTxt, info.envVarMsg, logLine, ppsLine)
 	utils.Write(w, http.StatusOK, fullResponse)
 }
 
 func (leh *LoggerEntryHandler) GetAllLogs(w http.ResponseWriter, r *http.Request) {
 	logs := leh.loggerStore.GetAll()
 
-	utils.WriteJSON(w, http.StatusOK,
-		utils.Envelope{
-			"logs": logs,
-		},
-	)
+	utils.OK(w, utils.Envelope{
+		"logs": logs,
+	})
 }
 
+// maxStatusLogs bounds the n query parameter of GetLastLogsAndStatus
+const maxStatusLogs = 1000
+
 func (leh *LoggerEntryHandler) GetLastLogsAndStatus(w http.ResponseWriter, r *http.Request) {
-	nParam := r.URL.Query().Get("n")
-
-	var lastNLogs int64 = 10 // Default value
-
-	if nParam != "" {
-		parsedN, err := strconv.ParseInt(nParam, 10, 64)
-		if err != nil {
-			leh.logger.Printf("ERROR: parsing query parameter 'n': %v\n", err)
-			utils.WriteJSON(w,
-				http.StatusBadRequest,
-				utils.Envelope{
-					"error": fmt.Sprintf("invalid query parameter 'n': %v", err),
-				})
-			return
-		}
-		lastNLogs = parsedN
+	lastNLogs, err := utils.QueryParam(r, "n", 10, utils.Int(0, maxStatusLogs))
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("invalid query parameter", "param", "n", "error", err)
+		utils.WriteError(w, r, err)
+		return
 	}
 
-	logs := leh.loggerStore.GetLatest(int(lastNLogs))
+	logs := leh.loggerStore.GetLatest(lastNLogs)
 
 	response := utils.Envelope{
 		"status": "ready",
 	}
 	response["logs"] = logs
 
-	utils.WriteJSON(w, http.StatusOK, response)
+	utils.OK(w, response)
 }
@@ -1,72 +1,107 @@
 package app
 
 import (
-	"context"
+	"common/boot"
+	"common/config"
+	"common/httpclient"
+	"common/metrics"
+	common_server "common/server"
 	"fmt"
-	"log"
-	"os"
-	"sync"
 	"time"
 
 	"log_output/internal/api"
 	client "log_output/internal/client/pingpong"
 	"log_output/internal/logger"
 	"log_output/internal/store"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type Application struct {
 	Logger           *logger.Logger
-	wg               sync.WaitGroup
+	Probes           *common_server.Probes
+	Config           *config.Watcher[Config]
+	Metrics          *prometheus.Registry
+	InFlight         *common_server.InFlight
+	HTTPPolicy       common_server.Policy
 	LogMemoryHandler *api.LoggerEntryHandler
 }
 
-func NewApplication() (*Application, error) {
+func NewApplication(cfgWatcher *config.Watcher[Config]) (*Application, error) {
+	cfg := cfgWatcher.Current().Config
 	logMemoryStore := store.NewMemoryStorage()
 	loggerConfig := logger.LoggerConfig{
-		Interval:   5 * time.Second,
+		Interval:   cfg.LogInterval,
 		TimeFormat: time.RFC3339,
 	}
 
-	fileInfoDir := os.Getenv("FILE_INFO_TXT_PATH")
-	if fileInfoDir == "" {
-		log.Fatal("FILE_INFO_TXT_PATH was not set.")
+	pingPongURL := cfg.PingPongURL.String()
+	pingPongTLS, err := common_server.NewClientTLSConfig(common_server.ClientTLS{
+		CAFile:   cfg.PingPongCAFile,
+		CertFile: cfg.PingPongCertFile,
+		KeyFile:  cfg.PingPongKeyFile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pingpong client tls: %w", err)
+	}
+
+	registry := metrics.NewRegistry()
+	pingpongHTTP, err := httpclient.New(httpclient.Options{
+		Name:           "pingpong",
+		BaseURL:        pingPongURL,
+		TLS:            pingPongTLS,
+		ConnectTimeout: cfg.PingPongConnectTimeout,
+		Timeout:        cfg.PingPongTimeout,
+		Retry:          httpclient.RetryPolicy{MaxAttempts: cfg.PingPongAttempts},
+		HedgeDelay:     cfg.PingPongHedgeDelay,
+		Hooks:          httpclient.NewMetrics(registry).Hooks(),
+	})
+	if err != nil {
+		return nil, err
 	}
-	pingPongURL := os.Getenv("PING_PONG_SVC_URL")
-	if pingPongURL == "" {
-		return nil, fmt.Errorf("PING_PONG_SVC_URL environment variable not set")
+	pingpongClient := client.NewClient(pingpongHTTP)
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "log_output_stored_entries",
+		Help: "Number of log entries held by the in-memory store.",
+	}, func() float64 {
+		return float64(logMemoryStore.Count())
+	}))
+
+	logMemory := logger.NewLogger(loggerConfig, logMemoryStore, registry)
+	logMemoryHandler := api.NewLoggerEntryHandler(logMemoryStore, pingpongClient, cfg.FileInfoPath, cfg.Message)
+	probes := common_server.NewProbes()
+	if cfg.PingPongHealthURL != nil {
+		probes.Register("pingpong", common_server.HTTPCheck(pingpongHTTP.HTTPClient(), cfg.PingPongHealthURL.String()), common_server.CheckOptions{
+			Kinds: common_server.Readiness,
+		})
 	}
-	pingpongClient := client.NewClient(pingPongURL, 5*time.Second)
 
-	logMemory := logger.NewLogger(loggerConfig, logMemoryStore)
-	logMemoryHandler := api.NewLoggerEntryHandler(logMemoryStore, logMemory.GetNormalLogger(), pingpongClient, fileInfoDir)
+	// the info file is re-read on change, message and interval follow the mounted config files
+	cfgWatcher.Watch(cfg.FileInfoPath)
+	cfgWatcher.Subscribe(func(snap config.Snapshot[Config]) {
+		logMemoryHandler.SetInfo(snap.Config.FileInfoPath, snap.Config.Message)
+		logMemory.SetInterval(snap.Config.LogInterval)
+	})
+
 	app := &Application{
 		Logger:           logMemory,
+		Probes:           probes,
+		Config:           cfgWatcher,
+		Metrics:          registry,
+		InFlight:         common_server.NewInFlight(),
+		HTTPPolicy:       cfg.HTTP,
 		LogMemoryHandler: logMemoryHandler,
 	}
 	return app, nil
 }
 
-func (a *Application) Start(ctx context.Context) error {
-	fmt.Println("Starting application services....")
-
-	a.wg.Add(1)
-
-	go func() {
-		defer a.wg.Done()
-
-		if err := a.Logger.StartLogger(ctx); err != nil {
-			fmt.Printf("Logger start error: %v\n", err)
-		}
-	}()
-
-	return nil
-}
-
-func (a *Application) Stop() error {
-	fmt.Println("Stopping application services...")
-
-	a.wg.Wait()
-
-	fmt.Println("Application stopped succesfully")
-	return nil
+// Register adds the application components to the supervisor
+func (a *Application) Register(sup *boot.Supervisor) {
+	sup.Register("config-watcher", a.Config, boot.Options{
+		Restart: boot.RestartOnFailure,
+	})
+	sup.Register("logger", boot.WorkerFunc(a.Logger.StartLogger), boot.Options{
+		Restart: boot.RestartOnFailure,
+	})
+	sup.OnShutdown(a.Probes.SetDraining)
 }
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"common/boot"
+	"common/logging"
+	common_server "common/server"
+	"common/tracing"
+	"net/url"
+	"time"
+)
+
+// Config is the log_output configuration, bound with common/config
+type Config struct {
+	Port            int           `env:"PORT" default:"8091"`
+	AdminPort       int           `env:"ADMIN_PORT" default:"9091"` // probes, metrics, pprof and admin endpoints, never exposed by the ingress
+	Message         string        `env:"MESSAGE" default:"no message found for env variable MESSAGE"`
+	FileInfoPath    string        `env:"FILE_INFO_TXT_PATH" required:"true"`
+	PingPongURL     url.URL       `env:"PING_PONG_SVC_URL" required:"true"`
+	PingPongTimeout time.Duration `env:"PING_PONG_TIMEOUT" default:"5s"` // per attempt
+	// PingPongConnectTimeout bounds the dial and TLS handshake of each attempt
+	PingPongConnectTimeout time.Duration `env:"PING_PONG_CONNECT_TIMEOUT" default:"2s"`
+	// PingPongAttempts above 1 retries the idempotent calls, only enable it while log_output
+	// reads the read-only /pingpong/count: the legacy GET /pingpong increments the counter
+	PingPongAttempts int `env:"PING_PONG_ATTEMPTS" default:"1"`
+	// PingPongHedgeDelay sends a second request when ping_pong has not answered after it, 0 disables hedging
+	PingPongHedgeDelay time.Duration `env:"PING_PONG_HEDGE_DELAY" default:"0s"`
+	// PingPongHealthURL is ping_pong's /livez on its public port (e.g. https://ping-pong-svc:2366/livez),
+	// checked with the TLS settings below. The readiness check on ping_pong is skipped when unset.
+	PingPongHealthURL *url.URL `env:"PING_PONG_HEALTH_URL"`
+
+	// client side TLS towards ping_pong, used with an https PING_PONG_SVC_URL
+	PingPongCAFile   string `env:"PING_PONG_TLS_CA_FILE"`
+	PingPongCertFile string `env:"PING_PONG_TLS_CERT_FILE"` // client certificate when ping_pong requires mTLS
+	PingPongKeyFile  string `env:"PING_PONG_TLS_KEY_FILE"`
+
+	LogInterval time.Duration `env:"LOG_INTERVAL" default:"5s"`
+	HTTP        common_server.Policy
+	TLS         common_server.TLSConfig
+	Logging     logging.Config
+	Tracing     tracing.Config
+	Shutdown    boot.ShutdownConfig
+}
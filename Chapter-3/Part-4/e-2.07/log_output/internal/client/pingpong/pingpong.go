@@ -1,53 +1,32 @@
 package client
 
 import (
-	"encoding/json"
+	"common/httpclient"
+	"context"
 	"fmt"
-	"net/http"
-	"time"
 )
 
 type Client interface {
-	// GetCount(ctx context.Context) (int, error)
-	GetCount() (int, error)
+	GetCount(ctx context.Context) (int, error)
 }
 
 type httpClient struct {
-	client  *http.Client
-	baseUrl string
+	http *httpclient.Client
 }
 
-func NewClient(baseUrl string, timeout time.Duration) Client {
-	return &httpClient{
-		client: &http.Client{
-			Timeout: timeout,
-		},
-		baseUrl: baseUrl,
-	}
+// NewClient returns a ping_pong client on top of c, whose base url is ping_pong's
+func NewClient(c *httpclient.Client) Client {
+	return &httpClient{http: c}
 }
 
-func (c *httpClient) GetCount() (int, error) {
-	url := fmt.Sprintf("%s/pingpong", c.baseUrl)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return -1, fmt.Errorf("failed to create request to pingpong count: %w", err)
-	}
+type countResponse struct {
+	Count int `json:"count"`
+}
 
-	resp, err := c.client.Do(req)
+func (c *httpClient) GetCount(ctx context.Context) (int, error) {
+	payload, err := httpclient.GetJSON[countResponse](ctx, c.http, "/pingpong/count")
 	if err != nil {
-		return -1, fmt.Errorf("failed to send request to pingpong count: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return -1, fmt.Errorf("received status code non-OK: %s", resp.Status)
-	}
-
-	var payload struct {
-		Count int `json:"count"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return -1, fmt.Errorf("could not decode response body: %w", err)
+		return -1, fmt.Errorf("could not get pingpong count: %w", err)
 	}
 	return payload.Count, nil
 }
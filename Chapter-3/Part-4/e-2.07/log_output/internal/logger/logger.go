@@ -2,14 +2,13 @@ package logger
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"log_output/internal/store"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // ValueGenerator is a function type utility,
@@ -27,15 +26,26 @@ type Logger struct {
 	loggerConfig LoggerConfig
 	logStorage   store.LogStorage
 	currentValue string
-	rwMutex      sync.RWMutex // Protects "currentValue" (thread safety)
-	normalLogger *log.Logger  // Standard Go logger for normal logging (not stored)
+	rwMutex      sync.RWMutex         // Protects "currentValue" and "loggerConfig.Interval" (thread safety)
+	normalLogger *slog.Logger         // logger for normal logging (not stored)
+	intervalCh   chan struct{}        // signals a new loggerConfig.Interval to the running loop
+	tickerLag    prometheus.Histogram // delay between a tick and the moment it is handled
 }
 
-func NewLogger(loggerConfig LoggerConfig, logStorage store.LogStorage) *Logger {
+func NewLogger(loggerConfig LoggerConfig, logStorage store.LogStorage, reg prometheus.Registerer) *Logger {
+	tickerLag := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "log_output_ticker_lag_seconds",
+		Help:    "Delay between a logger tick and the moment the entry is stored.",
+		Buckets: []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+	})
+	reg.MustRegister(tickerLag)
+
 	return &Logger{
 		loggerConfig: loggerConfig,
 		logStorage:   logStorage,
-		normalLogger: log.New(os.Stdout, "[LOGGER] ", log.LstdFlags),
+		normalLogger: slog.Default().With("component", "logger"),
+		intervalCh:   make(chan struct{}, 1),
+		tickerLag:    tickerLag,
 		// Note --> rwMutex doesn't need initialization.
 	}
 }
@@ -46,8 +56,8 @@ func (l *Logger) StartLogger(ctx context.Context) error {
 	l.currentValue = generateUUID()
 	l.rwMutex.Unlock()
 
-	// Create ticker --> periodic logging
-	ticker := time.NewTicker(l.loggerConfig.Interval)
+	// Create ticker --> periodic logging, a restart keeps the last interval set
+	ticker := time.NewTicker(l.interval())
 	defer ticker.Stop()
 
 	// immediate log on Start
@@ -57,10 +67,15 @@ func (l *Logger) StartLogger(ctx context.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
-			l.normalLogger.Println("Logger stopped...")
+			l.normalLogger.Info("logger stopped")
 			return nil
-		case <-ticker.C:
+		case tick := <-ticker.C:
+			l.tickerLag.Observe(time.Since(tick).Seconds())
 			l.logCurrent()
+		case <-l.intervalCh:
+			interval := l.interval()
+			l.normalLogger.Info("logger interval changed", "interval", interval)
+			ticker.Reset(interval)
 		}
 	}
 }
@@ -73,19 +88,41 @@ func (l *Logger) logCurrent() {
 	timestamp := time.Now()
 
 	if err := l.logStorage.Store(timestamp, value); err != nil {
-		l.normalLogger.Printf("Error storing log: %v", err)
+		l.normalLogger.Error("could not store log entry", "error", err)
 		return
 	}
 
 	// Output generated log value to console (stored in memory)
-	fmt.Printf("%s: %s\n", timestamp.Format(l.loggerConfig.TimeFormat), value)
+	l.normalLogger.Info("log entry", "timestamp", timestamp.Format(l.loggerConfig.TimeFormat), "value", value)
 }
 
-func generateUUID() string {
-	return uuid.New().String()
+// SetInterval changes the logging interval of a running logger (config reload),
+// an unchanged interval is ignored
+func (l *Logger) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	l.rwMutex.Lock()
+	changed := interval != l.loggerConfig.Interval
+	l.loggerConfig.Interval = interval
+	l.rwMutex.Unlock()
+	if !changed {
+		return
+	}
+
+	// a pending signal already makes the loop read the latest value
+	select {
+	case l.intervalCh <- struct{}{}:
+	default:
+	}
+}
+
+func (l *Logger) interval() time.Duration {
+	l.rwMutex.RLock()
+	defer l.rwMutex.RUnlock()
+	return l.loggerConfig.Interval
 }
 
-// GetNormalLogger returns the normal logger instance for external use
-func (l *Logger) GetNormalLogger() *log.Logger {
-	return l.normalLogger
+func generateUUID() string {
+	return uuid.New().String()
 }
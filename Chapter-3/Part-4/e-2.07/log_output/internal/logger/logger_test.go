@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"log_output/internal/store"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestLogger(interval time.Duration) (*Logger, *store.MemoryStorage) {
+	storage := store.NewMemoryStorage()
+	l := NewLogger(LoggerConfig{Interval: interval, TimeFormat: time.RFC3339}, storage, prometheus.NewRegistry())
+	return l, storage
+}
+
+func TestSetIntervalSurvivesRestart(t *testing.T) {
+	l, storage := newTestLogger(time.Hour)
+	l.SetInterval(10 * time.Millisecond)
+
+	// a restarted loop has to pick up the reloaded interval, not the initial one
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- l.StartLogger(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for storage.Count() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("logged %d entries, want the 10ms interval to be used", storage.Count())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetIntervalIgnoresUnchanged(t *testing.T) {
+	l, _ := newTestLogger(5 * time.Second)
+
+	l.SetInterval(5 * time.Second)
+	l.SetInterval(0)
+	if len(l.intervalCh) != 0 {
+		t.Fatal("an unchanged interval signalled the logger")
+	}
+
+	l.SetInterval(time.Second)
+	l.SetInterval(2 * time.Second)
+	if len(l.intervalCh) != 1 {
+		t.Fatalf("%d pending signals, want 1", len(l.intervalCh))
+	}
+	if got := l.interval(); got != 2*time.Second {
+		t.Fatalf("interval = %s, want the latest 2s", got)
+	}
+}
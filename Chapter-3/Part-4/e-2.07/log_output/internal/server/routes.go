@@ -9,10 +9,24 @@ import (
 )
 
 func RegisterRoutes(app *app.Application) http.Handler {
-	r := common_server.NewRouter()
+	r := common_server.NewRouter(common_server.RouterConfig{
+		Metrics:  app.Metrics,
+		InFlight: app.InFlight,
+		Policy:   &app.HTTPPolicy,
+	})
 	r.Get("/logs", app.LogMemoryHandler.GetAllLogs)
 	r.Get("/status", app.LogMemoryHandler.GetLastLogsAndStatus)
 	r.Get("/", app.LogMemoryHandler.GetLatestData)
 
 	return r
 }
+
+// RegisterAdminRoutes returns the handler of the admin listener, preStop serves the kubelet preStop hook
+func RegisterAdminRoutes(app *app.Application, preStop http.Handler) http.Handler {
+	return common_server.NewAdminRouter(common_server.AdminConfig{
+		Probes:  app.Probes,
+		Metrics: app.Metrics,
+		PreStop: preStop,
+		Config:  http.HandlerFunc(app.Config.StatusHandler),
+	})
+}
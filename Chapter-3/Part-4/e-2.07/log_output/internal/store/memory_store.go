@@ -9,6 +9,7 @@ type LogStorage interface {
 	Store(timestamp time.Time, value string) error
 	GetAll() []LogEntry
 	GetLatest(n int) []LogEntry
+	Count() int
 }
 
 type LogEntry struct {
@@ -66,3 +67,11 @@ func (m *MemoryStorage) GetLatest(n int) []LogEntry {
 
 	return result
 }
+
+// Count returns the number of stored entries
+func (m *MemoryStorage) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.entries)
+}
@@ -1,22 +1,113 @@
 package main
 
 import (
+	"cmp"
 	"common/boot"
+	"common/config"
+	"common/db"
+	"common/logging"
 	common_server "common/server"
-	"log"
+	"common/tracing"
+	"context"
+	"flag"
+	"os"
 
 	"ping_pong/internal/app"
+	"ping_pong/internal/migrations"
 	"ping_pong/internal/server"
 )
 
 func main() {
-	application, err := app.NewApplication()
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration (secrets redacted) and exit")
+	preStop := flag.Bool("prestop", false, "Ask the server running in the container to drain and exit once drained, for the preStop exec hook")
+	migrateOnly := flag.Bool("migrate-only", false, "Apply the pending migrations and exit, for an init container or Job (exit code 1 on failure)")
+	skipMigrations := flag.Bool("skip-migrations", false, "Do not migrate on start, report the schema version in /readyz instead")
+	flag.Parse()
+
+	var cfg app.Config
+	loadErr := config.Load(&cfg)
+	cfg.DB.Schema = cmp.Or(cfg.DB.Schema, app.Schema)
+	// missing keys are listed instead of failing the load
+	if *printConfig {
+		if err := config.PrintLoaded(os.Stdout, &cfg, loadErr); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	if loadErr != nil {
+		logging.Fatal("could not load configuration", "error", loadErr)
+	}
+	if *preStop {
+		if err := boot.PreStop(cfg.AdminPort, cfg.Shutdown); err != nil {
+			logging.Fatal("preStop hook failed", "error", err)
+		}
+		return
+	}
+	if err := cfg.HTTP.Validate(); err != nil {
+		logging.Fatal("invalid http policy", "error", err)
+	}
+	if err := cfg.Store.Validate(); err != nil {
+		logging.Fatal("invalid store", "error", err)
+	}
+	if cfg.Store.Backend == app.BackendPostgres {
+		if err := cfg.DB.Validate(); err != nil {
+			logging.Fatal("invalid database settings", "error", err)
+		}
+	}
+	if err := cfg.History.Validate(); err != nil {
+		logging.Fatal("invalid history retention", "error", err)
+	}
+	if _, err := logging.Setup(cfg.Logging, "ping_pong"); err != nil {
+		logging.Fatal("could not set up logging", "error", err)
+	}
+
+	// ping_pong migrate <up|down|status|redo|dry-run>, --migrate-only is migrate up
+	if flag.Arg(0) == "migrate" || *migrateOnly {
+		args := flag.Args()[min(1, flag.NArg()):]
+		if *migrateOnly {
+			args = []string{"up"}
+		}
+		if err := db.MigrateCommand(context.Background(), cfg.DB, migrations.FS, args, os.Stdout); err != nil {
+			logging.Fatal("migrate failed", "error", err)
+		}
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, "ping_pong")
+	if err != nil {
+		logging.Fatal("could not set up tracing", "error", err)
+	}
+
+	application, err := app.NewApplication(cfg, app.Options{
+		SkipMigrations: *skipMigrations,
+	})
 	if err != nil {
-		log.Fatalf("failed to create application")
+		logging.Fatal("failed to create application", "error", err)
 	}
 
-	srv := common_server.New(8092)
+	srv := common_server.New(cfg.Port)
+	if err := common_server.ConfigureTLS(srv, cfg.TLS); err != nil {
+		logging.Fatal("could not configure tls", "error", err)
+	}
 	srv.Handler = server.RegisterRoutes(application)
+	sup := boot.NewSupervisor()
+	adminSrv := common_server.NewAdmin(cfg.AdminPort)
+	adminSrv.Handler = server.RegisterAdminRoutes(application, boot.PreStopHandler(sup, cfg.Shutdown))
+
+	// the http server depends on tracing so pending spans are flushed once it is stopped
+	sup.Register("tracing", &boot.Resource{StopFn: shutdownTracing}, boot.Options{})
+	// the admin listener starts before and stops after the other components, probes
+	// and metrics stay reachable while the database connects and the public server drains
+	sup.Register("admin", boot.NewHTTPServer(adminSrv), boot.Options{
+		DependsOn: []string{"tracing"},
+	})
+	application.Register(sup)
+	// stopped first: it stops accepting and waits for the in-flight requests before the
+	// components they use are stopped
+	sup.Register("http", boot.NewHTTPServer(srv).Track(application.InFlight), boot.Options{
+		DependsOn:   []string{"tracing", "admin", application.StoreComponent()},
+		StopTimeout: cfg.Shutdown.Timeout,
+	})
 
-	boot.Run(application, srv)
+	boot.Run(sup, cfg.Shutdown)
 }
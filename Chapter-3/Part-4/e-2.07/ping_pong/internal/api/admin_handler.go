@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"common/logging"
+	common_server "common/server"
+	"common/utils"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"ping_pong/internal/store"
+)
+
+var auditPage = utils.PageOptions{DefaultLimit: 50, MaxLimit: 500}
+
+// AdminHandler serves the audited counter operations of the admin listener. The actor of
+// each change is the principal authenticated by server.RequireBearer in front of it.
+type AdminHandler struct {
+	adminRepo store.AdminRepo
+}
+
+func NewAdminHandler(adminRepo store.AdminRepo) *AdminHandler {
+	return &AdminHandler{
+		adminRepo: adminRepo,
+	}
+}
+
+// actor returns the authenticated user, or writes a 401 when the request was not authenticated
+func (ah *AdminHandler) actor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	actor := common_server.Principal(r)
+	if actor == "" {
+		utils.WriteProblem(w, r, utils.NewProblem(http.StatusUnauthorized, utils.CodeUnauthenticated,
+			"the request is not authenticated"))
+		return "", false
+	}
+	return actor, true
+}
+
+// Reset sets the counter back to 0
+func (ah *AdminHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	key, err := counterKey(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	actor, ok := ah.actor(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := ah.adminRepo.Reset(r.Context(), key, actor)
+	if err != nil {
+		writeRepoError(w, r, key, "could not reset counter", err)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"audit": entry,
+	})
+}
+
+// Set replaces the value of the counter with the one of the body, {"value": n}
+func (ah *AdminHandler) Set(w http.ResponseWriter, r *http.Request) {
+	key, err := counterKey(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	var input struct {
+		Value *int64 `json:"value" validate:"required"`
+	}
+	if !decodeInput(w, r, &input) {
+		return
+	}
+	if *input.Value < 0 {
+		utils.WriteError(w, r, utils.FieldErrors{"value": "must not be negative"})
+		return
+	}
+	actor, ok := ah.actor(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := ah.adminRepo.Set(r.Context(), key, *input.Value, actor)
+	if err != nil {
+		writeRepoError(w, r, key, "could not set counter", err)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"audit": entry,
+	})
+}
+
+// Delete deletes the counter. The pingpong counter is reserved, its routes and log_output use it.
+func (ah *AdminHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	key, err := counterKey(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if key == store.PingPongKey {
+		utils.Conflict(w, r, utils.CodeConflict, fmt.Sprintf("counter %q of namespace %q is reserved, reset it instead", key.Name, key.Namespace))
+		return
+	}
+	actor, ok := ah.actor(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := ah.adminRepo.DeleteAudited(r.Context(), key, actor)
+	if err != nil {
+		writeRepoError(w, r, key, "could not delete counter", err)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"audit": entry,
+	})
+}
+
+// Snapshot saves the current value of the counter under the label of the body, {"label": "..."}
+func (ah *AdminHandler) Snapshot(w http.ResponseWriter, r *http.Request) {
+	key, err := counterKey(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	var input struct {
+		Label string `json:"label" validate:"required"`
+	}
+	if !decodeInput(w, r, &input) {
+		return
+	}
+	if _, err := parseName(input.Label); err != nil {
+		utils.WriteError(w, r, utils.FieldErrors{"label": err.Error()})
+		return
+	}
+	actor, ok := ah.actor(w, r)
+	if !ok {
+		return
+	}
+
+	snapshot, err := ah.adminRepo.Snapshot(r.Context(), key, input.Label, actor)
+	if errors.Is(err, store.ErrSnapshotExists) {
+		utils.Conflict(w, r, utils.CodeConflict, fmt.Sprintf("snapshot %q already exists", input.Label))
+		return
+	}
+	if err != nil {
+		writeRepoError(w, r, key, "could not snapshot counter", err)
+		return
+	}
+	utils.Created(w, counterLocation("/admin/counters", key, "/snapshots"), utils.Envelope{
+		"snapshot": snapshot,
+	})
+}
+
+func (ah *AdminHandler) Snapshots(w http.ResponseWriter, r *http.Request) {
+	key, err := counterKey(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	snapshots, err := ah.adminRepo.Snapshots(r.Context(), key)
+	if err != nil {
+		writeRepoError(w, r, key, "could not list snapshots", err)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"snapshots": snapshots,
+	})
+}
+
+// AuditLog lists the audit entries, newest first, filtered by the namespace, name and action
+// query parameters
+func (ah *AdminHandler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	var filter store.AuditFilter
+	var err error
+	if filter.Namespace, err = utils.QueryParam(r, "namespace", "", parseName); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if filter.Name, err = utils.QueryParam(r, "name", "", parseName); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if filter.Action, err = utils.QueryParam(r, "action", "", utils.Enum(store.ActionReset, store.ActionSet, store.ActionSnapshot, store.ActionDelete)); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	page, err := utils.ReadPage(r, auditPage)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	entries, err := ah.adminRepo.AuditLog(r.Context(), filter, page.Limit, page.Offset)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("could not read the audit log", "error", err)
+		utils.InternalServerError(w, r)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"entries": entries,
+		"page":    page,
+	})
+}
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	common_server "common/server"
+	"common/utils"
+	"ping_pong/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeAdmin is an in-memory AdminRepo over fakeCounters
+type fakeAdmin struct {
+	counters  *fakeCounters
+	snapshots map[string]store.Snapshot
+	audit     []store.AuditEntry
+}
+
+func (f *fakeAdmin) set(key store.CounterKey, value int64, action, actor string) (store.AuditEntry, error) {
+	c, err := f.counters.Get(context.Background(), key)
+	if err != nil {
+		return store.AuditEntry{}, err
+	}
+	entry := store.AuditEntry{ID: int64(len(f.audit) + 1), Namespace: key.Namespace, Name: key.Name,
+		Action: action, Actor: actor, OldValue: c.Value, NewValue: value, At: time.Now()}
+	c.Value = value
+	f.counters.counters[key] = c
+	f.audit = append(f.audit, entry)
+	return entry, nil
+}
+
+func (f *fakeAdmin) Reset(ctx context.Context, key store.CounterKey, actor string) (store.AuditEntry, error) {
+	return f.set(key, 0, store.ActionReset, actor)
+}
+
+func (f *fakeAdmin) Set(ctx context.Context, key store.CounterKey, value int64, actor string) (store.AuditEntry, error) {
+	return f.set(key, value, store.ActionSet, actor)
+}
+
+func (f *fakeAdmin) DeleteAudited(ctx context.Context, key store.CounterKey, actor string) (store.AuditEntry, error) {
+	entry, err := f.set(key, 0, store.ActionDelete, actor)
+	if err != nil {
+		return store.AuditEntry{}, err
+	}
+	return entry, f.counters.Delete(ctx, key)
+}
+
+func (f *fakeAdmin) Snapshot(ctx context.Context, key store.CounterKey, label string, actor string) (store.Snapshot, error) {
+	c, err := f.counters.Get(ctx, key)
+	if err != nil {
+		return store.Snapshot{}, err
+	}
+	if _, ok := f.snapshots[label]; ok {
+		return store.Snapshot{}, store.ErrSnapshotExists
+	}
+	snap := store.Snapshot{Label: label, Value: c.Value, Actor: actor, TakenAt: time.Now()}
+	f.snapshots[label] = snap
+	return snap, nil
+}
+
+func (f *fakeAdmin) Snapshots(ctx context.Context, key store.CounterKey) ([]store.Snapshot, error) {
+	if _, err := f.counters.Get(ctx, key); err != nil {
+		return nil, err
+	}
+	snaps := []store.Snapshot{}
+	for _, s := range f.snapshots {
+		snaps = append(snaps, s)
+	}
+	return snaps, nil
+}
+
+func (f *fakeAdmin) AuditLog(ctx context.Context, filter store.AuditFilter, limit, offset int) ([]store.AuditEntry, error) {
+	entries := []store.AuditEntry{}
+	for i := len(f.audit) - 1; i >= 0; i-- {
+		if e := f.audit[i]; filter.Action == "" || e.Action == filter.Action {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// adminTokens are the bearer tokens of the admin users of the tests
+var adminTokens = map[string]string{
+	"alice": "alice-0123456789abcdef0123456789abcdef",
+	"bob":   "bob-0123456789abcdef0123456789abcdef",
+}
+
+func newAdminRouter(t *testing.T) http.Handler {
+	t.Helper()
+	tokens, err := common_server.NewBearerTokens(adminTokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counters := &fakeCounters{counters: map[store.CounterKey]store.Counter{
+		store.PingPongKey:                     {Namespace: store.PingPongKey.Namespace, Name: store.PingPongKey.Name, Value: 9},
+		{Namespace: "team-a", Name: "visits"}: {Namespace: "team-a", Name: "visits", Value: 3},
+	}}
+	h := NewAdminHandler(&fakeAdmin{counters: counters, snapshots: map[string]store.Snapshot{}})
+	r := chi.NewRouter()
+	r.Use(common_server.RequireBearer(tokens))
+	r.Delete("/admin/counters/{name}", h.Delete)
+	r.Post("/admin/counters/{name}/reset", h.Reset)
+	r.Put("/admin/counters/{name}/value", h.Set)
+	r.Get("/admin/counters/{name}/snapshots", h.Snapshots)
+	r.Post("/admin/counters/{name}/snapshots", h.Snapshot)
+	r.Get("/admin/audit", h.AuditLog)
+	return r
+}
+
+// doAs is do authenticated with the bearer token of user, anonymous when user is empty
+func doAs(t *testing.T, h http.Handler, user, method, target, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+adminTokens[user])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, decodeBody(t, rec)
+}
+
+func TestAdminOperations(t *testing.T) {
+	h := newAdminRouter(t)
+
+	code, body := doAs(t, h, "alice", http.MethodPost, "/admin/counters/pingpong/snapshots", `{"label":"before"}`)
+	if snap, _ := body["snapshot"].(map[string]any); code != http.StatusCreated || snap["value"] != 9.0 {
+		t.Fatalf("Snapshot = %d %v, want 201 value 9", code, body)
+	}
+	if code, _ := doAs(t, h, "alice", http.MethodPost, "/admin/counters/pingpong/snapshots", `{"label":"before"}`); code != http.StatusConflict {
+		t.Fatalf("second Snapshot = %d, want 409", code)
+	}
+
+	code, body = doAs(t, h, "alice", http.MethodPut, "/admin/counters/pingpong/value", `{"value":100}`)
+	if audit, _ := body["audit"].(map[string]any); code != http.StatusOK || audit["old_value"] != 9.0 || audit["new_value"] != 100.0 {
+		t.Fatalf("Set = %d %v, want 200 9 -> 100", code, body)
+	}
+	code, body = doAs(t, h, "bob", http.MethodPost, "/admin/counters/pingpong/reset", "")
+	if audit, _ := body["audit"].(map[string]any); code != http.StatusOK || audit["actor"] != "bob" || audit["new_value"] != 0.0 {
+		t.Fatalf("Reset = %d %v, want 200 by bob to 0", code, body)
+	}
+
+	code, body = doAs(t, h, "bob", http.MethodGet, "/admin/audit?action=set", "")
+	if entries, _ := body["entries"].([]any); code != http.StatusOK || len(entries) != 1 {
+		t.Fatalf("AuditLog of sets = %d %v, want one entry", code, body)
+	}
+	if code, _ := doAs(t, h, "bob", http.MethodGet, "/admin/audit?action=drop", ""); code != http.StatusBadRequest {
+		t.Fatalf("AuditLog with an unknown action = %d, want 400", code)
+	}
+}
+
+func TestAdminDelete(t *testing.T) {
+	h := newAdminRouter(t)
+
+	// the pingpong counter is reserved, it is only reset
+	code, body := doAs(t, h, "alice", http.MethodDelete, "/admin/counters/pingpong", "")
+	if code != http.StatusConflict || body["code"] != utils.CodeConflict {
+		t.Fatalf("Delete of pingpong = %d %v, want 409 conflict", code, body)
+	}
+	if code, _ := doAs(t, h, "alice", http.MethodGet, "/admin/counters/pingpong/snapshots", ""); code != http.StatusOK {
+		t.Fatalf("pingpong after the refused Delete = %d, want 200", code)
+	}
+	if code, _ := doAs(t, h, "", http.MethodDelete, "/admin/counters/visits?namespace=team-a", ""); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous Delete = %d, want 401", code)
+	}
+
+	code, body = doAs(t, h, "bob", http.MethodDelete, "/admin/counters/visits?namespace=team-a", "")
+	if audit, _ := body["audit"].(map[string]any); code != http.StatusOK || audit["action"] != store.ActionDelete ||
+		audit["actor"] != "bob" || audit["old_value"] != 3.0 {
+		t.Fatalf("Delete = %d %v, want 200 deleted by bob from 3", code, body)
+	}
+	if code, _ := doAs(t, h, "bob", http.MethodDelete, "/admin/counters/visits?namespace=team-a", ""); code != http.StatusNotFound {
+		t.Fatalf("second Delete = %d, want 404", code)
+	}
+	code, body = doAs(t, h, "bob", http.MethodGet, "/admin/audit?action=delete", "")
+	if entries, _ := body["entries"].([]any); code != http.StatusOK || len(entries) != 1 {
+		t.Fatalf("AuditLog of deletes = %d %v, want one entry", code, body)
+	}
+}
+
+func TestAdminRejects(t *testing.T) {
+	h := newAdminRouter(t)
+
+	for _, tc := range []struct {
+		user, method, target, body string
+		want                       int
+	}{
+		{"", http.MethodPost, "/admin/counters/pingpong/reset", "", http.StatusUnauthorized},
+		{"", http.MethodGet, "/admin/audit", "", http.StatusUnauthorized},
+		{"alice", http.MethodPost, "/admin/counters/missing/reset", "", http.StatusNotFound},
+		{"alice", http.MethodPut, "/admin/counters/pingpong/value", `{}`, http.StatusUnprocessableEntity},
+		{"alice", http.MethodPut, "/admin/counters/pingpong/value", `{"value":-1}`, http.StatusUnprocessableEntity},
+		{"alice", http.MethodPost, "/admin/counters/pingpong/snapshots", `{"label":"Not A Label"}`, http.StatusUnprocessableEntity},
+	} {
+		if code, body := doAs(t, h, tc.user, tc.method, tc.target, tc.body); code != tc.want {
+			t.Errorf("%s %s %s as %q = %d %v, want %d", tc.method, tc.target, tc.body, tc.user, code, body, tc.want)
+		}
+	}
+
+	// the header set by the former authenticating proxy is a client header like any other
+	req := httptest.NewRequest(http.MethodPost, "/admin/counters/pingpong/reset", nil)
+	req.Header.Set("X-Forwarded-User", "alice")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reset with only X-Forwarded-User = %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"common/logging"
+	"common/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"ping_pong/internal/store"
+)
+
+// counterName matches counter names and namespaces, safe in paths and label values
+var counterName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+var counterPage = utils.PageOptions{DefaultLimit: 50, MaxLimit: 500}
+
+type CounterHandler struct {
+	counterRepo store.CounterRepo
+}
+
+func NewCounterHandler(counterRepo store.CounterRepo) *CounterHandler {
+	return &CounterHandler{
+		counterRepo: counterRepo,
+	}
+}
+
+// parseName accepts the lower case names matched by counterName
+func parseName(raw string) (string, error) {
+	if !counterName.MatchString(raw) {
+		return "", fmt.Errorf("must be 1 to 63 lower case letters, digits, '_', '.' or '-'")
+	}
+	return raw, nil
+}
+
+// namespace reads the optional namespace query parameter
+func namespace(r *http.Request) (string, error) {
+	return utils.QueryParam(r, "namespace", store.DefaultNamespace, parseName)
+}
+
+// counterKey reads the counter addressed by the {name} path parameter and the namespace
+func counterKey(r *http.Request) (store.CounterKey, error) {
+	name, err := utils.PathParam(r, "name", parseName)
+	if err != nil {
+		return store.CounterKey{}, err
+	}
+	ns, err := namespace(r)
+	if err != nil {
+		return store.CounterKey{}, err
+	}
+	return store.CounterKey{Namespace: ns, Name: name}, nil
+}
+
+// counterLocation is the URL of a counter under prefix, followed by suffix. The namespace
+// is left out for the default one.
+func counterLocation(prefix string, key store.CounterKey, suffix string) string {
+	loc := prefix + "/" + url.PathEscape(key.Name) + suffix
+	if key.Namespace != store.DefaultNamespace {
+		loc += "?namespace=" + url.QueryEscape(key.Namespace)
+	}
+	return loc
+}
+
+// writeRepoError maps the store errors to problems, the others are logged and hidden
+func writeRepoError(w http.ResponseWriter, r *http.Request, key store.CounterKey, msg string, err error) {
+	switch {
+	case errors.Is(err, store.ErrCounterNotFound):
+		utils.NotFound(w, r, fmt.Sprintf("counter %q not found in namespace %q", key.Name, key.Namespace))
+	case errors.Is(err, store.ErrCounterExists):
+		utils.Conflict(w, r, utils.CodeConflict, fmt.Sprintf("counter %q already exists in namespace %q", key.Name, key.Namespace))
+	default:
+		logging.FromContext(r.Context()).Error(msg, "namespace", key.Namespace, "counter", key.Name, "error", err)
+		utils.InternalServerError(w, r)
+	}
+}
+
+// decodeInput reads the JSON body into dst, or writes the problem and returns false.
+// Errors other than problems (malformed validate tags) are logged.
+func decodeInput(w http.ResponseWriter, r *http.Request, dst any) bool {
+	err := utils.DecodeJSON(w, r, dst)
+	if err == nil {
+		return true
+	}
+	var p utils.Problem
+	if !errors.As(err, &p) {
+		logging.FromContext(r.Context()).Error("could not decode request body", "error", err)
+	}
+	utils.WriteError(w, r, err)
+	return false
+}
+
+func (ch *CounterHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name" validate:"required"`
+	}
+	if !decodeInput(w, r, &input) {
+		return
+	}
+	if _, err := parseName(input.Name); err != nil {
+		utils.WriteError(w, r, utils.FieldErrors{"name": err.Error()})
+		return
+	}
+	ns, err := namespace(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	key := store.CounterKey{Namespace: ns, Name: input.Name}
+	counter, err := ch.counterRepo.Create(r.Context(), key)
+	if err != nil {
+		writeRepoError(w, r, key, "could not create counter", err)
+		return
+	}
+	utils.Created(w, counterLocation("/counters", key, ""), utils.Envelope{
+		"counter": counter,
+	})
+}
+
+func (ch *CounterHandler) Get(w http.ResponseWriter, r *http.Request) {
+	key, err := counterKey(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	counter, err := ch.counterRepo.Get(r.Context(), key)
+	if err != nil {
+		writeRepoError(w, r, key, "could not read counter", err)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"counter": counter,
+	})
+}
+
+func (ch *CounterHandler) Increment(w http.ResponseWriter, r *http.Request) {
+	key, err := counterKey(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	counter, err := ch.counterRepo.Increment(r.Context(), key)
+	if err != nil {
+		writeRepoError(w, r, key, "could not increment counter", err)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"counter": counter,
+	})
+}
+
+func (ch *CounterHandler) List(w http.ResponseWriter, r *http.Request) {
+	ns, err := namespace(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	page, err := utils.ReadPage(r, counterPage)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	counters, err := ch.counterRepo.List(r.Context(), ns, page.Limit, page.Offset)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("could not list counters", "namespace", ns, "error", err)
+		utils.InternalServerError(w, r)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"namespace": ns,
+		"counters":  counters,
+		"page":      page,
+	})
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"common/utils"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"ping_pong/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeCounters is an in-memory CounterRepo
+type fakeCounters struct {
+	counters map[store.CounterKey]store.Counter
+}
+
+func (f *fakeCounters) Create(ctx context.Context, key store.CounterKey) (store.Counter, error) {
+	if _, ok := f.counters[key]; ok {
+		return store.Counter{}, store.ErrCounterExists
+	}
+	now := time.Now()
+	c := store.Counter{Namespace: key.Namespace, Name: key.Name, CreatedAt: now, UpdatedAt: now}
+	f.counters[key] = c
+	return c, nil
+}
+
+func (f *fakeCounters) Get(ctx context.Context, key store.CounterKey) (store.Counter, error) {
+	c, ok := f.counters[key]
+	if !ok {
+		return store.Counter{}, store.ErrCounterNotFound
+	}
+	return c, nil
+}
+
+func (f *fakeCounters) Increment(ctx context.Context, key store.CounterKey) (store.Counter, error) {
+	c, ok := f.counters[key]
+	if !ok {
+		return store.Counter{}, store.ErrCounterNotFound
+	}
+	c.Value++
+	f.counters[key] = c
+	return c, nil
+}
+
+func (f *fakeCounters) List(ctx context.Context, namespace string, limit, offset int) ([]store.Counter, error) {
+	list := []store.Counter{}
+	for key, c := range f.counters {
+		if key.Namespace == namespace {
+			list = append(list, c)
+		}
+	}
+	slices.SortFunc(list, func(a, b store.Counter) int { return strings.Compare(a.Name, b.Name) })
+	list = list[min(offset, len(list)):]
+	return list[:min(limit, len(list))], nil
+}
+
+func (f *fakeCounters) Delete(ctx context.Context, key store.CounterKey) error {
+	if _, ok := f.counters[key]; !ok {
+		return store.ErrCounterNotFound
+	}
+	delete(f.counters, key)
+	return nil
+}
+
+func newCounterRouter() http.Handler {
+	h := NewCounterHandler(&fakeCounters{counters: map[store.CounterKey]store.Counter{}})
+	r := chi.NewRouter()
+	r.Get("/counters", h.List)
+	r.Post("/counters", h.Create)
+	r.Get("/counters/{name}", h.Get)
+	r.Post("/counters/{name}/increment", h.Increment)
+	return r
+}
+
+func do(t *testing.T, h http.Handler, method, target, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, rd)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, decodeBody(t, rec)
+}
+
+// decodeBody decodes the JSON body of rec, nil when it is empty
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var decoded map[string]any
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+			t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+		}
+	}
+	return decoded
+}
+
+func TestCounterRoutes(t *testing.T) {
+	h := newCounterRouter()
+
+	rec, _ := do(t, h, http.MethodPost, "/counters?namespace=team-a", `{"name":"visits"}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/counters/visits?namespace=team-a" {
+		t.Fatalf("Create = %d Location %q, want 201 /counters/visits?namespace=team-a", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec, body := do(t, h, http.MethodPost, "/counters?namespace=team-a", `{"name":"visits"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("second Create = %d %v, want 409", rec.Code, body)
+	}
+
+	do(t, h, http.MethodPost, "/counters/visits/increment?namespace=team-a", "")
+	rec, body := do(t, h, http.MethodPost, "/counters/visits/increment?namespace=team-a", "")
+	if counter, _ := body["counter"].(map[string]any); rec.Code != http.StatusOK || counter["value"] != 2.0 {
+		t.Fatalf("Increment = %d %v, want 200 value 2", rec.Code, body)
+	}
+
+	// the same name in the default namespace is another counter
+	if rec, body := do(t, h, http.MethodGet, "/counters/visits", ""); rec.Code != http.StatusNotFound || body["code"] != utils.CodeNotFound {
+		t.Fatalf("Get in the default namespace = %d %v, want 404", rec.Code, body)
+	}
+
+	rec, body = do(t, h, http.MethodGet, "/counters?namespace=team-a", "")
+	if counters, _ := body["counters"].([]any); rec.Code != http.StatusOK || len(counters) != 1 {
+		t.Fatalf("List = %d %v, want one counter", rec.Code, body)
+	}
+
+	// deleted on the admin listener only
+	if rec, _ := do(t, h, http.MethodDelete, "/counters/visits?namespace=team-a", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Delete = %d, want 405", rec.Code)
+	}
+}
+
+func TestCounterInvalidInput(t *testing.T) {
+	h := newCounterRouter()
+
+	for _, tc := range []struct {
+		method, target, body string
+		want                 int
+	}{
+		{http.MethodPost, "/counters", `{"name":"Bad Name"}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/counters", `{}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/counters?namespace=-x", `{"name":"ok"}`, http.StatusBadRequest},
+		{http.MethodGet, "/counters/UPPER", "", http.StatusBadRequest},
+		{http.MethodGet, "/counters?limit=0", "", http.StatusBadRequest},
+	} {
+		if rec, body := do(t, h, tc.method, tc.target, tc.body); rec.Code != tc.want {
+			t.Errorf("%s %s %s = %d %v, want %d", tc.method, tc.target, tc.body, rec.Code, body, tc.want)
+		}
+	}
+}
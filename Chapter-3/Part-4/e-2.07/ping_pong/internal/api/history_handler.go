@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"common/logging"
+	"common/utils"
+	"net/http"
+	"time"
+
+	"ping_pong/internal/store"
+)
+
+const (
+	// maxHistoryPoints bounds the buckets of one history response, a day of minutes
+	maxHistoryPoints = 1440
+	// maxRateWindow stays within the default minute retention
+	maxRateWindow = 24 * time.Hour
+)
+
+// defaultHistorySpan is the range of a history request without from
+var defaultHistorySpan = map[store.Resolution]time.Duration{
+	store.Minute: time.Hour,
+	store.Hour:   24 * time.Hour,
+	store.Day:    30 * 24 * time.Hour,
+}
+
+type HistoryHandler struct {
+	historyRepo store.HistoryRepo
+	counterRepo store.CounterRepo
+}
+
+func NewHistoryHandler(historyRepo store.HistoryRepo, counterRepo store.CounterRepo) *HistoryHandler {
+	return &HistoryHandler{
+		historyRepo: historyRepo,
+		counterRepo: counterRepo,
+	}
+}
+
+// CounterHistory serves the history of a named counter, 404 for a counter never created
+func (hh *HistoryHandler) CounterHistory(w http.ResponseWriter, r *http.Request) {
+	key, ok := hh.existingCounter(w, r)
+	if ok {
+		hh.writeHistory(w, r, key)
+	}
+}
+
+// CounterRate serves the increments of a named counter over the last window
+func (hh *HistoryHandler) CounterRate(w http.ResponseWriter, r *http.Request) {
+	key, ok := hh.existingCounter(w, r)
+	if ok {
+		hh.writeRate(w, r, key)
+	}
+}
+
+// PingPongHistory is CounterHistory for the pingpong counter, empty before the first increment
+func (hh *HistoryHandler) PingPongHistory(w http.ResponseWriter, r *http.Request) {
+	hh.writeHistory(w, r, store.PingPongKey)
+}
+
+// PingPongRate is CounterRate for the pingpong counter
+func (hh *HistoryHandler) PingPongRate(w http.ResponseWriter, r *http.Request) {
+	hh.writeRate(w, r, store.PingPongKey)
+}
+
+func (hh *HistoryHandler) existingCounter(w http.ResponseWriter, r *http.Request) (store.CounterKey, bool) {
+	key, err := counterKey(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return key, false
+	}
+	if _, err := hh.counterRepo.Get(r.Context(), key); err != nil {
+		writeRepoError(w, r, key, "could not read counter", err)
+		return key, false
+	}
+	return key, true
+}
+
+// writeHistory reads the resolution (minute by default), from and to (RFC 3339) parameters,
+// to defaults to now and from to a span of the resolution before it
+func (hh *HistoryHandler) writeHistory(w http.ResponseWriter, r *http.Request, key store.CounterKey) {
+	res, err := utils.QueryParam(r, "resolution", store.Minute, utils.Enum(store.Minute, store.Hour, store.Day))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	to, err := utils.QueryParam(r, "to", time.Now(), utils.Time(time.RFC3339))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	from, err := utils.QueryParam(r, "from", to.Add(-defaultHistorySpan[res]), utils.Time(time.RFC3339))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if from.After(to) {
+		utils.WriteError(w, r, utils.InvalidParameter("from", "must not be after to"))
+		return
+	}
+	if points := to.Sub(from.Truncate(res.Duration()))/res.Duration() + 1; points > maxHistoryPoints {
+		utils.WriteError(w, r, utils.InvalidParameter("from", "the range holds more than 1440 buckets, use a coarser resolution"))
+		return
+	}
+
+	buckets, err := hh.historyRepo.History(r.Context(), key, res, from, to)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("could not read counter history", "namespace", key.Namespace, "counter", key.Name, "error", err)
+		utils.InternalServerError(w, r)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"namespace":  key.Namespace,
+		"name":       key.Name,
+		"resolution": res,
+		"from":       from.UTC(),
+		"to":         to.UTC(),
+		"buckets":    buckets,
+	})
+}
+
+// writeRate reads the window parameter (1h by default, at most 24h). The count covers the
+// minute buckets overlapping the window, so it may include up to a minute before it.
+func (hh *HistoryHandler) writeRate(w http.ResponseWriter, r *http.Request, key store.CounterKey) {
+	window, err := utils.QueryParam(r, "window", time.Hour, utils.Duration())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if window < time.Minute || window > maxRateWindow {
+		utils.WriteError(w, r, utils.InvalidParameter("window", "must be between 1m and 24h"))
+		return
+	}
+
+	count, err := hh.historyRepo.CountSince(r.Context(), key, time.Now().Add(-window))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("could not read counter rate", "namespace", key.Namespace, "counter", key.Name, "error", err)
+		utils.InternalServerError(w, r)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"namespace":  key.Namespace,
+		"name":       key.Name,
+		"window":     window.String(),
+		"count":      count,
+		"per_second": float64(count) / window.Seconds(),
+	})
+}
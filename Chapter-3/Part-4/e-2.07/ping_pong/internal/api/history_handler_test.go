@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"ping_pong/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeHistory records the queries it receives and counts 1 per bucket
+type fakeHistory struct {
+	key   store.CounterKey
+	res   store.Resolution
+	since time.Time
+}
+
+func (f *fakeHistory) History(ctx context.Context, key store.CounterKey, res store.Resolution, from, to time.Time) ([]store.Bucket, error) {
+	f.key, f.res = key, res
+	buckets := []store.Bucket{}
+	for start := from.Truncate(res.Duration()); !start.After(to); start = start.Add(res.Duration()) {
+		buckets = append(buckets, store.Bucket{Start: start, Count: 1})
+	}
+	return buckets, nil
+}
+
+func (f *fakeHistory) CountSince(ctx context.Context, key store.CounterKey, since time.Time) (int64, error) {
+	f.key, f.since = key, since
+	return 120, nil
+}
+
+func newHistoryRouter(history *fakeHistory, counters *fakeCounters) http.Handler {
+	h := NewHistoryHandler(history, counters)
+	r := chi.NewRouter()
+	r.Get("/pingpong/history", h.PingPongHistory)
+	r.Get("/pingpong/rate", h.PingPongRate)
+	r.Get("/counters/{name}/history", h.CounterHistory)
+	r.Get("/counters/{name}/rate", h.CounterRate)
+	return r
+}
+
+func TestHistoryRoutes(t *testing.T) {
+	history := &fakeHistory{}
+	counters := &fakeCounters{counters: map[store.CounterKey]store.Counter{}}
+	h := newHistoryRouter(history, counters)
+
+	rec, body := do(t, h, http.MethodGet, "/pingpong/history?resolution=hour&from=2026-01-01T00:30:00Z&to=2026-01-01T05:00:00Z", "")
+	if buckets, _ := body["buckets"].([]any); rec.Code != http.StatusOK || len(buckets) != 6 {
+		t.Fatalf("pingpong history = %d %v, want 6 hourly buckets", rec.Code, body)
+	}
+	if history.key != store.PingPongKey || history.res != store.Hour {
+		t.Fatalf("History(%v, %s), want the pingpong counter by hour", history.key, history.res)
+	}
+
+	rec, body = do(t, h, http.MethodGet, "/pingpong/rate?window=2m", "")
+	if rec.Code != http.StatusOK || body["count"] != 120.0 || body["per_second"] != 1.0 {
+		t.Fatalf("pingpong rate = %d %v, want count 120 at 1/s", rec.Code, body)
+	}
+	if since := time.Since(history.since); since < 2*time.Minute || since > 3*time.Minute {
+		t.Fatalf("CountSince %s ago, want 2m", since)
+	}
+
+	if rec, _ := do(t, h, http.MethodGet, "/counters/visits/history", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("history of a missing counter = %d, want 404", rec.Code)
+	}
+	counters.Create(context.Background(), store.CounterKey{Namespace: store.DefaultNamespace, Name: "visits"})
+	if rec, body := do(t, h, http.MethodGet, "/counters/visits/history", ""); rec.Code != http.StatusOK || body["resolution"] != "minute" {
+		t.Fatalf("counter history = %d %v, want 200 by minute", rec.Code, body)
+	}
+	if rec, _ := do(t, h, http.MethodGet, "/counters/visits/rate", ""); rec.Code != http.StatusOK {
+		t.Fatalf("counter rate = %d, want 200", rec.Code)
+	}
+}
+
+func TestHistoryInvalidParams(t *testing.T) {
+	h := newHistoryRouter(&fakeHistory{}, &fakeCounters{counters: map[store.CounterKey]store.Counter{}})
+
+	for _, target := range []string{
+		"/pingpong/history?resolution=week",
+		"/pingpong/history?from=yesterday",
+		"/pingpong/history?from=2026-01-02T00:00:00Z&to=2026-01-01T00:00:00Z",
+		"/pingpong/history?from=2026-01-01T00:00:00Z&to=2026-01-03T00:00:00Z", // 2881 minutes
+		"/pingpong/rate?window=30s",
+		"/pingpong/rate?window=48h",
+	} {
+		if rec, body := do(t, h, http.MethodGet, target, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d %v, want 400", target, rec.Code, body)
+		}
+	}
+}
@@ -1,16 +1,27 @@
 package handler
 
 import (
+	"common/logging"
 	"common/utils"
+	"errors"
 	"net/http"
 
 	"ping_pong/internal/store"
 )
 
+// maxStep bounds the step of a single increment
+const maxStep = 1000
+
+// IdempotencyKeyHeader makes a POST /pingpong/increment safe to retry, IdempotentReplayedHeader
+// marks the responses replayed from an earlier request with the same key
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen     = 255
+)
+
 type PingPongHandler struct {
 	pingpongRepo store.PingPongRepo
-	// service..
-	// logger       *log.Logger
 }
 
 func NewPingPongHandler(pingpongRepo store.PingPongRepo) *PingPongHandler {
@@ -20,25 +31,81 @@ func NewPingPongHandler(pingpongRepo store.PingPongRepo) *PingPongHandler {
 }
 
 func (ph *PingPongHandler) Get(w http.ResponseWriter, r *http.Request) {
-	count, _ := ph.pingpongRepo.GetCurr()
-	utils.WriteJSON(
-		w, http.StatusOK,
-		utils.Envelope{
-			"count": count,
-		},
-	)
+	count, err := ph.pingpongRepo.GetCurr(r.Context())
+	if errors.Is(err, store.ErrCounterNotFound) {
+		count, err = 0, nil // no increment yet
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("could not read pingpong count", "error", err)
+		utils.InternalServerError(w, r)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"count": count,
+	})
 }
 
 func (ph *PingPongHandler) Update(w http.ResponseWriter, r *http.Request) {
-	count, err := ph.pingpongRepo.Update()
+	count, err := ph.pingpongRepo.Update(r.Context())
 	if err != nil {
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		logging.FromContext(r.Context()).Error("could not update pingpong count", "error", err)
+		utils.InternalServerError(w, r)
+		return
+	}
+	utils.OK(w, utils.Envelope{
+		"count": count,
+	})
+}
+
+// Increment adds the step query parameter (1 by default) to the counter, at most once per
+// Idempotency-Key
+func (ph *PingPongHandler) Increment(w http.ResponseWriter, r *http.Request) {
+	step, err := utils.QueryParam(r, "step", 1, utils.Int(1, maxStep))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if !validIdempotencyKey(key) {
+		utils.BadRequest(w, r, utils.CodeBadRequest, "the Idempotency-Key header must be 1 to 255 printable ASCII characters")
 		return
 	}
-	utils.WriteJSON(
-		w, http.StatusOK,
-		utils.Envelope{
-			"count": count,
-		},
-	)
+
+	count, replayed, err := ph.pingpongRepo.IncrementBy(r.Context(), step, key)
+	if errors.Is(err, store.ErrIdempotencyKeyReused) {
+		utils.WriteProblem(w, r, utils.NewProblem(http.StatusUnprocessableEntity, utils.CodeIdempotencyReuse,
+			"the Idempotency-Key was already used with another step"))
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("could not increment pingpong count", "step", step, "error", err)
+		utils.InternalServerError(w, r)
+		return
+	}
+	if replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+	}
+	utils.OK(w, utils.Envelope{
+		"count": count,
+	})
+}
+
+// Legacy is the original GET /pingpong, incrementing the counter on every request
+func (ph *PingPongHandler) Legacy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</pingpong/increment>; rel="successor-version"`)
+	ph.Update(w, r)
+}
+
+// validIdempotencyKey accepts an absent header or up to 255 visible ASCII characters
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
 }
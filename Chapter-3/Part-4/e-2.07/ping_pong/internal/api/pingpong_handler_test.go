@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"common/db/dbtest"
+	"common/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"ping_pong/internal/migrations"
+	"ping_pong/internal/store"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMain(m *testing.M) { os.Exit(dbtest.Main(m)) }
+
+// fakeRepo is an in-memory PingPongRepo
+type fakeRepo struct {
+	count int
+	err   error
+	keys  map[string][2]int // idempotency key -> step, count
+}
+
+func (f *fakeRepo) Update(ctx context.Context) (int, error) {
+	if f.err != nil {
+		return -1, f.err
+	}
+	f.count++
+	return f.count, nil
+}
+
+func (f *fakeRepo) GetCurr(ctx context.Context) (int, error) {
+	if f.err != nil {
+		return -1, f.err
+	}
+	return f.count, nil
+}
+
+func (f *fakeRepo) IncrementBy(ctx context.Context, step int, key string) (int, bool, error) {
+	if f.err != nil {
+		return -1, false, f.err
+	}
+	if prev, ok := f.keys[key]; ok && key != "" {
+		if prev[0] != step {
+			return -1, false, store.ErrIdempotencyKeyReused
+		}
+		return prev[1], true, nil
+	}
+	f.count += step
+	if key != "" {
+		if f.keys == nil {
+			f.keys = map[string][2]int{}
+		}
+		f.keys[key] = [2]int{step, f.count}
+	}
+	return f.count, false, nil
+}
+
+func serve(t *testing.T, h http.HandlerFunc) (int, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/pingpong", nil))
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, body
+}
+
+func TestGet(t *testing.T) {
+	h := NewPingPongHandler(&fakeRepo{count: 4})
+
+	code, body := serve(t, h.Get)
+	if code != http.StatusOK || body["count"] != 4.0 {
+		t.Fatalf("Get = %d %v, want 200 count 4", code, body)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	h := NewPingPongHandler(&fakeRepo{})
+
+	for want := 1.0; want <= 2; want++ {
+		code, body := serve(t, h.Update)
+		if code != http.StatusOK || body["count"] != want {
+			t.Fatalf("Update = %d %v, want 200 count %v", code, body, want)
+		}
+	}
+}
+
+func TestRepoError(t *testing.T) {
+	h := NewPingPongHandler(&fakeRepo{err: errors.New("connection refused")})
+
+	for name, fn := range map[string]http.HandlerFunc{"Get": h.Get, "Update": h.Update} {
+		code, body := serve(t, fn)
+		if code != http.StatusInternalServerError || body["code"] != utils.CodeInternal {
+			t.Errorf("%s = %d %v, want 500 %s", name, code, body, utils.CodeInternal)
+		}
+		if detail, _ := body["detail"].(string); strings.Contains(detail, "connection refused") {
+			t.Errorf("%s exposes the repository error: %q", name, detail)
+		}
+	}
+}
+
+func TestUpdateWithStore(t *testing.T) {
+	repo := store.NewPingPongStore(dbtest.New(t, migrations.FS), prometheus.NewRegistry())
+	h := NewPingPongHandler(repo)
+
+	if code, body := serve(t, h.Get); code != http.StatusOK || body["count"] != 0.0 {
+		t.Fatalf("Get before any update = %d %v, want 200 count 0", code, body)
+	}
+	serve(t, h.Update)
+	if code, body := serve(t, h.Get); code != http.StatusOK || body["count"] != 1.0 {
+		t.Fatalf("Get = %d %v, want 200 count 1", code, body)
+	}
+}
+
+func increment(t *testing.T, h *PingPongHandler, target, key string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	h.Increment(rec, req)
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	return rec, body
+}
+
+func TestIncrement(t *testing.T) {
+	h := NewPingPongHandler(&fakeRepo{})
+
+	if rec, body := increment(t, h, "/pingpong/increment", ""); rec.Code != http.StatusOK || body["count"] != 1.0 {
+		t.Fatalf("Increment = %d %v, want 200 count 1", rec.Code, body)
+	}
+	if rec, body := increment(t, h, "/pingpong/increment?step=5", ""); rec.Code != http.StatusOK || body["count"] != 6.0 {
+		t.Fatalf("Increment step 5 = %d %v, want 200 count 6", rec.Code, body)
+	}
+	for _, target := range []string{"/pingpong/increment?step=0", "/pingpong/increment?step=1001", "/pingpong/increment?step=x"} {
+		if rec, body := increment(t, h, target, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s = %d %v, want 400", target, rec.Code, body)
+		}
+	}
+}
+
+func TestIncrementIdempotent(t *testing.T) {
+	h := NewPingPongHandler(&fakeRepo{})
+
+	rec, body := increment(t, h, "/pingpong/increment?step=2", "req-1")
+	if rec.Code != http.StatusOK || body["count"] != 2.0 || rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("first Increment = %d %v, want 200 count 2 not replayed", rec.Code, body)
+	}
+	rec, body = increment(t, h, "/pingpong/increment?step=2", "req-1")
+	if rec.Code != http.StatusOK || body["count"] != 2.0 || rec.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("retried Increment = %d %v, want 200 count 2 replayed", rec.Code, body)
+	}
+	if rec, body := increment(t, h, "/pingpong/increment?step=3", "req-1"); rec.Code != http.StatusUnprocessableEntity || body["code"] != utils.CodeIdempotencyReuse {
+		t.Fatalf("Increment reusing the key = %d %v, want 422 %s", rec.Code, body, utils.CodeIdempotencyReuse)
+	}
+	if rec, _ := increment(t, h, "/pingpong/increment", "bad key"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("Increment with an invalid key = %d, want 400", rec.Code)
+	}
+}
+
+func TestIncrementWithStore(t *testing.T) {
+	repo := store.NewPingPongStore(dbtest.New(t, migrations.FS), prometheus.NewRegistry())
+	h := NewPingPongHandler(repo)
+
+	increment(t, h, "/pingpong/increment?step=3", "req-1")
+	rec, body := increment(t, h, "/pingpong/increment?step=3", "req-1")
+	if rec.Header().Get(IdempotentReplayedHeader) != "true" || body["count"] != 3.0 {
+		t.Fatalf("retried Increment = %v %v, want count 3 replayed", rec.Header(), body)
+	}
+	if code, body := serve(t, h.Get); code != http.StatusOK || body["count"] != 3.0 {
+		t.Fatalf("Get = %d %v, want 200 count 3", code, body)
+	}
+}
@@ -1,55 +1,204 @@
 package app
 
 import (
+	"common/boot"
 	"common/db"
+	"common/metrics"
+	common_server "common/server"
 	"context"
-	"fmt"
-	"sync"
+	"log/slog"
+	"time"
 
 	handler "ping_pong/internal/api"
 	"ping_pong/internal/migrations"
 	"ping_pong/internal/store"
+	"ping_pong/internal/store/memory"
+	"ping_pong/internal/store/redisstore"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
 )
 
+// Options are the run modes selected on the command line
+type Options struct {
+	// SkipMigrations leaves the migrations to a Job or init container (--migrate-only),
+	// readiness then reports whether the schema version matches the embedded migrations
+	SkipMigrations bool
+}
+
 type Application struct {
 	PingpongHandler *handler.PingPongHandler
-	wg              sync.WaitGroup
+	// CounterHandler, HistoryHandler and AdminHandler are nil unless the backend is postgres
+	CounterHandler *handler.CounterHandler
+	HistoryHandler *handler.HistoryHandler
+	AdminHandler   *handler.AdminHandler
+	// AdminTokens authenticate the AdminHandler routes, pprof and the log level, empty without ADMIN_TOKENS_FILE
+	AdminTokens common_server.BearerTokens
+	Probes      *common_server.Probes
+	Metrics     *prometheus.Registry
+	InFlight    *common_server.InFlight
+	Config      Config
+	db          *db.DBService
+	store       *store.PingPongStore
+	migrator    *db.Migrator
+	redis       *redis.Client
+	file        *memory.PingPongStore
+	opts        Options
 }
 
-func NewApplication() (*Application, error) {
-	postgresDB, err := db.Open()
-	if err != nil {
-		panic(err)
+func NewApplication(cfg Config, opts Options) (*Application, error) {
+	app := &Application{
+		Probes:   common_server.NewProbes(),
+		Metrics:  metrics.NewRegistry(),
+		InFlight: common_server.NewInFlight(),
+		Config:   cfg,
+		opts:     opts,
 	}
 
-	err = db.MigrateFS(postgresDB, migrations.FS, ".")
-	if err != nil {
-		panic(err)
+	if cfg.AdminTokensFile != "" {
+		tokens, err := common_server.LoadBearerTokens(cfg.AdminTokensFile)
+		if err != nil {
+			return nil, err
+		}
+		app.AdminTokens = tokens
 	}
 
-	pingpongRepo := store.NewPingPongStore(postgresDB)
-	pingpongHandler := handler.NewPingPongHandler(pingpongRepo)
-
-	app := &Application{
-		PingpongHandler: pingpongHandler,
+	var pingpongRepo store.PingPongRepo
+	switch cfg.Store.Backend {
+	case BackendPostgres:
+		if err := app.openPostgres(); err != nil {
+			return nil, err
+		}
+		pingpongRepo = app.store
+	case BackendMemory:
+		pingpongRepo = store.Instrument(memory.NewPingPongStore(), app.Metrics)
+	case BackendFile:
+		fileStore, err := memory.OpenFile(cfg.Store.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		app.file = fileStore
+		pingpongRepo = store.Instrument(fileStore, app.Metrics)
+	case BackendRedis:
+		app.redis = redis.NewClient(&redis.Options{
+			Addr:     cfg.Store.RedisAddr,
+			Password: cfg.Store.RedisPassword,
+			DB:       cfg.Store.RedisDB,
+		})
+		redisStore := redisstore.NewPingPongStore(app.redis, cfg.Store.RedisPrefix)
+		app.Probes.Register("redis", redisStore.Ping, common_server.CheckOptions{
+			Kinds: common_server.Readiness | common_server.Startup,
+		})
+		pingpongRepo = store.Instrument(redisStore, app.Metrics)
+	default:
+		return nil, cfg.Store.Validate()
 	}
+	app.PingpongHandler = handler.NewPingPongHandler(pingpongRepo)
 
 	return app, nil
 }
 
-func (a *Application) Start(ctx context.Context) error {
-	fmt.Println("Starting application services....")
+// openPostgres sets up the postgres store and the routes depending on it
+func (a *Application) openPostgres() error {
+	// the pool connects lazily, the postgres component waits for the database on start
+	postgresDB, err := db.New(a.Config.DB)
+	if err != nil {
+		return err
+	}
+	migrator, err := db.NewMigrator(postgresDB, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	a.Metrics.MustRegister(collectors.NewDBStatsCollector(postgresDB.DB, "pingpong"))
+	pgStore := store.NewPingPongStore(postgresDB, a.Metrics)
+	a.CounterHandler = handler.NewCounterHandler(pgStore)
+	a.HistoryHandler = handler.NewHistoryHandler(pgStore, pgStore)
+	a.AdminHandler = handler.NewAdminHandler(pgStore)
 
-	a.wg.Add(1)
+	a.Probes.Register("postgres", postgresDB.Ping, common_server.CheckOptions{
+		Kinds: common_server.Readiness | common_server.Startup,
+	})
+	if a.opts.SkipMigrations {
+		a.Probes.Register("schema", migrator.CheckVersion, common_server.CheckOptions{
+			Kinds: common_server.Readiness,
+		})
+	}
 
+	a.db, a.store, a.migrator = postgresDB, pgStore, migrator
 	return nil
 }
 
-func (a *Application) Stop() error {
-	fmt.Println("Stopping application services...")
+// StoreComponent names the supervisor component of the store, the http server depends on it
+func (a *Application) StoreComponent() string {
+	return a.Config.Store.Backend
+}
+
+// Register adds the application components to the supervisor
+func (a *Application) Register(sup *boot.Supervisor) {
+	switch {
+	case a.db != nil:
+		a.registerPostgres(sup)
+	case a.redis != nil:
+		sup.Register(a.StoreComponent(), &boot.Resource{
+			StopFn: func(ctx context.Context) error { return a.redis.Close() },
+		}, boot.Options{})
+	case a.file != nil:
+		sup.Register(a.StoreComponent(), &boot.Resource{
+			StopFn: func(ctx context.Context) error { return a.file.Close() },
+		}, boot.Options{})
+	default:
+		// the memory store has nothing to open or close
+		sup.Register(a.StoreComponent(), &boot.Resource{}, boot.Options{})
+	}
+	sup.OnShutdown(a.Probes.SetDraining)
+}
 
-	a.wg.Wait()
+func (a *Application) registerPostgres(sup *boot.Supervisor) {
+	sup.Register(BackendPostgres, &boot.Resource{
+		StartFn: func(ctx context.Context) error {
+			if err := a.db.Connect(ctx); err != nil {
+				return err
+			}
+			if a.opts.SkipMigrations {
+				if err := a.migrator.CheckVersion(ctx); err != nil {
+					slog.Warn("schema not up to date, the service stays unready until migrations run", "error", err)
+				}
+				return nil
+			}
+			results, err := a.migrator.Up(ctx)
+			for _, res := range results {
+				slog.Info("migration applied", "migration", res.Source.Path, "duration", res.Duration)
+			}
+			return err
+		},
+		StopFn: a.db.Close,
+	}, boot.Options{})
+	sup.Register("history-pruner", boot.WorkerFunc(a.pruneHistory), boot.Options{
+		DependsOn: []string{BackendPostgres},
+		Restart:   boot.RestartOnFailure,
+	})
+}
 
-	fmt.Println("Application stopped succesfully")
-	return nil
+// pruneHistory deletes the history buckets past their retention every prune interval.
+// Failures are logged, the next run catches up.
+func (a *Application) pruneHistory(ctx context.Context) error {
+	ticker := time.NewTicker(a.Config.History.PruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		deleted, err := a.store.PruneHistory(ctx, a.Config.History)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("could not prune the counter history", "error", err)
+			}
+			continue
+		}
+		slog.Debug("counter history pruned", "buckets", deleted)
+	}
 }
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"common/boot"
+	"common/db"
+	"common/logging"
+	common_server "common/server"
+	"common/tracing"
+
+	"ping_pong/internal/store"
+)
+
+// Schema is the Postgres schema owned by ping_pong, unless DB_SCHEMA overrides it
+const Schema = "pingpong_sc"
+
+// Config is the ping_pong configuration, bound with common/config
+type Config struct {
+	Port      int `env:"PORT" default:"8092"`
+	AdminPort int `env:"ADMIN_PORT" default:"9092"` // probes, metrics, pprof and admin endpoints, never exposed by the ingress
+	// LegacyPingPong keeps GET /pingpong incrementing the counter, for the clients not yet on
+	// POST /pingpong/increment. Off, GET /pingpong is a read like GET /pingpong/count.
+	LegacyPingPong bool `env:"PINGPONG_LEGACY_GET" default:"false"`
+	// AdminTokensFile lists the "<user> <token>" bearer tokens of the admin counter operations,
+	// pprof and the log level (e.g. mounted from a Secret), the user is the actor of the audit log.
+	// Unset, the counter operations are refused and the others served on loopback only.
+	AdminTokensFile string `env:"ADMIN_TOKENS_FILE"`
+	Store           StoreConfig
+	DB              db.Config // with STORE_BACKEND=postgres
+	History         store.HistoryConfig
+	HTTP            common_server.Policy
+	TLS             common_server.TLSConfig
+	Logging         logging.Config
+	Tracing         tracing.Config
+	Shutdown        boot.ShutdownConfig
+}
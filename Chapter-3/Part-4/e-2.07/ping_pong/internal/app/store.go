@@ -0,0 +1,36 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Store backends, selected by STORE_BACKEND
+const (
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory" // lost on restart, for local runs and tests
+	BackendFile     = "file"   // one JSON file, e.g. on a volume, a line appended per increment
+	BackendRedis    = "redis"  // Redis or a server speaking its protocol
+)
+
+var backends = []string{BackendPostgres, BackendMemory, BackendFile, BackendRedis}
+
+// StoreConfig selects the PingPongRepo backend. The named counters, their history and the
+// audited admin operations need postgres, the other backends serve the /pingpong routes only.
+type StoreConfig struct {
+	Backend       string `env:"STORE_BACKEND" default:"postgres"`
+	FilePath      string `env:"STORE_FILE_PATH" default:"/data/pingpong.json"`
+	RedisAddr     string `env:"STORE_REDIS_ADDR" default:"localhost:6379"`
+	RedisPassword string `env:"STORE_REDIS_PASSWORD" secret:"true"`
+	RedisDB       int    `env:"STORE_REDIS_DB" default:"0"`
+	// RedisPrefix starts every key, its hash tag keeps them in one cluster slot
+	RedisPrefix string `env:"STORE_REDIS_PREFIX" default:"{pingpong}:"`
+}
+
+// Validate rejects an unknown backend
+func (c StoreConfig) Validate() error {
+	if !slices.Contains(backends, c.Backend) {
+		return fmt.Errorf("unknown STORE_BACKEND %q, want one of %v", c.Backend, backends)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+-- Earlier releases created pingpong_counter in the default schema while tracking
+-- versions in pingpong_sc, move it to the service schema (the search_path).
+-- The moved table is marked with a comment so Down only moves back what Up moved.
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF current_schema() <> 'public'
+        AND to_regclass('public.pingpong_counter') IS NOT NULL
+        AND to_regclass(format('%I.pingpong_counter', current_schema())) IS NULL THEN
+        EXECUTE format('ALTER TABLE public.pingpong_counter SET SCHEMA %I', current_schema());
+        COMMENT ON TABLE pingpong_counter IS 'moved from public by 00002_pingpong_schema';
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS pingpong_counter (
+    id BIGSERIAL PRIMARY KEY,
+    count INTEGER NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF obj_description(to_regclass('pingpong_counter'), 'pg_class') = 'moved from public by 00002_pingpong_schema'
+        AND to_regclass('public.pingpong_counter') IS NULL THEN
+        COMMENT ON TABLE pingpong_counter IS NULL;
+        EXECUTE format('ALTER TABLE %I.pingpong_counter SET SCHEMA public', current_schema());
+    END IF;
+END $$;
+-- +goose StatementEnd
@@ -0,0 +1,71 @@
+-- Named counters, grouped by namespace (one per team/tenant). The pingpong counter
+-- becomes default/pingpong, pingpong_counter is kept for a rollback.
+-- The migration runs before the rolling update, while the pods of the previous release
+-- still increment pingpong_counter: a trigger adds their increments to default/pingpong
+-- until they are gone. It is created before the copy, in the same transaction, so no
+-- increment falls between the two. It only mirrors pingpong_counter to counters, the
+-- reverse direction would lock the two rows in opposite orders and deadlock.
+-- Drop it in a later release, once no pod of a release older than this one can run.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS counters (
+    namespace TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (namespace, name)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- search_path FROM CURRENT: counters resolves to the service schema whatever the writer's search_path
+CREATE OR REPLACE FUNCTION pingpong_counter_mirror() RETURNS trigger
+SET search_path FROM CURRENT AS $$
+DECLARE
+    delta BIGINT;
+BEGIN
+    delta := NEW.count;
+    IF TG_OP = 'UPDATE' THEN
+        delta := NEW.count - OLD.count;
+    END IF;
+    INSERT INTO counters (namespace, name, value)
+    VALUES ('default', 'pingpong', delta)
+    ON CONFLICT (namespace, name) DO UPDATE
+    SET value = counters.value + EXCLUDED.value, updated_at = now();
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER pingpong_counter_mirror
+AFTER INSERT OR UPDATE OF count ON pingpong_counter
+FOR EACH ROW WHEN (NEW.id = 1)
+EXECUTE FUNCTION pingpong_counter_mirror();
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO counters (namespace, name, value)
+SELECT 'default', 'pingpong', count FROM pingpong_counter WHERE id = 1
+ON CONFLICT (namespace, name) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS pingpong_counter_mirror ON pingpong_counter;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS pingpong_counter_mirror();
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO pingpong_counter (id, count)
+SELECT 1, value FROM counters WHERE namespace = 'default' AND name = 'pingpong'
+ON CONFLICT (id) DO UPDATE SET count = EXCLUDED.count;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE counters;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- Increments per counter and UTC minute, hour and day. Every increment is added to its three
+-- buckets, so the coarser ones are rolled up as they are written and outlive the minute ones.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS counter_buckets (
+    namespace TEXT NOT NULL,
+    name TEXT NOT NULL,
+    resolution TEXT NOT NULL CHECK (resolution IN ('minute', 'hour', 'day')),
+    bucket_start TIMESTAMPTZ NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (namespace, name, resolution, bucket_start),
+    FOREIGN KEY (namespace, name) REFERENCES counters (namespace, name) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS counter_buckets_retention_idx ON counter_buckets (resolution, bucket_start);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE counter_buckets;
+-- +goose StatementEnd
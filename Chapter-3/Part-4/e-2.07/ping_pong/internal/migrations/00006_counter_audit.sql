@@ -0,0 +1,42 @@
+-- Admin changes to the counters (reset, set, snapshot) and the named snapshots. The audit
+-- log keeps the entries of deleted counters, the snapshots go with their counter.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS counter_audit (
+    id BIGSERIAL PRIMARY KEY,
+    namespace TEXT NOT NULL,
+    name TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('reset', 'set', 'snapshot')),
+    actor TEXT NOT NULL,
+    old_value BIGINT NOT NULL,
+    new_value BIGINT NOT NULL,
+    snapshot TEXT,
+    at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS counter_audit_counter_idx ON counter_audit (namespace, name, id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS counter_snapshots (
+    namespace TEXT NOT NULL,
+    name TEXT NOT NULL,
+    label TEXT NOT NULL,
+    value BIGINT NOT NULL,
+    actor TEXT NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (namespace, name, label),
+    FOREIGN KEY (namespace, name) REFERENCES counters (namespace, name) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE counter_snapshots;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE counter_audit;
+-- +goose StatementEnd
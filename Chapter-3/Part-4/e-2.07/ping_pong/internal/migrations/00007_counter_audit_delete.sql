@@ -0,0 +1,18 @@
+-- Counters are deleted through the admin listener, the deletions are audited too.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE counter_audit
+    DROP CONSTRAINT counter_audit_action_check,
+    ADD CONSTRAINT counter_audit_action_check CHECK (action IN ('reset', 'set', 'snapshot', 'delete'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM counter_audit WHERE action = 'delete';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE counter_audit
+    DROP CONSTRAINT counter_audit_action_check,
+    ADD CONSTRAINT counter_audit_action_check CHECK (action IN ('reset', 'set', 'snapshot'));
+-- +goose StatementEnd
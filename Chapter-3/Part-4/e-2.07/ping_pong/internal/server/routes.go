@@ -1,17 +1,77 @@
 package server
 
 import (
+	"common/config"
 	"net/http"
 
 	"ping_pong/internal/app"
 
 	common_server "common/server"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func RegisterRoutes(app *app.Application) http.Handler {
-	r := common_server.NewRouter()
+	r := common_server.NewRouter(common_server.RouterConfig{
+		Metrics:  app.Metrics,
+		InFlight: app.InFlight,
+		Policy:   &app.Config.HTTP,
+		Health:   app.Probes, // log_output's readiness check
+	})
+
+	r.Get("/pingpong/count", app.PingpongHandler.Get)
+	r.Post("/pingpong/increment", app.PingpongHandler.Increment)
+	if app.Config.LegacyPingPong {
+		r.Get("/pingpong", app.PingpongHandler.Legacy)
+	} else {
+		r.Get("/pingpong", app.PingpongHandler.Get)
+	}
+
+	// named counters and history are served by the postgres backend only, they are deleted on
+	// the admin listener
+	if app.HistoryHandler != nil {
+		r.Get("/pingpong/history", app.HistoryHandler.PingPongHistory)
+		r.Get("/pingpong/rate", app.HistoryHandler.PingPongRate)
+	}
+	if app.CounterHandler != nil {
+		r.Route("/counters", func(r chi.Router) {
+			r.Get("/", app.CounterHandler.List)
+			r.Post("/", app.CounterHandler.Create)
+			r.Get("/{name}", app.CounterHandler.Get)
+			r.Post("/{name}/increment", app.CounterHandler.Increment)
+			r.Get("/{name}/history", app.HistoryHandler.CounterHistory)
+			r.Get("/{name}/rate", app.HistoryHandler.CounterRate)
+		})
+	}
+
+	return r
+}
+
+// RegisterAdminRoutes returns the handler of the admin listener, preStop serves the kubelet preStop hook
+func RegisterAdminRoutes(app *app.Application, preStop http.Handler) http.Handler {
+	r := common_server.NewAdminRouter(common_server.AdminConfig{
+		Probes:  app.Probes,
+		Metrics: app.Metrics,
+		PreStop: preStop,
+		Config:  config.Handler(app.Config),
+		Tokens:  app.AdminTokens,
+	})
 
-	r.Get("/pingpong", app.PingpongHandler.Update)
+	// audited counter operations, kept off the public listener and authenticated with the
+	// ADMIN_TOKENS_FILE bearer tokens: the admin port is reachable from any pod
+	if app.AdminHandler != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(common_server.RequireBearer(app.AdminTokens))
+			r.Route("/admin/counters/{name}", func(r chi.Router) {
+				r.Delete("/", app.AdminHandler.Delete)
+				r.Post("/reset", app.AdminHandler.Reset)
+				r.Put("/value", app.AdminHandler.Set)
+				r.Get("/snapshots", app.AdminHandler.Snapshots)
+				r.Post("/snapshots", app.AdminHandler.Snapshot)
+			})
+			r.Get("/admin/audit", app.AdminHandler.AuditLog)
+		})
+	}
 
 	return r
 }
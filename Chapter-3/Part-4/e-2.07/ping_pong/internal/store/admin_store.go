@@ -0,0 +1,226 @@
+package store
+
+import (
+	common_db "common/db"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const auditColumns = `id, namespace, name, action, actor, old_value, new_value, COALESCE(snapshot, ''), at`
+
+func scanAuditEntry(row interface{ Scan(dest ...any) error }) (AuditEntry, error) {
+	var e AuditEntry
+	err := row.Scan(&e.ID, &e.Namespace, &e.Name, &e.Action, &e.Actor, &e.OldValue, &e.NewValue, &e.Snapshot, &e.At)
+	return e, err
+}
+
+func (ps *PingPongStore) Reset(ctx context.Context, key CounterKey, actor string) (AuditEntry, error) {
+	return ps.set(ctx, key, 0, ActionReset, actor)
+}
+
+func (ps *PingPongStore) Set(ctx context.Context, key CounterKey, value int64, actor string) (AuditEntry, error) {
+	return ps.set(ctx, key, value, ActionSet, actor)
+}
+
+func (ps *PingPongStore) set(ctx context.Context, key CounterKey, value int64, action string, actor string) (AuditEntry, error) {
+	var entry AuditEntry
+	err := ps.dbService.WithTx(ctx, nil, func(ctx context.Context, _ common_db.Querier) error {
+		old, err := ps.lockValue(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		query := `
+		UPDATE counters
+		SET value = $3, updated_at = now()
+		WHERE namespace = $1 AND name = $2
+		`
+		qctx, done := ps.startQuery(ctx, "counter_"+action, query)
+		_, err = ps.dbService.Querier(ctx).ExecContext(qctx, query, key.Namespace, key.Name, value)
+		done(err)
+		if err != nil {
+			return err
+		}
+
+		entry, err = ps.audit(ctx, key, action, actor, old, value, "")
+		return err
+	})
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	if key == PingPongKey {
+		ps.metrics.count.Set(float64(value))
+	}
+	return entry, nil
+}
+
+func (ps *PingPongStore) DeleteAudited(ctx context.Context, key CounterKey, actor string) (AuditEntry, error) {
+	var entry AuditEntry
+	err := ps.dbService.WithTx(ctx, nil, func(ctx context.Context, _ common_db.Querier) error {
+		old, err := ps.lockValue(ctx, key)
+		if err != nil {
+			return err
+		}
+		if err := ps.Delete(ctx, key); err != nil {
+			return err
+		}
+		entry, err = ps.audit(ctx, key, ActionDelete, actor, old, 0, "")
+		return err
+	})
+	if err != nil {
+		return AuditEntry{}, err
+	}
+	return entry, nil
+}
+
+func (ps *PingPongStore) Snapshot(ctx context.Context, key CounterKey, label string, actor string) (Snapshot, error) {
+	var snap Snapshot
+	err := ps.dbService.WithTx(ctx, nil, func(ctx context.Context, _ common_db.Querier) error {
+		value, err := ps.lockValue(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		query := `
+		INSERT INTO counter_snapshots (namespace, name, label, value, actor) VALUES ($1, $2, $3, $4, $5)
+		RETURNING label, value, actor, taken_at
+		`
+		qctx, done := ps.startQuery(ctx, "snapshot_create", query)
+		err = ps.dbService.Querier(ctx).QueryRowContext(qctx, query, key.Namespace, key.Name, label, value, actor).
+			Scan(&snap.Label, &snap.Value, &snap.Actor, &snap.TakenAt)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			done(nil)
+			return ErrSnapshotExists
+		}
+		done(err)
+		if err != nil {
+			return err
+		}
+
+		_, err = ps.audit(ctx, key, ActionSnapshot, actor, value, value, label)
+		return err
+	})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// lockValue reads the value of the counter and locks it until the end of the transaction
+func (ps *PingPongStore) lockValue(ctx context.Context, key CounterKey) (int64, error) {
+	query := `
+	SELECT value
+	FROM counters
+	WHERE namespace = $1 AND name = $2
+	FOR UPDATE
+	`
+
+	var value int64
+	ctx, done := ps.startQuery(ctx, "counter_lock", query)
+	err := ps.dbService.Querier(ctx).QueryRowContext(ctx, query, key.Namespace, key.Name).Scan(&value)
+	done(err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrCounterNotFound
+	}
+	return value, err
+}
+
+func (ps *PingPongStore) audit(ctx context.Context, key CounterKey, action, actor string, oldValue, newValue int64, snapshot string) (AuditEntry, error) {
+	query := `
+	INSERT INTO counter_audit (namespace, name, action, actor, old_value, new_value, snapshot)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+	RETURNING ` + auditColumns
+
+	ctx, done := ps.startQuery(ctx, "audit_insert", query)
+	entry, err := scanAuditEntry(ps.dbService.Querier(ctx).QueryRowContext(ctx, query,
+		key.Namespace, key.Name, action, actor, oldValue, newValue, snapshot))
+	done(err)
+	return entry, err
+}
+
+func (ps *PingPongStore) Snapshots(ctx context.Context, key CounterKey) ([]Snapshot, error) {
+	// the left join tells a counter without snapshots (one row of nulls) from a missing one
+	query := `
+	SELECT s.label, s.value, s.actor, s.taken_at
+	FROM counters c
+	LEFT JOIN counter_snapshots s ON s.namespace = c.namespace AND s.name = c.name
+	WHERE c.namespace = $1 AND c.name = $2
+	ORDER BY s.taken_at
+	`
+
+	ctx, done := ps.startQuery(ctx, "snapshot_list", query)
+	snapshots, err := ps.snapshots(ctx, query, key.Namespace, key.Name)
+	done(err)
+	return snapshots, err
+}
+
+func (ps *PingPongStore) snapshots(ctx context.Context, query string, args ...any) ([]Snapshot, error) {
+	rows, err := ps.dbService.Querier(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var (
+			label, actor sql.NullString
+			value        sql.NullInt64
+			takenAt      sql.NullTime
+		)
+		if err := rows.Scan(&label, &value, &actor, &takenAt); err != nil {
+			return nil, err
+		}
+		if snapshots == nil {
+			snapshots = []Snapshot{}
+		}
+		if label.Valid {
+			snapshots = append(snapshots, Snapshot{Label: label.String, Value: value.Int64, Actor: actor.String, TakenAt: takenAt.Time})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if snapshots == nil {
+		return nil, ErrCounterNotFound
+	}
+	return snapshots, nil
+}
+
+func (ps *PingPongStore) AuditLog(ctx context.Context, filter AuditFilter, limit, offset int) ([]AuditEntry, error) {
+	query := `
+	SELECT ` + auditColumns + `
+	FROM counter_audit
+	WHERE ($1 = '' OR namespace = $1) AND ($2 = '' OR name = $2) AND ($3 = '' OR action = $3)
+	ORDER BY id DESC
+	LIMIT $4 OFFSET $5
+	`
+
+	ctx, done := ps.startQuery(ctx, "audit_list", query)
+	entries, err := ps.auditLog(ctx, query, filter.Namespace, filter.Name, filter.Action, limit, offset)
+	done(err)
+	return entries, err
+}
+
+func (ps *PingPongStore) auditLog(ctx context.Context, query string, args ...any) ([]AuditEntry, error) {
+	rows, err := ps.dbService.Querier(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
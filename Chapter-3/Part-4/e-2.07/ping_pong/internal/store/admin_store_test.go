@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestResetAndSet(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := ps.Reset(ctx, PingPongKey, "alice"); !errors.Is(err, ErrCounterNotFound) {
+		t.Fatalf("Reset before the first increment = %v, want %v", err, ErrCounterNotFound)
+	}
+	if _, _, err := ps.IncrementBy(ctx, 7, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := ps.Set(ctx, PingPongKey, 42, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Action != ActionSet || entry.Actor != "alice" || entry.OldValue != 7 || entry.NewValue != 42 {
+		t.Fatalf("Set audit = %+v, want alice set 7 -> 42", entry)
+	}
+	if got, err := ps.GetCurr(ctx); err != nil || got != 42 {
+		t.Fatalf("GetCurr after Set = %d, %v, want 42", got, err)
+	}
+
+	if entry, err = ps.Reset(ctx, PingPongKey, "bob"); err != nil || entry.OldValue != 42 || entry.NewValue != 0 {
+		t.Fatalf("Reset audit = %+v, %v, want 42 -> 0", entry, err)
+	}
+	if got, err := ps.GetCurr(ctx); err != nil || got != 0 {
+		t.Fatalf("GetCurr after Reset = %d, %v, want 0", got, err)
+	}
+
+	entries, err := ps.AuditLog(ctx, AuditFilter{Name: PingPongKey.Name}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != ActionReset || entries[1].Action != ActionSet {
+		t.Fatalf("AuditLog = %+v, want the reset then the set", entries)
+	}
+	if entries, err := ps.AuditLog(ctx, AuditFilter{Action: ActionSnapshot}, 10, 0); err != nil || len(entries) != 0 {
+		t.Fatalf("AuditLog of snapshots = %+v, %v, want none", entries, err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+	key := CounterKey{Namespace: "team-a", Name: "visits"}
+
+	if _, err := ps.Snapshots(ctx, key); !errors.Is(err, ErrCounterNotFound) {
+		t.Fatalf("Snapshots of a missing counter = %v, want %v", err, ErrCounterNotFound)
+	}
+	if _, err := ps.Create(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if snaps, err := ps.Snapshots(ctx, key); err != nil || len(snaps) != 0 {
+		t.Fatalf("Snapshots = %+v, %v, want none", snaps, err)
+	}
+	if _, err := ps.Increment(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := ps.Snapshot(ctx, key, "before-reset", "alice")
+	if err != nil || snap.Value != 1 || snap.Label != "before-reset" {
+		t.Fatalf("Snapshot = %+v, %v, want before-reset at 1", snap, err)
+	}
+	if _, err := ps.Snapshot(ctx, key, "before-reset", "alice"); !errors.Is(err, ErrSnapshotExists) {
+		t.Fatalf("second Snapshot = %v, want %v", err, ErrSnapshotExists)
+	}
+
+	snaps, err := ps.Snapshots(ctx, key)
+	if err != nil || len(snaps) != 1 || snaps[0].Actor != "alice" {
+		t.Fatalf("Snapshots = %+v, %v, want the one of alice", snaps, err)
+	}
+	entries, err := ps.AuditLog(ctx, AuditFilter{Namespace: key.Namespace, Action: ActionSnapshot}, 10, 0)
+	if err != nil || len(entries) != 1 || entries[0].Snapshot != "before-reset" {
+		t.Fatalf("AuditLog = %+v, %v, want the snapshot", entries, err)
+	}
+
+	// the audit log outlives the counter
+	entry, err := ps.DeleteAudited(ctx, key, "bob")
+	if err != nil || entry.Action != ActionDelete || entry.OldValue != 1 || entry.Actor != "bob" {
+		t.Fatalf("DeleteAudited = %+v, %v, want a delete by bob from 1", entry, err)
+	}
+	if _, err := ps.Get(ctx, key); !errors.Is(err, ErrCounterNotFound) {
+		t.Fatalf("Get after DeleteAudited = %v, want %v", err, ErrCounterNotFound)
+	}
+	if entries, err := ps.AuditLog(ctx, AuditFilter{Namespace: key.Namespace}, 10, 0); err != nil || len(entries) != 2 {
+		t.Fatalf("AuditLog after DeleteAudited = %+v, %v, want the snapshot and delete entries", entries, err)
+	}
+	if _, err := ps.DeleteAudited(ctx, key, "bob"); !errors.Is(err, ErrCounterNotFound) {
+		t.Fatalf("second DeleteAudited = %v, want %v", err, ErrCounterNotFound)
+	}
+}
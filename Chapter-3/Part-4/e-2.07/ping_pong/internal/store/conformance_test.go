@@ -0,0 +1,19 @@
+package store_test
+
+import (
+	"common/db/dbtest"
+	"testing"
+
+	"ping_pong/internal/migrations"
+	"ping_pong/internal/store"
+	"ping_pong/internal/store/storetest"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the suite lives outside package store, which it imports
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.PingPongRepo {
+		return store.NewPingPongStore(dbtest.New(t, migrations.FS), prometheus.NewRegistry())
+	})
+}
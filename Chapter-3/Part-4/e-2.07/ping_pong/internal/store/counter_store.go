@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueViolation is the Postgres error code of a duplicate primary key
+const uniqueViolation = "23505"
+
+const counterColumns = `namespace, name, value, created_at, updated_at`
+
+func scanCounter(row interface{ Scan(dest ...any) error }) (Counter, error) {
+	var c Counter
+	err := row.Scan(&c.Namespace, &c.Name, &c.Value, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+func (ps *PingPongStore) Create(ctx context.Context, key CounterKey) (Counter, error) {
+	query := `
+	INSERT INTO counters (namespace, name) VALUES ($1, $2)
+	RETURNING ` + counterColumns
+
+	ctx, done := ps.startQuery(ctx, "counter_create", query)
+	c, err := scanCounter(ps.dbService.Querier(ctx).QueryRowContext(ctx, query, key.Namespace, key.Name))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		done(nil)
+		return Counter{}, ErrCounterExists
+	}
+	done(err)
+	return c, err
+}
+
+func (ps *PingPongStore) Get(ctx context.Context, key CounterKey) (Counter, error) {
+	query := `
+	SELECT ` + counterColumns + `
+	FROM counters
+	WHERE namespace = $1 AND name = $2
+	`
+
+	ctx, done := ps.startQuery(ctx, "counter_get", query)
+	c, err := scanCounter(ps.dbService.Querier(ctx).QueryRowContext(ctx, query, key.Namespace, key.Name))
+	done(err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Counter{}, ErrCounterNotFound
+	}
+	return c, err
+}
+
+func (ps *PingPongStore) Increment(ctx context.Context, key CounterKey) (Counter, error) {
+	query := `
+	WITH c AS (
+		UPDATE counters
+		SET value = value + $3, updated_at = now()
+		WHERE namespace = $1 AND name = $2
+		RETURNING ` + counterColumns + `
+	)` + recordBuckets + `
+	SELECT ` + counterColumns + ` FROM c
+	`
+
+	ctx, done := ps.startQuery(ctx, "counter_increment", query)
+	c, err := scanCounter(ps.dbService.Querier(ctx).QueryRowContext(ctx, query, key.Namespace, key.Name, 1))
+	done(err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Counter{}, ErrCounterNotFound
+	}
+	return c, err
+}
+
+func (ps *PingPongStore) List(ctx context.Context, namespace string, limit, offset int) ([]Counter, error) {
+	query := `
+	SELECT ` + counterColumns + `
+	FROM counters
+	WHERE namespace = $1
+	ORDER BY name
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, done := ps.startQuery(ctx, "counter_list", query)
+	counters, err := ps.list(ctx, query, namespace, limit, offset)
+	done(err)
+	return counters, err
+}
+
+func (ps *PingPongStore) list(ctx context.Context, query string, args ...any) ([]Counter, error) {
+	rows, err := ps.dbService.Querier(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counters := []Counter{}
+	for rows.Next() {
+		c, err := scanCounter(rows)
+		if err != nil {
+			return nil, err
+		}
+		counters = append(counters, c)
+	}
+	return counters, rows.Err()
+}
+
+func (ps *PingPongStore) Delete(ctx context.Context, key CounterKey) error {
+	query := `
+	DELETE FROM counters
+	WHERE namespace = $1 AND name = $2
+	`
+
+	ctx, done := ps.startQuery(ctx, "counter_delete", query)
+	res, err := ps.dbService.Querier(ctx).ExecContext(ctx, query, key.Namespace, key.Name)
+	done(err)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrCounterNotFound
+	}
+	return nil
+}
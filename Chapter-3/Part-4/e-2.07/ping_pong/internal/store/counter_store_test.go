@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCounterLifecycle(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+	key := CounterKey{Namespace: "team-a", Name: "visits"}
+
+	if _, err := ps.Get(ctx, key); !errors.Is(err, ErrCounterNotFound) {
+		t.Fatalf("Get before Create = %v, want %v", err, ErrCounterNotFound)
+	}
+	if _, err := ps.Increment(ctx, key); !errors.Is(err, ErrCounterNotFound) {
+		t.Fatalf("Increment before Create = %v, want %v", err, ErrCounterNotFound)
+	}
+
+	c, err := ps.Create(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Namespace != key.Namespace || c.Name != key.Name || c.Value != 0 {
+		t.Fatalf("Create = %+v, want %v at 0", c, key)
+	}
+	if _, err := ps.Create(ctx, key); !errors.Is(err, ErrCounterExists) {
+		t.Fatalf("second Create = %v, want %v", err, ErrCounterExists)
+	}
+
+	for want := int64(1); want <= 2; want++ {
+		c, err := ps.Increment(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != want {
+			t.Fatalf("Increment = %d, want %d", c.Value, want)
+		}
+	}
+	if c, err := ps.Get(ctx, key); err != nil || c.Value != 2 || c.UpdatedAt.Before(c.CreatedAt) {
+		t.Fatalf("Get = %+v, %v, want value 2", c, err)
+	}
+
+	if err := ps.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Delete(ctx, key); !errors.Is(err, ErrCounterNotFound) {
+		t.Fatalf("second Delete = %v, want %v", err, ErrCounterNotFound)
+	}
+}
+
+func TestCounterNamespaces(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	for _, key := range []CounterKey{{"a", "x"}, {"a", "y"}, {"a", "z"}, {"b", "x"}} {
+		if _, err := ps.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ps.Increment(ctx, CounterKey{"b", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := ps.Get(ctx, CounterKey{"a", "x"}); err != nil || c.Value != 0 {
+		t.Fatalf("a/x = %+v, %v, want 0: increments leak across namespaces", c, err)
+	}
+
+	page, err := ps.List(ctx, "a", 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].Name != "y" || page[1].Name != "z" {
+		t.Fatalf("List(a, 2, 1) = %+v, want y and z", page)
+	}
+	if empty, err := ps.List(ctx, "c", 10, 0); err != nil || len(empty) != 0 {
+		t.Fatalf("List(c) = %+v, %v, want none", empty, err)
+	}
+}
+
+func TestPingpongIsDefaultCounter(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := ps.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ps.Get(ctx, CounterKey{Namespace: DefaultNamespace, Name: "pingpong"})
+	if err != nil || c.Value != 1 {
+		t.Fatalf("default/pingpong = %+v, %v, want 1", c, err)
+	}
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// recordBuckets adds the step ($3) to the UTC minute, hour and day buckets of the counter
+// returned by the "c" CTE of the increment statement it is appended to
+const recordBuckets = `
+	, buckets AS (
+		INSERT INTO counter_buckets (namespace, name, resolution, bucket_start, count)
+		SELECT c.namespace, c.name, r.resolution, date_trunc(r.resolution, now(), 'UTC'), $3
+		FROM c CROSS JOIN (VALUES ('minute'), ('hour'), ('day')) AS r(resolution)
+		ON CONFLICT (namespace, name, resolution, bucket_start) DO UPDATE
+		SET count = counter_buckets.count + EXCLUDED.count
+	)`
+
+// HistoryConfig is how long the buckets of each resolution are kept, bound with common/config
+type HistoryConfig struct {
+	MinuteRetention time.Duration `env:"HISTORY_MINUTE_RETENTION" default:"48h"`
+	HourRetention   time.Duration `env:"HISTORY_HOUR_RETENTION" default:"720h"`
+	DayRetention    time.Duration `env:"HISTORY_DAY_RETENTION" default:"0s"` // 0 keeps the day buckets forever
+	PruneInterval   time.Duration `env:"HISTORY_PRUNE_INTERVAL" default:"10m"`
+}
+
+// Validate rejects a retention shorter than its resolution and a missing prune interval
+func (c HistoryConfig) Validate() error {
+	var errs []error
+	if c.MinuteRetention < time.Minute {
+		errs = append(errs, fmt.Errorf("minute retention %s is shorter than a minute", c.MinuteRetention))
+	}
+	if c.HourRetention < time.Hour {
+		errs = append(errs, fmt.Errorf("hour retention %s is shorter than an hour", c.HourRetention))
+	}
+	if c.DayRetention != 0 && c.DayRetention < 24*time.Hour {
+		errs = append(errs, fmt.Errorf("day retention %s is shorter than a day", c.DayRetention))
+	}
+	if c.PruneInterval <= 0 {
+		errs = append(errs, fmt.Errorf("prune interval must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (ps *PingPongStore) History(ctx context.Context, key CounterKey, res Resolution, from, to time.Time) ([]Bucket, error) {
+	// the series is built on UTC timestamps so that day steps ignore the session time zone
+	query := `
+	SELECT s.start AT TIME ZONE 'UTC', COALESCE(b.count, 0)
+	FROM generate_series(
+		date_trunc($3::text, $4::timestamptz, 'UTC') AT TIME ZONE 'UTC',
+		$5::timestamptz AT TIME ZONE 'UTC',
+		('1 ' || $3::text)::interval
+	) AS s(start)
+	LEFT JOIN counter_buckets b
+		ON b.namespace = $1 AND b.name = $2 AND b.resolution = $3::text
+		AND b.bucket_start = s.start AT TIME ZONE 'UTC'
+	ORDER BY s.start
+	`
+
+	ctx, done := ps.startQuery(ctx, "history", query)
+	buckets, err := ps.history(ctx, query, key.Namespace, key.Name, string(res), from, to)
+	done(err)
+	return buckets, err
+}
+
+func (ps *PingPongStore) history(ctx context.Context, query string, args ...any) ([]Bucket, error) {
+	rows, err := ps.dbService.Querier(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []Bucket{}
+	for rows.Next() {
+		var b Bucket
+		if err := rows.Scan(&b.Start, &b.Count); err != nil {
+			return nil, err
+		}
+		b.Start = b.Start.UTC()
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+func (ps *PingPongStore) CountSince(ctx context.Context, key CounterKey, since time.Time) (int64, error) {
+	query := `
+	SELECT COALESCE(sum(count), 0)::bigint
+	FROM counter_buckets
+	WHERE namespace = $1 AND name = $2 AND resolution = 'minute'
+		AND bucket_start >= date_trunc('minute', $3::timestamptz, 'UTC')
+	`
+
+	var count int64
+	ctx, done := ps.startQuery(ctx, "count_since", query)
+	err := ps.dbService.Querier(ctx).QueryRowContext(ctx, query, key.Namespace, key.Name, since).Scan(&count)
+	done(err)
+	return count, err
+}
+
+// PruneHistory deletes the buckets older than their retention and returns how many were deleted
+func (ps *PingPongStore) PruneHistory(ctx context.Context, cfg HistoryConfig) (int64, error) {
+	query := `
+	DELETE FROM counter_buckets
+	WHERE (resolution = 'minute' AND bucket_start < now() - make_interval(secs => $1))
+		OR (resolution = 'hour' AND bucket_start < now() - make_interval(secs => $2))
+		OR ($3::float8 > 0 AND resolution = 'day' AND bucket_start < now() - make_interval(secs => $3))
+	`
+
+	ctx, done := ps.startQuery(ctx, "history_prune", query)
+	res, err := ps.dbService.Querier(ctx).ExecContext(ctx, query,
+		cfg.MinuteRetention.Seconds(), cfg.HourRetention.Seconds(), cfg.DayRetention.Seconds())
+	done(err)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	if _, _, err := ps.IncrementBy(ctx, 3, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	buckets, err := ps.History(ctx, PingPongKey, Minute, now.Add(-10*time.Minute), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 11 || !buckets[10].Start.Equal(now.Truncate(time.Minute)) {
+		t.Fatalf("History over 10 minutes = %+v, want 11 buckets up to %s", buckets, now.Truncate(time.Minute))
+	}
+	if got := sum(buckets); got != 4 {
+		t.Fatalf("History over 10 minutes counts %d, want 4", got)
+	}
+
+	// the increments may straddle a boundary, a range of two buckets holds them
+	for _, res := range []Resolution{Hour, Day} {
+		buckets, err := ps.History(ctx, PingPongKey, res, now.Add(-time.Minute), now)
+		if err != nil || sum(buckets) != 4 {
+			t.Fatalf("History by %s = %+v, %v, want 4", res, buckets, err)
+		}
+	}
+
+	if got, err := ps.CountSince(ctx, PingPongKey, now.Add(-time.Hour)); err != nil || got != 4 {
+		t.Fatalf("CountSince = %d, %v, want 4", got, err)
+	}
+}
+
+func TestHistoryNamedCounter(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+	key := CounterKey{Namespace: "team-a", Name: "visits"}
+
+	if _, err := ps.Create(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.Increment(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ps.CountSince(ctx, key, time.Now().Add(-time.Minute)); err != nil || got != 1 {
+		t.Fatalf("CountSince = %d, %v, want 1", got, err)
+	}
+
+	// deleting the counter drops its history
+	if err := ps.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ps.CountSince(ctx, key, time.Now().Add(-time.Minute)); err != nil || got != 0 {
+		t.Fatalf("CountSince after Delete = %d, %v, want 0", got, err)
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := ps.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := ps.PruneHistory(ctx, HistoryConfig{MinuteRetention: time.Nanosecond, HourRetention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("PruneHistory deleted %d buckets, want the minute one", deleted)
+	}
+
+	now := time.Now()
+	if got, _ := ps.CountSince(ctx, PingPongKey, now.Add(-time.Hour)); got != 0 {
+		t.Fatalf("CountSince after pruning = %d, want 0", got)
+	}
+	if buckets, err := ps.History(ctx, PingPongKey, Hour, now.Add(-time.Minute), now); err != nil || sum(buckets) != 1 {
+		t.Fatalf("hour bucket after pruning = %+v, %v, want 1", buckets, err)
+	}
+}
+
+func sum(buckets []Bucket) int64 {
+	var total int64
+	for _, b := range buckets {
+		total += b.Count
+	}
+	return total
+}
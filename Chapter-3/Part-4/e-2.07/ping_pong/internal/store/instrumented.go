@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// instrumentedRepo records the store metrics of a PingPongRepo which does not record them
+// itself, the memory, file and redis ones. PingPongStore records its own per query.
+type instrumentedRepo struct {
+	repo    PingPongRepo
+	metrics *storeMetrics
+}
+
+// Instrument wraps repo to record pingpong_count and the latency of its calls, registered on reg
+func Instrument(repo PingPongRepo, reg prometheus.Registerer) PingPongRepo {
+	return &instrumentedRepo{
+		repo:    repo,
+		metrics: newStoreMetrics(reg),
+	}
+}
+
+func (ir *instrumentedRepo) GetCurr(ctx context.Context) (int, error) {
+	start := time.Now()
+	count, err := ir.repo.GetCurr(ctx)
+	ir.record("get", start, count, err)
+	return count, err
+}
+
+func (ir *instrumentedRepo) Update(ctx context.Context) (int, error) {
+	start := time.Now()
+	count, err := ir.repo.Update(ctx)
+	ir.record("update", start, count, err)
+	return count, err
+}
+
+func (ir *instrumentedRepo) IncrementBy(ctx context.Context, step int, idempotencyKey string) (int, bool, error) {
+	start := time.Now()
+	count, replayed, err := ir.repo.IncrementBy(ctx, step, idempotencyKey)
+	ir.record("increment", start, count, err)
+	return count, replayed, err
+}
+
+// record observes a call started at start and sets the count it returned
+func (ir *instrumentedRepo) record(query string, start time.Time, count int, err error) {
+	ir.metrics.observe(query, start, err)
+	if err == nil {
+		ir.metrics.count.Set(float64(count))
+	}
+}
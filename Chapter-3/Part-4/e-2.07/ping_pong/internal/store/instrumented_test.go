@@ -0,0 +1,45 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ping_pong/internal/store"
+	"ping_pong/internal/store/memory"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	repo := store.Instrument(memory.NewPingPongStore(), reg)
+	ctx := context.Background()
+
+	if _, err := repo.GetCurr(ctx); err == nil {
+		t.Fatal("GetCurr before the first increment: expected an error")
+	}
+	if _, err := repo.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.IncrementBy(ctx, 41, "req-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.IncrementBy(ctx, 2, "req-1"); err == nil {
+		t.Fatal("IncrementBy reusing a key with another step: expected an error")
+	}
+
+	wantCount := `
+# HELP pingpong_count Last pingpong counter value read or written by the store.
+# TYPE pingpong_count gauge
+pingpong_count 42
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(wantCount), "pingpong_count"); err != nil {
+		t.Fatal(err)
+	}
+	// get/error, update/ok, increment/ok and increment/error
+	if n, err := testutil.GatherAndCount(reg, "pingpong_db_query_duration_seconds"); err != nil || n != 4 {
+		t.Fatalf("pingpong_db_query_duration_seconds has %d series, %v, want 4", n, err)
+	}
+}
@@ -1,6 +1,140 @@
 package store
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
 type PingPongRepo interface {
-	Update() (int, error)
-	GetCurr() (int, error)
+	Update(ctx context.Context) (int, error)
+	GetCurr(ctx context.Context) (int, error)
+	// IncrementBy adds step to the pingpong counter. A non-empty idempotencyKey is recorded
+	// for IdempotencyTTL: later calls with it add nothing and return the count of the first
+	// one with replayed set, or ErrIdempotencyKeyReused when their step differs.
+	IncrementBy(ctx context.Context, step int, idempotencyKey string) (count int, replayed bool, err error)
+}
+
+var (
+	ErrCounterNotFound      = errors.New("counter not found")
+	ErrCounterExists        = errors.New("counter already exists")
+	ErrIdempotencyKeyReused = errors.New("idempotency key already used with another step")
+	ErrSnapshotExists       = errors.New("snapshot already exists")
+)
+
+// IdempotencyTTL is how long an idempotency key is remembered
+const IdempotencyTTL = 24 * time.Hour
+
+// DefaultNamespace holds the counters of callers not giving a namespace, pingpong included
+const DefaultNamespace = "default"
+
+// CounterKey identifies a counter, names are unique within a namespace (a team or tenant)
+type CounterKey struct {
+	Namespace string
+	Name      string
+}
+
+type Counter struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Value     int64     `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CounterRepo stores the named counters. Get, Increment and Delete return ErrCounterNotFound
+// for a counter never created, Create returns ErrCounterExists for an existing one.
+type CounterRepo interface {
+	Create(ctx context.Context, key CounterKey) (Counter, error)
+	Get(ctx context.Context, key CounterKey) (Counter, error)
+	Increment(ctx context.Context, key CounterKey) (Counter, error)
+	// List returns the counters of namespace ordered by name, limit and offset page through them
+	List(ctx context.Context, namespace string, limit, offset int) ([]Counter, error)
+	Delete(ctx context.Context, key CounterKey) error
+}
+
+// Resolution is the width of the history buckets
+type Resolution string
+
+const (
+	Minute Resolution = "minute"
+	Hour   Resolution = "hour"
+	Day    Resolution = "day"
+)
+
+// Duration is the width of a bucket of resolution r
+func (r Resolution) Duration() time.Duration {
+	switch r {
+	case Hour:
+		return time.Hour
+	case Day:
+		return 24 * time.Hour
+	default:
+		return time.Minute
+	}
+}
+
+// Bucket counts the increments of a counter from Start, over one resolution
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// HistoryRepo reads the increments recorded per minute, hour and day (UTC) of each counter
+type HistoryRepo interface {
+	// History returns a bucket for every step of res from the one holding from to the one
+	// holding to, buckets without increments (or past their retention) count 0
+	History(ctx context.Context, key CounterKey, res Resolution, from, to time.Time) ([]Bucket, error)
+	// CountSince sums the minute buckets from the one holding since
+	CountSince(ctx context.Context, key CounterKey, since time.Time) (int64, error)
+}
+
+// Audited admin actions
+const (
+	ActionReset    = "reset"
+	ActionSet      = "set"
+	ActionSnapshot = "snapshot"
+	ActionDelete   = "delete"
+)
+
+// AuditEntry records an admin action on a counter, Snapshot is the label of a snapshot action
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	OldValue  int64     `json:"old_value"`
+	NewValue  int64     `json:"new_value"`
+	Snapshot  string    `json:"snapshot,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// AuditFilter selects audit entries, empty fields match any value
+type AuditFilter struct {
+	Namespace string
+	Name      string
+	Action    string
+}
+
+// Snapshot is the value of a counter saved under a label
+type Snapshot struct {
+	Label   string    `json:"label"`
+	Value   int64     `json:"value"`
+	Actor   string    `json:"actor"`
+	TakenAt time.Time `json:"taken_at"`
+}
+
+// AdminRepo changes counters outside of increments, every change is written to the audit log
+// in the transaction making it. The counter must exist (ErrCounterNotFound).
+type AdminRepo interface {
+	Reset(ctx context.Context, key CounterKey, actor string) (AuditEntry, error)
+	Set(ctx context.Context, key CounterKey, value int64, actor string) (AuditEntry, error)
+	// DeleteAudited deletes the counter with its snapshots, keys and history, the audit entry stays
+	DeleteAudited(ctx context.Context, key CounterKey, actor string) (AuditEntry, error)
+	// Snapshot saves the current value under label, ErrSnapshotExists if the label is taken
+	Snapshot(ctx context.Context, key CounterKey, label string, actor string) (Snapshot, error)
+	Snapshots(ctx context.Context, key CounterKey) ([]Snapshot, error)
+	// AuditLog returns the matching entries, newest first
+	AuditLog(ctx context.Context, filter AuditFilter, limit, offset int) ([]AuditEntry, error)
 }
@@ -0,0 +1,274 @@
+// Package memory keeps the pingpong counter in the process, optionally saved to a file
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ping_pong/internal/store"
+)
+
+// PingPongStore is a PingPongRepo in memory. Opened with OpenFile it appends every change to
+// a file, so the counter and the idempotency keys survive restarts without a database.
+type PingPongStore struct {
+	mu      sync.Mutex
+	count   int
+	created bool
+	keys    map[string]keyEntry
+	order   []string // keys oldest first, they expire in this order
+
+	path         string   // empty for a store kept in memory only
+	file         *os.File // path opened for appending, nil once closed
+	appended     int      // increments appended since the last compaction
+	compactAfter int
+	now          func() time.Time
+}
+
+// defaultCompactAfter is the number of increments appended to the file before it is rewritten
+const defaultCompactAfter = 1000
+
+// keyEntry is an idempotency key, and a line of the file for every increment
+type keyEntry struct {
+	Key   string    `json:"key,omitempty"`
+	Step  int       `json:"step"`
+	Count int       `json:"count"`
+	At    time.Time `json:"at"`
+}
+
+// fileState is the first line of the file of the store, the increments appended follow it
+type fileState struct {
+	Count   int        `json:"count"`
+	Created bool       `json:"created"`
+	Keys    []keyEntry `json:"keys,omitempty"`
+}
+
+func NewPingPongStore() *PingPongStore {
+	return &PingPongStore{
+		keys: map[string]keyEntry{},
+		now:  time.Now,
+	}
+}
+
+// OpenFile returns a store saved to path, loaded from it when it exists. An increment appends
+// one synced line to the file. Every compactAfter increments, and on open, the file is
+// rewritten without the expired keys through a temporary file renamed over it.
+func OpenFile(path string) (*PingPongStore, error) {
+	ps := NewPingPongStore()
+	ps.path = path
+	ps.compactAfter = defaultCompactAfter
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// created by the compaction below
+	case err != nil:
+		return nil, fmt.Errorf("could not read pingpong file: %w", err)
+	default:
+		if err := ps.load(data); err != nil {
+			return nil, fmt.Errorf("invalid pingpong file %s: %w", path, err)
+		}
+	}
+	ps.expireKeys()
+	if err := ps.compact(); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// load reads the state line and replays the increments appended after it. A last line without
+// its newline is an append cut short by a crash, never acknowledged, and is dropped.
+func (ps *PingPongStore) load(data []byte) error {
+	lines := bytes.Split(data, []byte("\n"))
+	if last := len(lines) - 1; last > 0 {
+		// the state line is written whole, files of older versions have no newline after it
+		lines = lines[:last]
+	}
+
+	var state fileState
+	if err := json.Unmarshal(lines[0], &state); err != nil {
+		return err
+	}
+	ps.count, ps.created = state.Count, state.Created
+	for _, k := range state.Keys {
+		ps.remember(k)
+	}
+	for i, line := range lines[1:] {
+		var inc keyEntry
+		if err := json.Unmarshal(line, &inc); err != nil {
+			return fmt.Errorf("line %d: %w", i+2, err)
+		}
+		ps.count, ps.created = inc.Count, true
+		if inc.Key != "" {
+			ps.remember(inc)
+		}
+	}
+	return nil
+}
+
+func (ps *PingPongStore) GetCurr(ctx context.Context) (int, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if !ps.created {
+		return -1, fmt.Errorf("pingpong counter not created yet: %w", store.ErrCounterNotFound)
+	}
+	return ps.count, nil
+}
+
+func (ps *PingPongStore) Update(ctx context.Context) (int, error) {
+	count, _, err := ps.IncrementBy(ctx, 1, "")
+	return count, err
+}
+
+func (ps *PingPongStore) IncrementBy(ctx context.Context, step int, idempotencyKey string) (int, bool, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.expireKeys()
+	if prev, ok := ps.keys[idempotencyKey]; ok {
+		if prev.Step != step {
+			return -1, false, store.ErrIdempotencyKeyReused
+		}
+		return prev.Count, true, nil
+	}
+
+	// saved first, the memory is only changed once the file holds the increment
+	inc := keyEntry{Key: idempotencyKey, Step: step, Count: ps.count + step, At: ps.now()}
+	if err := ps.save(inc); err != nil {
+		return -1, false, err
+	}
+	ps.count, ps.created = inc.Count, true
+	if idempotencyKey != "" {
+		ps.remember(inc)
+	}
+	return ps.count, false, nil
+}
+
+// Close closes the file of the store, later increments fail. A no-op for a store in memory.
+func (ps *PingPongStore) Close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.file == nil {
+		return nil
+	}
+	err := ps.file.Close()
+	ps.file = nil
+	return err
+}
+
+func (ps *PingPongStore) remember(k keyEntry) {
+	ps.keys[k.Key] = k
+	ps.order = append(ps.order, k.Key)
+}
+
+// expireKeys forgets the idempotency keys older than store.IdempotencyTTL
+func (ps *PingPongStore) expireKeys() {
+	now := ps.now()
+	for len(ps.order) > 0 {
+		oldest := ps.keys[ps.order[0]]
+		if now.Sub(oldest.At) < store.IdempotencyTTL {
+			return
+		}
+		delete(ps.keys, oldest.Key)
+		ps.order = ps.order[1:]
+	}
+}
+
+// save appends inc to the file, compacting it first once compactAfter increments were appended
+func (ps *PingPongStore) save(inc keyEntry) error {
+	if ps.path == "" {
+		return nil
+	}
+	if ps.file == nil {
+		return errors.New("pingpong file closed")
+	}
+	if ps.appended >= ps.compactAfter {
+		if err := ps.compact(); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(inc)
+	if err != nil {
+		return err
+	}
+	if _, err := ps.file.Write(append(line, '\n')); err != nil {
+		// the file may end with part of the line, it is rewritten before the next append
+		ps.appended = ps.compactAfter
+		return fmt.Errorf("could not save pingpong file: %w", err)
+	}
+	if err := ps.file.Sync(); err != nil {
+		ps.appended = ps.compactAfter
+		return fmt.Errorf("could not save pingpong file: %w", err)
+	}
+	ps.appended++
+	return nil
+}
+
+// compact rewrites the file as the state line alone, the expired keys already forgotten,
+// and reopens it for appending
+func (ps *PingPongStore) compact() error {
+	state := fileState{Count: ps.count, Created: ps.created}
+	for _, key := range ps.order {
+		state.Keys = append(state.Keys, ps.keys[key])
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(ps.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("could not save pingpong file: %w", err)
+	}
+
+	file, err := os.OpenFile(ps.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("could not open pingpong file: %w", err)
+	}
+	if ps.file != nil {
+		ps.file.Close()
+	}
+	ps.file, ps.appended = file, 0
+	return nil
+}
+
+// writeFileAtomic replaces path with data through a synced temporary file of the same directory
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// the rename is durable once the directory entry is synced
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
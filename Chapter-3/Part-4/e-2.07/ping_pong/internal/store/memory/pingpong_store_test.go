@@ -0,0 +1,192 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ping_pong/internal/store"
+	"ping_pong/internal/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.PingPongRepo { return NewPingPongStore() })
+}
+
+func TestFileConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.PingPongRepo {
+		ps, err := OpenFile(filepath.Join(t.TempDir(), "pingpong.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ps
+	})
+}
+
+func TestFileSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pingpong.json")
+	ctx := context.Background()
+
+	ps, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ps.IncrementBy(ctx, 4, "req-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.GetCurr(ctx); err != nil || got != 4 {
+		t.Fatalf("GetCurr after reopening = %d, %v, want 4", got, err)
+	}
+	if got, replayed, err := reopened.IncrementBy(ctx, 4, "req-1"); err != nil || got != 4 || !replayed {
+		t.Fatalf("retried IncrementBy after reopening = %d %v %v, want 4 replayed", got, replayed, err)
+	}
+
+	// only the file remains, the temporary files are renamed or removed
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("directory holds %v, %v, want the pingpong file only", entries, err)
+	}
+}
+
+func TestFileSaveFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pingpong.json")
+	ctx := context.Background()
+	ps, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a closed file makes the append fail, the increment is not kept
+	ps.file.Close()
+	if _, _, err := ps.IncrementBy(ctx, 1, "req-1"); err == nil {
+		t.Fatal("IncrementBy with a failing save: expected an error")
+	}
+	if got, err := ps.GetCurr(ctx); err != nil || got != 1 {
+		t.Fatalf("GetCurr after a failed save = %d, %v, want 1", got, err)
+	}
+	if _, ok := ps.keys["req-1"]; ok {
+		t.Fatal("the idempotency key of the failed increment is kept")
+	}
+
+	// the next increment rewrites the file and appends to it again
+	if got, _, err := ps.IncrementBy(ctx, 1, "req-1"); err != nil || got != 2 {
+		t.Fatalf("IncrementBy after a failed save = %d, %v, want 2", got, err)
+	}
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.GetCurr(ctx); err != nil || got != 2 {
+		t.Fatalf("GetCurr after reopening = %d, %v, want 2", got, err)
+	}
+}
+
+func TestFileCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pingpong.json")
+	ctx := context.Background()
+	ps, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ps.now = func() time.Time { return now }
+	ps.compactAfter = 3
+
+	if _, _, err := ps.IncrementBy(ctx, 1, "old"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(store.IdempotencyTTL)
+	for i := range 5 {
+		if _, _, err := ps.IncrementBy(ctx, 1, fmt.Sprintf("req-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// compacted before the fourth increment: the state line and the three increments since
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 4 {
+		t.Fatalf("file holds %d lines, want 4:\n%s", lines, data)
+	}
+	if strings.Contains(string(data), `"old"`) {
+		t.Fatalf("the expired key is still saved:\n%s", data)
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.GetCurr(ctx); err != nil || got != 6 {
+		t.Fatalf("GetCurr after reopening = %d, %v, want 6", got, err)
+	}
+	if len(reopened.order) != 5 {
+		t.Fatalf("%d keys remembered after reopening, want 5", len(reopened.order))
+	}
+}
+
+func TestFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantErr bool
+	}{
+		{"state only", `{"count":3,"created":true}` + "\n", 3, false},
+		{"older version without newline", `{"count":3,"created":true}`, 3, false},
+		{"appended increments", `{"count":3,"created":true}` + "\n" + `{"step":2,"count":5,"at":"2026-01-01T00:00:00Z"}` + "\n", 5, false},
+		{"torn last append", `{"count":3,"created":true}` + "\n" + `{"step":2,"co`, 3, false},
+		{"not JSON", "Ping / Pongs: 3\n", 0, true},
+		{"empty", "", 0, true},
+		{"corrupted increment", `{"count":3,"created":true}` + "\n" + "garbage\n" + `{"step":2,"count":5,"at":"2026-01-01T00:00:00Z"}` + "\n", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pingpong.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			ps, err := OpenFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenFile = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got, err := ps.GetCurr(context.Background()); err != nil || got != tt.want {
+				t.Fatalf("GetCurr = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	ps := NewPingPongStore()
+	now := time.Now()
+	ps.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, _, err := ps.IncrementBy(ctx, 1, "req-1"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(store.IdempotencyTTL)
+	if got, replayed, err := ps.IncrementBy(ctx, 1, "req-1"); err != nil || got != 2 || replayed {
+		t.Fatalf("IncrementBy after the key expired = %d %v %v, want 2 not replayed", got, replayed, err)
+	}
+	if len(ps.order) != 1 {
+		t.Fatalf("%d keys remembered, want the new one only", len(ps.order))
+	}
+}
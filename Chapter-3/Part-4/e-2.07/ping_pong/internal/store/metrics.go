@@ -0,0 +1,38 @@
+package store
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// storeMetrics holds the domain metrics of the pingpong store
+type storeMetrics struct {
+	count         prometheus.Gauge
+	queryDuration *prometheus.HistogramVec
+}
+
+func newStoreMetrics(reg prometheus.Registerer) *storeMetrics {
+	m := &storeMetrics{
+		count: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pingpong_count",
+			Help: "Last pingpong counter value read or written by the store.",
+		}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pingpong_db_query_duration_seconds",
+			Help:    "Latency of the pingpong store queries, by query and outcome.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"query", "outcome"}),
+	}
+	reg.MustRegister(m.count, m.queryDuration)
+	return m
+}
+
+// observe records the duration of a query started at start
+func (m *storeMetrics) observe(query string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.queryDuration.WithLabelValues(query, outcome).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStoreMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := newStoreMetrics(reg)
+
+	m.count.Set(42)
+	start := time.Now()
+	m.observe("get", start, nil)
+	m.observe("get", start, nil)
+	m.observe("update", start, errors.New("connection reset"))
+
+	wantCount := `
+# HELP pingpong_count Last pingpong counter value read or written by the store.
+# TYPE pingpong_count gauge
+pingpong_count 42
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(wantCount), "pingpong_count"); err != nil {
+		t.Fatal(err)
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := map[string]uint64{} // query/outcome --> observations
+	for _, mf := range mfs {
+		if mf.GetName() != "pingpong_db_query_duration_seconds" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			samples[labels["query"]+"/"+labels["outcome"]] = metric.GetHistogram().GetSampleCount()
+		}
+	}
+	wantSamples := map[string]uint64{"get/ok": 2, "update/error": 1}
+	if !maps.Equal(samples, wantSamples) {
+		t.Fatalf("query duration observations = %v, want %v", samples, wantSamples)
+	}
+}
@@ -2,54 +2,208 @@ package store
 
 import (
 	common_db "common/db"
+	"common/tracing"
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("ping_pong/internal/store")
+
+// PingPongKey is the counter behind /pingpong, created by its first increment
+var PingPongKey = CounterKey{Namespace: DefaultNamespace, Name: "pingpong"}
+
 type PingPongStore struct {
 	dbService *common_db.DBService
+	metrics   *storeMetrics
 }
 
-func NewPingPongStore(db *common_db.DBService) *PingPongStore {
+func NewPingPongStore(db *common_db.DBService, reg prometheus.Registerer) *PingPongStore {
 	return &PingPongStore{
 		dbService: db,
+		metrics:   newStoreMetrics(reg),
 	}
 }
 
-func (ps *PingPongStore) GetCurr() (int, error) {
+func (ps *PingPongStore) GetCurr(ctx context.Context) (int, error) {
 	var count int
 	query := `
-	SELECT count
-	FROM pingpong_counter
-	WHERE id = 1
+	SELECT value
+	FROM counters
+	WHERE namespace = $1 AND name = $2
 	`
 
-	err := ps.dbService.DB.QueryRow(query).Scan(&count)
+	ctx, done := ps.startQuery(ctx, "get", query)
+	err := ps.dbService.Querier(ctx).QueryRowContext(ctx, query, PingPongKey.Namespace, PingPongKey.Name).Scan(&count)
+	done(err)
 	if err == sql.ErrNoRows {
-		return -1, fmt.Errorf("No rows in pingpong_count.count for row with id 1")
+		return -1, fmt.Errorf("pingpong counter not created yet: %w", ErrCounterNotFound)
 	}
 
 	if err != nil {
 		return -1, err
 	}
 
+	ps.metrics.count.Set(float64(count))
 	return count, nil
 }
 
-func (ps *PingPongStore) Update() (int, error) {
+func (ps *PingPongStore) Update(ctx context.Context) (int, error) {
+	return ps.add(ctx, "update", 1)
+}
+
+func (ps *PingPongStore) IncrementBy(ctx context.Context, step int, idempotencyKey string) (int, bool, error) {
+	if idempotencyKey == "" {
+		count, err := ps.add(ctx, "increment", step)
+		return count, false, err
+	}
+
+	var count int
+	var replayed bool
+	err := ps.dbService.WithTx(ctx, nil, func(ctx context.Context, _ common_db.Querier) error {
+		var err error
+		count, replayed, err = ps.incrementOnce(ctx, step, idempotencyKey)
+		return err
+	})
+	if err != nil {
+		return -1, false, err
+	}
+	if replayed {
+		ps.metrics.count.Set(float64(count))
+	}
+	return count, replayed, nil
+}
+
+// add creates the pingpong counter at step or adds step to it
+func (ps *PingPongStore) add(ctx context.Context, name string, step int) (int, error) {
 	query := `
-	INSERT INTO pingpong_counter (id, count) VALUES(1, 1)
-	ON CONFLICT (id) DO UPDATE
-	SET count = pingpong_counter.count + 1
-	RETURNING count
+	WITH c AS (
+		INSERT INTO counters (namespace, name, value) VALUES($1, $2, $3)
+		ON CONFLICT (namespace, name) DO UPDATE
+		SET value = counters.value + EXCLUDED.value, updated_at = now()
+		RETURNING namespace, name, value
+	)` + recordBuckets + `
+	SELECT value FROM c
 	`
 
 	var newCount int
-	err := ps.dbService.DB.QueryRowContext(context.Background(), query).Scan(&newCount)
+	ctx, done := ps.startQuery(ctx, name, query)
+	err := ps.dbService.Querier(ctx).QueryRowContext(ctx, query, PingPongKey.Namespace, PingPongKey.Name, step).Scan(&newCount)
+	done(err)
 	if err != nil {
 		return -1, err
 	}
 
+	ps.metrics.count.Set(float64(newCount))
 	return newCount, nil
 }
+
+// incrementOnce runs in a transaction. The key row is claimed before the increment, a concurrent
+// request with the same key blocks on the claim until the first one commits and then replays it.
+func (ps *PingPongStore) incrementOnce(ctx context.Context, step int, idempotencyKey string) (int, bool, error) {
+	q := ps.dbService.Querier(ctx)
+
+	purge := `
+	DELETE FROM idempotency_keys
+	WHERE created_at < now() - make_interval(secs => $1)
+	`
+	qctx, done := ps.startQuery(ctx, "idempotency_purge", purge)
+	_, err := q.ExecContext(qctx, purge, IdempotencyTTL.Seconds())
+	done(err)
+	if err != nil {
+		return -1, false, err
+	}
+
+	// the key references the counter, which the legacy route creates lazily
+	ensure := `
+	INSERT INTO counters (namespace, name) VALUES($1, $2)
+	ON CONFLICT (namespace, name) DO NOTHING
+	`
+	qctx, done = ps.startQuery(ctx, "counter_ensure", ensure)
+	_, err = q.ExecContext(qctx, ensure, PingPongKey.Namespace, PingPongKey.Name)
+	done(err)
+	if err != nil {
+		return -1, false, err
+	}
+
+	claim := `
+	INSERT INTO idempotency_keys (namespace, name, key, step) VALUES($1, $2, $3, $4)
+	ON CONFLICT (namespace, name, key) DO NOTHING
+	`
+	qctx, done = ps.startQuery(ctx, "idempotency_claim", claim)
+	res, err := q.ExecContext(qctx, claim, PingPongKey.Namespace, PingPongKey.Name, idempotencyKey, step)
+	done(err)
+	if err != nil {
+		return -1, false, err
+	}
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return -1, false, err
+	}
+
+	if claimed == 0 {
+		replay := `
+		SELECT step, value
+		FROM idempotency_keys
+		WHERE namespace = $1 AND name = $2 AND key = $3
+		`
+		var prevStep, count int
+		qctx, done = ps.startQuery(ctx, "idempotency_replay", replay)
+		err := q.QueryRowContext(qctx, replay, PingPongKey.Namespace, PingPongKey.Name, idempotencyKey).Scan(&prevStep, &count)
+		done(err)
+		if err != nil {
+			return -1, false, err
+		}
+		if prevStep != step {
+			return -1, false, ErrIdempotencyKeyReused
+		}
+		return count, true, nil
+	}
+
+	count, err := ps.add(ctx, "increment", step)
+	if err != nil {
+		return -1, false, err
+	}
+
+	record := `
+	UPDATE idempotency_keys
+	SET value = $4
+	WHERE namespace = $1 AND name = $2 AND key = $3
+	`
+	qctx, done = ps.startQuery(ctx, "idempotency_record", record)
+	_, err = q.ExecContext(qctx, record, PingPongKey.Namespace, PingPongKey.Name, idempotencyKey, count)
+	done(err)
+	if err != nil {
+		return -1, false, err
+	}
+	return count, false, nil
+}
+
+// startQuery opens a client span for query and returns the func recording its outcome
+// on both the span and the latency histogram
+func (ps *PingPongStore) startQuery(ctx context.Context, name string, query string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "pingpong."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(name),
+			semconv.DBCollectionName("counters"),
+			semconv.DBQueryText(query),
+		),
+	)
+	return ctx, func(err error) {
+		ps.metrics.observe(name, start, err)
+		if err != nil && err != sql.ErrNoRows {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	common_db "common/db"
+	"common/db/dbtest"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"ping_pong/internal/migrations"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMain(m *testing.M) { os.Exit(dbtest.Main(m)) }
+
+func newTestStore(t *testing.T) *PingPongStore {
+	t.Helper()
+	return NewPingPongStore(dbtest.New(t, migrations.FS), prometheus.NewRegistry())
+}
+
+func TestGetCurrEmpty(t *testing.T) {
+	ps := newTestStore(t)
+
+	if _, err := ps.GetCurr(context.Background()); err == nil {
+		t.Fatal("GetCurr on an empty counter: expected an error")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	for want := 1; want <= 3; want++ {
+		got, err := ps.Update(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("Update = %d, want %d", got, want)
+		}
+	}
+
+	got, err := ps.GetCurr(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 3 {
+		t.Fatalf("GetCurr = %d, want 3", got)
+	}
+	if v := testutil.ToFloat64(ps.metrics.count); v != 3 {
+		t.Fatalf("pingpong_count = %v, want 3", v)
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	const n = 20
+	var wg sync.WaitGroup
+	for range n {
+		wg.Go(func() {
+			if _, err := ps.Update(ctx); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if got, err := ps.GetCurr(ctx); err != nil || got != n {
+		t.Fatalf("GetCurr = %d, %v, want %d", got, err, n)
+	}
+}
+
+func TestUpdateRolledBack(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+	if _, err := ps.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	errAbort := errors.New("abort")
+	err := ps.dbService.WithTx(ctx, nil, func(ctx context.Context, _ common_db.Querier) error {
+		if _, err := ps.Update(ctx); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx = %v, want %v", err, errAbort)
+	}
+
+	if got, err := ps.GetCurr(ctx); err != nil || got != 1 {
+		t.Fatalf("GetCurr = %d, %v, want 1", got, err)
+	}
+}
+
+func TestSchemasAreIsolated(t *testing.T) {
+	a, b := newTestStore(t), newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := a.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetCurr(ctx); err == nil {
+		t.Fatal("update in one schema is visible in the other")
+	}
+}
+
+// pods of the release before the named counters still increment pingpong_counter during the
+// rolling update, their increments must reach default/pingpong
+func TestLegacyIncrementsMirrored(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := ps.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	legacyUpdate := `
+	INSERT INTO pingpong_counter (id, count) VALUES(1, 1)
+	ON CONFLICT (id) DO UPDATE
+	SET count = pingpong_counter.count + 1`
+	for range 2 {
+		if _, err := ps.dbService.DB.ExecContext(ctx, legacyUpdate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, err := ps.GetCurr(ctx); err != nil || got != 3 {
+		t.Fatalf("GetCurr = %d, %v, want 3", got, err)
+	}
+	if got, err := ps.Update(ctx); err != nil || got != 4 {
+		t.Fatalf("Update = %d, %v, want 4", got, err)
+	}
+}
+
+func TestIncrementByIdempotent(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	if got, replayed, err := ps.IncrementBy(ctx, 5, "req-1"); err != nil || got != 5 || replayed {
+		t.Fatalf("IncrementBy = %d %v %v, want 5 not replayed", got, replayed, err)
+	}
+	if got, replayed, err := ps.IncrementBy(ctx, 5, "req-1"); err != nil || got != 5 || !replayed {
+		t.Fatalf("retried IncrementBy = %d %v %v, want 5 replayed", got, replayed, err)
+	}
+	if _, _, err := ps.IncrementBy(ctx, 2, "req-1"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("IncrementBy with another step = %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+	if got, _, err := ps.IncrementBy(ctx, 2, ""); err != nil || got != 7 {
+		t.Fatalf("IncrementBy without key = %d %v, want 7", got, err)
+	}
+}
+
+func TestIncrementByConcurrentKey(t *testing.T) {
+	ps := newTestStore(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			if got, _, err := ps.IncrementBy(ctx, 1, "same"); err != nil || got != 1 {
+				t.Errorf("IncrementBy = %d, %v, want 1", got, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if got, err := ps.GetCurr(ctx); err != nil || got != 1 {
+		t.Fatalf("GetCurr = %d, %v, want 1", got, err)
+	}
+}
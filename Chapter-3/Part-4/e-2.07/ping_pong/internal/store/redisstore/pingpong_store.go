@@ -0,0 +1,104 @@
+// Package redisstore keeps the pingpong counter in Redis, or any server speaking its protocol
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"ping_pong/internal/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// incrementOnce adds ARGV[1] to the counter KEYS[1] unless the idempotency key KEYS[2] holds
+// "step:count" from an earlier call. It returns {0, count}, {1, count} for a replay and
+// {2, 0} for a key used with another step. Scripts run atomically on the server.
+var incrementOnce = redis.NewScript(`
+local prev = redis.call('GET', KEYS[2])
+if prev then
+	local step, count = string.match(prev, '^(%d+):(%d+)$')
+	if step ~= ARGV[1] then
+		return {2, 0}
+	end
+	return {1, tonumber(count)}
+end
+local count = redis.call('INCRBY', KEYS[1], ARGV[1])
+redis.call('SET', KEYS[2], ARGV[1] .. ':' .. count, 'PX', ARGV[2])
+return {0, count}
+`)
+
+// replies of incrementOnce
+const (
+	incremented = 0
+	replayed    = 1
+	reused      = 2
+)
+
+// PingPongStore is a PingPongRepo on Redis. Its keys share prefix, a hash tag such as
+// "{pingpong}:" keeps them in one slot of a cluster as the script requires.
+type PingPongStore struct {
+	client    redis.UniversalClient
+	countKey  string
+	keyPrefix string
+}
+
+func NewPingPongStore(client redis.UniversalClient, prefix string) *PingPongStore {
+	return &PingPongStore{
+		client:    client,
+		countKey:  prefix + "count",
+		keyPrefix: prefix + "idempotency:",
+	}
+}
+
+func (ps *PingPongStore) GetCurr(ctx context.Context) (int, error) {
+	count, err := ps.client.Get(ctx, ps.countKey).Int()
+	if errors.Is(err, redis.Nil) {
+		return -1, fmt.Errorf("pingpong counter not created yet: %w", store.ErrCounterNotFound)
+	}
+	if err != nil {
+		return -1, err
+	}
+	return count, nil
+}
+
+func (ps *PingPongStore) Update(ctx context.Context) (int, error) {
+	count, err := ps.client.Incr(ctx, ps.countKey).Result()
+	if err != nil {
+		return -1, err
+	}
+	return int(count), nil
+}
+
+func (ps *PingPongStore) IncrementBy(ctx context.Context, step int, idempotencyKey string) (int, bool, error) {
+	if idempotencyKey == "" {
+		count, err := ps.client.IncrBy(ctx, ps.countKey, int64(step)).Result()
+		if err != nil {
+			return -1, false, err
+		}
+		return int(count), false, nil
+	}
+
+	keys := []string{ps.countKey, ps.keyPrefix + idempotencyKey}
+	reply, err := incrementOnce.Run(ctx, ps.client, keys, strconv.Itoa(step), store.IdempotencyTTL.Milliseconds()).Int64Slice()
+	if err != nil {
+		return -1, false, err
+	}
+	if len(reply) == 2 {
+		switch reply[0] {
+		case incremented:
+			return int(reply[1]), false, nil
+		case replayed:
+			return int(reply[1]), true, nil
+		case reused:
+			return -1, false, store.ErrIdempotencyKeyReused
+		}
+	}
+	return -1, false, fmt.Errorf("unexpected reply %v of the increment script", reply)
+}
+
+// Ping checks the connection, for the readiness probe
+func (ps *PingPongStore) Ping(ctx context.Context) error {
+	return ps.client.Ping(ctx).Err()
+}
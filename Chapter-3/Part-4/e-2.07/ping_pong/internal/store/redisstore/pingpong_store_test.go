@@ -0,0 +1,42 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ping_pong/internal/store"
+	"ping_pong/internal/store/storetest"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestStore runs the store against an in-process Redis stand-in
+func newTestStore(t *testing.T) (*PingPongStore, *miniredis.Miniredis) {
+	t.Helper()
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewPingPongStore(client, "{pingpong}:"), srv
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.PingPongRepo {
+		ps, _ := newTestStore(t)
+		return ps
+	})
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	ps, srv := newTestStore(t)
+	ctx := context.Background()
+
+	if _, _, err := ps.IncrementBy(ctx, 1, "req-1"); err != nil {
+		t.Fatal(err)
+	}
+	srv.FastForward(store.IdempotencyTTL + time.Second)
+	if got, replayed, err := ps.IncrementBy(ctx, 1, "req-1"); err != nil || got != 2 || replayed {
+		t.Fatalf("IncrementBy after the key expired = %d %v %v, want 2 not replayed", got, replayed, err)
+	}
+}
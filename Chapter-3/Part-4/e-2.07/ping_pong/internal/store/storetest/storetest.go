@@ -0,0 +1,192 @@
+// Package storetest is the conformance suite of the PingPongRepo backends. Every backend
+// runs it from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.PingPongRepo { return newTestStore(t) })
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"ping_pong/internal/store"
+)
+
+// concurrency is the number of goroutines of the concurrent cases
+const concurrency = 16
+
+// Run checks the PingPongRepo contract against fresh repositories from newRepo
+func Run(t *testing.T, newRepo func(t *testing.T) store.PingPongRepo) {
+	t.Run("EmptyCounter", func(t *testing.T) { testEmptyCounter(t, newRepo(t)) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newRepo(t)) })
+	t.Run("IncrementBy", func(t *testing.T) { testIncrementBy(t, newRepo(t)) })
+	t.Run("Idempotent", func(t *testing.T) { testIdempotent(t, newRepo(t)) })
+	t.Run("ConcurrentUpdates", func(t *testing.T) { testConcurrentUpdates(t, newRepo(t)) })
+	t.Run("ConcurrentIdempotent", func(t *testing.T) { testConcurrentIdempotent(t, newRepo(t)) })
+	t.Run("Monotonic", func(t *testing.T) { testMonotonic(t, newRepo(t)) })
+}
+
+func testEmptyCounter(t *testing.T, repo store.PingPongRepo) {
+	if _, err := repo.GetCurr(context.Background()); !errors.Is(err, store.ErrCounterNotFound) {
+		t.Fatalf("GetCurr before any increment = %v, want %v", err, store.ErrCounterNotFound)
+	}
+}
+
+func testUpdate(t *testing.T, repo store.PingPongRepo) {
+	ctx := context.Background()
+	for want := 1; want <= 3; want++ {
+		if got, err := repo.Update(ctx); err != nil || got != want {
+			t.Fatalf("Update = %d, %v, want %d", got, err, want)
+		}
+	}
+	if got, err := repo.GetCurr(ctx); err != nil || got != 3 {
+		t.Fatalf("GetCurr = %d, %v, want 3", got, err)
+	}
+}
+
+func testIncrementBy(t *testing.T, repo store.PingPongRepo) {
+	ctx := context.Background()
+	if got, replayed, err := repo.IncrementBy(ctx, 5, ""); err != nil || got != 5 || replayed {
+		t.Fatalf("IncrementBy(5) = %d %v %v, want 5", got, replayed, err)
+	}
+	if got, _, err := repo.IncrementBy(ctx, 5, ""); err != nil || got != 10 {
+		t.Fatalf("second IncrementBy(5) without key = %d %v, want 10: only keys deduplicate", got, err)
+	}
+	if got, err := repo.Update(ctx); err != nil || got != 11 {
+		t.Fatalf("Update = %d, %v, want 11", got, err)
+	}
+}
+
+func testIdempotent(t *testing.T, repo store.PingPongRepo) {
+	ctx := context.Background()
+	if got, replayed, err := repo.IncrementBy(ctx, 2, "req-1"); err != nil || got != 2 || replayed {
+		t.Fatalf("IncrementBy = %d %v %v, want 2 not replayed", got, replayed, err)
+	}
+	if _, _, err := repo.IncrementBy(ctx, 3, "req-2"); err != nil {
+		t.Fatal(err)
+	}
+	// the replay returns the count of the first call, not the current one
+	if got, replayed, err := repo.IncrementBy(ctx, 2, "req-1"); err != nil || got != 2 || !replayed {
+		t.Fatalf("retried IncrementBy = %d %v %v, want 2 replayed", got, replayed, err)
+	}
+	if _, _, err := repo.IncrementBy(ctx, 4, "req-1"); !errors.Is(err, store.ErrIdempotencyKeyReused) {
+		t.Fatalf("IncrementBy with another step = %v, want %v", err, store.ErrIdempotencyKeyReused)
+	}
+	if got, err := repo.GetCurr(ctx); err != nil || got != 5 {
+		t.Fatalf("GetCurr = %d, %v, want 5", got, err)
+	}
+}
+
+func testConcurrentUpdates(t *testing.T, repo store.PingPongRepo) {
+	ctx := context.Background()
+	const perWorker = 10
+
+	var (
+		mu   sync.Mutex
+		seen []int
+		wg   sync.WaitGroup
+	)
+	for range concurrency {
+		wg.Go(func() {
+			for range perWorker {
+				got, err := repo.Update(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				seen = append(seen, got)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	// every update returns its own count: 1 to n, no value lost or repeated
+	slices.Sort(seen)
+	for i, got := range seen {
+		if got != i+1 {
+			t.Fatalf("Update results %v: want each of 1 to %d once", seen, concurrency*perWorker)
+		}
+	}
+	if got, err := repo.GetCurr(ctx); err != nil || got != concurrency*perWorker {
+		t.Fatalf("GetCurr = %d, %v, want %d", got, err, concurrency*perWorker)
+	}
+}
+
+func testConcurrentIdempotent(t *testing.T, repo store.PingPongRepo) {
+	ctx := context.Background()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		replayed int
+	)
+	for range concurrency {
+		wg.Go(func() {
+			got, r, err := repo.IncrementBy(ctx, 3, "same")
+			if err != nil || got != 3 {
+				t.Errorf("IncrementBy = %d, %v, want 3", got, err)
+			}
+			if r {
+				mu.Lock()
+				replayed++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	if replayed != concurrency-1 {
+		t.Fatalf("%d replayed calls, want all but the first (%d)", replayed, concurrency-1)
+	}
+	if got, err := repo.GetCurr(ctx); err != nil || got != 3 {
+		t.Fatalf("GetCurr = %d, %v, want 3", got, err)
+	}
+}
+
+func testMonotonic(t *testing.T, repo store.PingPongRepo) {
+	ctx := context.Background()
+	if _, err := repo.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range concurrency / 2 {
+		wg.Go(func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := repo.Update(ctx); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+
+	// a reader never sees the counter go back
+	last := 0
+	for range 200 {
+		got, err := repo.GetCurr(ctx)
+		if err != nil {
+			t.Error(err)
+			break
+		}
+		if got < last {
+			t.Errorf("GetCurr went from %d back to %d", last, got)
+			break
+		}
+		last = got
+	}
+	close(stop)
+	wg.Wait()
+}